@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// errBatchRetry - значение не выведено: вывод его партии завершился ошибкой,
+// и партия будет отправлена повторно.
+var errBatchRetry = errors.New("партия будет отправлена повторно")
+
+// batchAcks - подтверждение доставки партий буфера приемниками (-ack).
+// Партия подтверждается после вывода ее последнего значения и сброса
+// приемников; при ошибке вывода остальные значения партии пропускаются,
+// неотправленный остаток приемников отбрасывается, и партия возвращается
+// буферу на повторную отправку. Методы допускают nil-получатель.
+type batchAcks struct {
+	acks    *pipeline.Acks[envelope]
+	batches *batchTracker
+	failed  bool // Вывод текущей партии завершился ошибкой
+}
+
+// newBatchAcks - подтверждение доставки партий, отправляемых с acks.
+func newBatchAcks(acks *pipeline.Acks[envelope]) *batchAcks {
+	return &batchAcks{acks: acks, batches: &batchTracker{}}
+}
+
+// flushed - регистрация отправки партии (обработчик BufferOptions.OnFlush).
+func (a *batchAcks) flushed(id uint64, n int) {
+	if a != nil {
+		a.batches.flushed(id, n)
+	}
+}
+
+// write - вывод значения it приемником sink с подтверждением партии после
+// вывода ее последнего значения. Возвращает errBatchRetry, если значение
+// не выведено из-за ошибки вывода партии.
+func (a *batchAcks) write(sink pipeline.Sink[pipeline.Num], it envelope) error {
+	if a == nil {
+		return writeItem(sink, it)
+	}
+	id, last, ok := a.batches.next()
+	if !ok {
+		return writeItem(sink, it)
+	}
+	retry := a.failed // Значение относится к партии с ошибкой вывода
+	if !retry {
+		err := writeItem(sink, it)
+		if err == nil && last {
+			err = sink.Flush()
+		}
+		if err != nil {
+			a.failed, retry = true, true
+			resetSink(sink)
+			stageLog("sink").Warn("Ошибка вывода партии, партия будет отправлена повторно", "batch", id, "err", err)
+		}
+	}
+	if last {
+		if a.failed {
+			a.acks.Nack(id)
+		} else {
+			a.acks.Ack(id)
+		}
+		a.failed = false
+	}
+	if retry {
+		return errBatchRetry
+	}
+	return nil
+}
+
+// skip - пропуск значения без вывода (например, по истечении времени
+// дообработки): партия подтверждается, чтобы буфер не ожидал ее повторной
+// отправки. Пропущенные значения учитываются вызывающим.
+func (a *batchAcks) skip() {
+	if a == nil {
+		return
+	}
+	if id, last, ok := a.batches.next(); ok && last {
+		a.acks.Ack(id)
+		a.failed = false
+	}
+}
+
+// resetSink - отбрасывание неотправленных значений приемника sink, если он
+// их накапливает: партия с ошибкой будет выведена повторно целиком.
+func resetSink(sink pipeline.Sink[pipeline.Num]) {
+	if r, ok := sink.(pipeline.Resetter); ok {
+		r.Reset()
+	}
+}
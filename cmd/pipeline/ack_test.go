@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// failingSink - приемник, возвращающий ошибку на записи с номером failAt (с 1).
+type failingSink struct {
+	writes  int
+	failAt  int
+	written []pipeline.Num
+}
+
+func (s *failingSink) Write(v pipeline.Num) error {
+	s.writes++
+	if s.writes == s.failAt {
+		return errors.New("ошибка записи")
+	}
+	s.written = append(s.written, v)
+	return nil
+}
+
+func (s *failingSink) Flush() error { return nil }
+
+func TestBatchAcksRetryOnOwnWriteError(t *testing.T) {
+	a := newBatchAcks(pipeline.NewAcks[envelope]())
+	a.flushed(1, 3)
+	sink := &failingSink{failAt: 2}
+	var errs []error
+	for i := range 3 {
+		errs = append(errs, a.write(sink, envelope{Value: pipeline.IntNum(int64(i + 1))}))
+	}
+	if errs[0] != nil {
+		t.Fatalf("первое значение: %v", errs[0])
+	}
+	// Ошибка вывода самого значения и пропуск остатка партии
+	for i, err := range errs[1:] {
+		if !errors.Is(err, errBatchRetry) {
+			t.Fatalf("значение %d: ошибка %v, ожидалась errBatchRetry", i+2, err)
+		}
+	}
+	if len(sink.written) != 1 {
+		t.Fatalf("выведено %v, ожидалось одно значение", sink.written)
+	}
+
+	// Следующая партия выводится заново
+	a.flushed(2, 1)
+	if err := a.write(sink, envelope{Value: pipeline.IntNum(4)}); err != nil {
+		t.Fatalf("следующая партия: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// buildAnomaly - стадия anomaly: значения, отклоняющиеся от скользящего окна
+// больше чем на sigmas стандартных отклонений, помечаются (поле anomaly
+// в jsonl) и без output остаются в потоке, с output - дописываются в файл
+// вместо выхода стадии.
+func buildAnomaly(p stageParams, _ stageEnv) (pipeline.Stage[envelope], error) {
+	window, err := p.int("window", pipeline.DefaultAnomalyWindow)
+	if err != nil {
+		return nil, err
+	}
+	sigmas, err := p.float("sigmas", pipeline.DefaultAnomalySigmas)
+	if err != nil {
+		return nil, err
+	}
+	output, err := p.string("output", "")
+	if err != nil {
+		return nil, err
+	}
+	format, err := p.string("format", "")
+	if err != nil {
+		return nil, err
+	}
+	opts := pipeline.AnomalyOptions[envelope]{Window: window, Sigmas: sigmas, Mark: pipeline.MarkAnomaly[pipeline.Num]}
+	switch {
+	case window <= 1:
+		return nil, fmt.Errorf("параметр window должен быть не меньше 2: %d", window)
+	case sigmas <= 0:
+		return nil, fmt.Errorf("параметр sigmas должен быть положительным: %v", sigmas)
+	case output == "" && format != "":
+		return nil, fmt.Errorf("параметр format задается только вместе с output")
+	}
+	value := func(it envelope) float64 { return it.Value.Float64() }
+	if output == "" {
+		return pipeline.DetectAnomaliesBy(value, opts), nil
+	}
+	sink, f, err := openOutputFile(output, format)
+	if err != nil {
+		return nil, err
+	}
+	opts.Alert = sink
+	detect := pipeline.DetectAnomaliesBy(value, opts)
+	return func(ctx context.Context, in <-chan envelope, out chan<- envelope) {
+		defer f.Close()
+		detect(ctx, in, out)
+	}, nil
+}
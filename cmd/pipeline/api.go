@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// maxAPIBody - наибольший размер тела запроса API управления.
+const maxAPIBody = 1 << 20
+
+// apiConfig - настройки, изменяемые через API управления.
+type apiConfig struct {
+	BufferSize    int    `json:"buffer_size"`
+	FlushInterval string `json:"flush_interval"`
+	Paused        bool   `json:"paused"`
+}
+
+// apiConfigPatch - изменение настроек: отсутствующие поля не меняются.
+type apiConfigPatch struct {
+	BufferSize    *int    `json:"buffer_size"`
+	FlushInterval *string `json:"flush_interval"`
+	Paused        *bool   `json:"paused"`
+}
+
+// apiStats - ответ /api/v1/stats: статистика работы и текущие настройки.
+type apiStats struct {
+	statsReport
+	Config apiConfig `json:"config"`
+}
+
+// apiError - ответ с ошибкой.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// registerAPI - регистрация API управления запуском rc в mux:
+// GET/PATCH /api/v1/config, GET/POST/DELETE /api/v1/pause,
+// POST /api/v1/flush и GET /api/v1/stats.
+func registerAPI(mux *http.ServeMux, rc *runControl) {
+	mux.HandleFunc("GET /api/v1/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, rc.config())
+	})
+	setConfig := func(w http.ResponseWriter, r *http.Request) {
+		var patch apiConfigPatch
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&patch); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "некорректный JSON: " + err.Error()})
+			return
+		}
+		if err := rc.apply(patch); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, rc.config())
+	}
+	mux.HandleFunc("PATCH /api/v1/config", setConfig)
+	mux.HandleFunc("PUT /api/v1/config", setConfig)
+
+	mux.HandleFunc("GET /api/v1/pause", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, rc.config())
+	})
+	mux.HandleFunc("POST /api/v1/pause", func(w http.ResponseWriter, r *http.Request) {
+		if rc.gate.pause() {
+			stageLog("source").Info("Источник приостановлен")
+		}
+		writeJSON(w, http.StatusOK, rc.config())
+	})
+	mux.HandleFunc("DELETE /api/v1/pause", func(w http.ResponseWriter, r *http.Request) {
+		if rc.gate.resume() {
+			stageLog("source").Info("Чтение источника возобновлено")
+		}
+		writeJSON(w, http.StatusOK, rc.config())
+	})
+	mux.HandleFunc("POST /api/v1/flush", func(w http.ResponseWriter, r *http.Request) {
+		rc.buffer.Flush()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("GET /api/v1/stats", func(w http.ResponseWriter, r *http.Request) {
+		if !rc.stats.running() {
+			writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "пайплайн не запущен"})
+			return
+		}
+		writeJSON(w, http.StatusOK, apiStats{statsReport: rc.stats.report(), Config: rc.config()})
+	})
+}
+
+// config - текущие настройки запуска.
+func (rc *runControl) config() apiConfig {
+	s := rc.buffer.Settings()
+	return apiConfig{
+		BufferSize:    s.Size,
+		FlushInterval: s.FlushInterval.String(),
+		Paused:        rc.gate.paused(),
+	}
+}
+
+// apply - проверка и одновременное применение изменений настроек:
+// при ошибке не меняется ни одна настройка.
+func (rc *runControl) apply(patch apiConfigPatch) error {
+	var s pipeline.BufferSettings
+	if patch.BufferSize != nil {
+		if *patch.BufferSize <= 0 {
+			return fmt.Errorf("buffer_size должен быть положительным: %d", *patch.BufferSize)
+		}
+		s.Size = *patch.BufferSize
+	}
+	if patch.FlushInterval != nil {
+		d, err := time.ParseDuration(*patch.FlushInterval)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("flush_interval: ожидается положительная длительность (например, 2s): %q", *patch.FlushInterval)
+		}
+		s.FlushInterval = d
+	}
+	if err := rc.buffer.Apply(s); err != nil {
+		return err
+	}
+	if patch.Paused != nil {
+		if *patch.Paused {
+			rc.gate.pause()
+		} else {
+			rc.gate.resume()
+		}
+	}
+	c := rc.config()
+	stageLog("http").Info("Настройки изменены", "buffer_size", c.BufferSize, "flush_interval", c.FlushInterval, "paused", c.Paused)
+	return nil
+}
+
+// writeJSON - ответ v в формате JSON с кодом status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
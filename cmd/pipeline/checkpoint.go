@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// checkpointState - содержимое файла контрольной точки.
+type checkpointState struct {
+	Files   []string  `json:"files"`    // Входные файлы
+	Values  uint64    `json:"values"`   // Прочитанные значения, обработка которых завершена
+	SavedAt time.Time `json:"saved_at"` // Время записи
+}
+
+// loadCheckpoint - чтение контрольной точки из path.
+// Отсутствующий файл означает обработку с начала.
+func loadCheckpoint(path string) (checkpointState, error) {
+	var st checkpointState
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("контрольная точка %s: %w", path, err)
+	}
+	return st, nil
+}
+
+// saveCheckpoint - запись контрольной точки в path через временный файл,
+// чтобы при сбое во время записи сохранилась предыдущая точка.
+func saveCheckpoint(path string, st checkpointState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if err = errors.Join(err, f.Close()); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// checkpointer - запись контрольных точек без остановки цепочки. Стадии
+// сохраняют порядок значений (см. reorders), поэтому выведенное значение с
+// номером n означает, что значения источника до n включительно обработаны:
+// выведены, отброшены или учтены в выведенном агрегате. Методы вызываются
+// из цикла вывода; nil - контрольные точки отключены.
+type checkpointer struct {
+	store  func(values uint64) error // Сохранение количества обработанных значений источника
+	base   uint64                    // Значения, пропущенные при продолжении
+	done   uint64                    // Наибольший номер выведенного значения
+	saved  uint64                    // Номер, сохраненный в последней точке
+	ticker *time.Ticker              // Моменты записи (nil до start)
+}
+
+// newCheckpointer - контрольные точки обработки входных файлов cfg в файле
+// -checkpoint или подтверждения сообщений Kafka и Redis (nil, если не нужны).
+// При -resume загружается сохраненная позиция (см. skip). Для подтверждающих
+// источников сохранение задается источником (см. startSource).
+func newCheckpointer(cfg config) (*checkpointer, error) {
+	c := &checkpointer{}
+	if cfg.acksSource() {
+		return c, nil
+	}
+	if cfg.checkpointPath == "" {
+		return nil, nil
+	}
+	files, err := inputFiles(cfg.input, cfg.inputDir)
+	if err != nil {
+		return nil, err
+	}
+	c.store = func(values uint64) error {
+		st := checkpointState{Files: files, Values: values, SavedAt: time.Now()}
+		if err := saveCheckpoint(cfg.checkpointPath, st); err != nil {
+			return fmt.Errorf("запись контрольной точки: %w", err)
+		}
+		return nil
+	}
+	if !cfg.resume {
+		return c, nil
+	}
+	st, err := loadCheckpoint(cfg.checkpointPath)
+	if err != nil {
+		return nil, err
+	}
+	log := stageLog("checkpoint")
+	if st.Files != nil && !slices.Equal(st.Files, files) {
+		log.Warn("Список входных файлов изменился с момента контрольной точки", "saved", st.Files, "files", files)
+	}
+	c.base = st.Values
+	log.Info("Продолжение с контрольной точки", "values", st.Values, "saved_at", st.SavedAt)
+	return c, nil
+}
+
+// skip - количество значений источника, обработанных до контрольной точки.
+func (c *checkpointer) skip() uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.base
+}
+
+// start - запись контрольных точек каждые interval.
+func (c *checkpointer) start(interval time.Duration) {
+	c.ticker = time.NewTicker(interval)
+}
+
+// stop - остановка периодической записи.
+func (c *checkpointer) stop() {
+	if c != nil && c.ticker != nil {
+		c.ticker.Stop()
+	}
+}
+
+// next - канал моментов записи контрольной точки (nil - контрольные точки
+// отключены).
+func (c *checkpointer) next() <-chan time.Time {
+	if c == nil || c.ticker == nil {
+		return nil
+	}
+	return c.ticker.C
+}
+
+// wrote - учет значения it, выведенного приемником.
+func (c *checkpointer) wrote(it envelope) {
+	if c != nil {
+		c.done = max(c.done, it.Seq)
+	}
+}
+
+// tick - запись позиции последнего выведенного значения, если она изменилась.
+func (c *checkpointer) tick(sink pipeline.Sink[pipeline.Num]) error {
+	if c.done == c.saved {
+		return nil
+	}
+	return c.save(c.done, sink)
+}
+
+// finish - запись итоговой позиции pos после вывода всех значений цепочки.
+func (c *checkpointer) finish(pos pipeline.ChainPosition, sink pipeline.Sink[pipeline.Num]) error {
+	if c == nil {
+		return nil
+	}
+	return c.save(pos.Received, sink)
+}
+
+// save - сброс приемника и сохранение позиции: received значений цепочки
+// обработаны.
+func (c *checkpointer) save(received uint64, sink pipeline.Sink[pipeline.Num]) error {
+	if err := sink.Flush(); err != nil {
+		return err
+	}
+	values := c.base + received
+	if err := c.store(values); err != nil {
+		return err
+	}
+	c.saved = received
+	stageLog("checkpoint").Debug("Контрольная точка записана", "values", values)
+	return nil
+}
+
+// sourceAcks - сообщения источника, ожидающие подтверждения обработки,
+// с количеством значений, полученных по каждое сообщение включительно.
+// Используется источниками, подтверждающими сообщения в контрольных точках.
+type sourceAcks[M any] struct {
+	mu      sync.Mutex
+	pending []pendingAck[M]
+	values  uint64 // Значения, переданные в пайплайн
+}
+
+// pendingAck - сообщение, ожидающее подтверждения.
+type pendingAck[M any] struct {
+	msg    M
+	values uint64
+}
+
+// add - учет прочитанного сообщения m; valid - значение сообщения передано в пайплайн.
+func (a *sourceAcks[M]) add(m M, valid bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if valid {
+		a.values++
+	}
+	a.pending = append(a.pending, pendingAck[M]{msg: m, values: a.values})
+}
+
+// take - извлечение сообщений, все значения которых входят в первые values
+// значений источника.
+func (a *sourceAcks[M]) take(values uint64) []M {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := 0
+	for i < len(a.pending) && a.pending[i].values <= values {
+		i++
+	}
+	msgs := make([]M, i)
+	for j := range msgs {
+		msgs[j] = a.pending[j].msg
+	}
+	a.pending = a.pending[i:]
+	return msgs
+}
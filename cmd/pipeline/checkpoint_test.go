@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// flushSink - приемник, считающий сбросы.
+type flushSink struct{ flushes int }
+
+func (s *flushSink) Write(pipeline.Num) error { return nil }
+
+func (s *flushSink) Flush() error {
+	s.flushes++
+	return nil
+}
+
+func TestCheckpointerSavesWrittenSeq(t *testing.T) {
+	var saved []uint64
+	c := &checkpointer{base: 10, store: func(values uint64) error {
+		saved = append(saved, values)
+		return nil
+	}}
+	sink := &flushSink{}
+	if err := c.tick(sink); err != nil {
+		t.Fatal(err)
+	}
+	// Значения 1, 2 и 4 отброшены стадиями, 3 и 5 выведены
+	c.wrote(envelope{Seq: 3})
+	c.wrote(envelope{Seq: 5})
+	for range 2 {
+		if err := c.tick(sink); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.finish(pipeline.ChainPosition{Received: 8, Emitted: 2}, sink); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{15, 18}; !slices.Equal(saved, want) {
+		t.Fatalf("сохранено %v, ожидалось %v", saved, want)
+	}
+	if sink.flushes != 2 {
+		t.Fatalf("сбросов приемника %d, ожидалось 2", sink.flushes)
+	}
+}
+
+func TestBuildStagesOrdered(t *testing.T) {
+	for _, tc := range []struct {
+		spec   stageSpec
+		reject bool
+	}{
+		{stageSpec{Name: "buffer", Params: stageParams{"sort": "asc"}}, true},
+		{stageSpec{Name: "buffer", Params: stageParams{"limit": 3}}, true},
+		{stageSpec{Name: "buffer", Params: stageParams{"priority": "x > 5"}}, true},
+		{stageSpec{Name: "map", Params: stageParams{"func": "abs", "workers": 4}}, true},
+		{stageSpec{Name: "map", Params: stageParams{"func": "abs", "workers": 4, "ordered": true}}, false},
+		{stageSpec{Name: "stable", Params: stageParams{"duration": "1s"}}, false},
+		{stageSpec{Name: "buffer", Params: stageParams{"size": 4}}, false},
+	} {
+		specs := []stageSpec{tc.spec}
+		if _, _, err := buildStages(specs, buildOptions{}); err != nil {
+			t.Fatalf("%v без контрольных точек: %v", tc.spec, err)
+		}
+		_, _, err := buildStages(specs, buildOptions{ordered: true})
+		if tc.reject != (err != nil && strings.Contains(err.Error(), "сохранения порядка")) {
+			t.Errorf("%v с контрольными точками: ошибка %v", tc.spec, err)
+		}
+	}
+}
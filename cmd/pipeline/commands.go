@@ -0,0 +1,557 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// command - точка входа подкоманды. Возвращает код завершения процесса.
+type command func(args []string) int
+
+// commands - доступные подкоманды.
+var commands = map[string]command{
+	"run":             runCommand,
+	"validate":        validateCommand,
+	"validate-config": validateCommand,
+	"bench":           benchCommand,
+	"replay":          replayCommand,
+	"stages":          stagesCommand,
+	"grpc":            grpcCommand,
+}
+
+// dispatch - выбор подкоманды по первому аргументу.
+// Без явной подкоманды выполняется run (обратная совместимость).
+func dispatch(args []string) int {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, ok := commands[args[0]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, tr("Неизвестная подкоманда: %s (доступны: %s)")+"\n", args[0], strings.Join(slices.Sorted(maps.Keys(commands)), ", "))
+			return 2
+		}
+		return cmd(args[1:])
+	}
+	return runCommand(args)
+}
+
+// parseConfig - разбор конфигурации подкоманды: значения по умолчанию,
+// переменные окружения, флаги из args и файл конфигурации. Дополнительные
+// флаги подкоманды должны быть зарегистрированы в fs заранее.
+func parseConfig(fs *flag.FlagSet, args []string) (config, error) {
+	cfg := defaultConfig()
+	// Ошибка переменных окружения выводится после разбора флагов на языке -lang
+	envErr := cfg.applyEnv()
+	cfg.registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if err := validateLang(cfg.lang); err != nil {
+		fmt.Fprintln(os.Stderr, tr("Ошибка конфигурации:"), err)
+		return cfg, err
+	}
+	lang = cfg.lang
+	if envErr != nil {
+		fmt.Fprintln(os.Stderr, tr("Ошибка конфигурации:"), envErr)
+		return cfg, envErr
+	}
+	level := cfg.logLevel
+	if cfg.quiet && level == "info" {
+		level = "warn"
+	}
+	logger, err := newLogger(os.Stderr, level, cfg.logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("Ошибка конфигурации:"), err)
+		return cfg, err
+	}
+	slog.SetDefault(logger)
+	if err := cfg.load(); err != nil {
+		slog.Error("Ошибка конфигурации", "err", err)
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// runCommand - запуск пайплайна над вводом из консоли, файлов или сети.
+func runCommand(args []string) int {
+	cfg, err := parseConfig(flag.NewFlagSet("run", flag.ContinueOnError), args)
+	if err != nil {
+		return 2
+	}
+	if len(cfg.pipelines) > 0 {
+		return superviseCommand(cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		slog.Error("Ошибка конфигурации", "err", err)
+		return 2
+	}
+	return runPipeline(cfg)
+}
+
+// runPipeline - работа одного пайплайна с конфигурацией cfg до завершения
+// источника или сигнала. Возвращает код завершения.
+func runPipeline(cfg config) int {
+	var err error
+
+	// Контексты завершения: ctx - стадии пайплайна, srcCtx - источник,
+	// sinkCtx - доставка значений получателю
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	srcCtx, stopSource := context.WithCancel(ctx)
+	defer stopSource()
+	sinkCtx, stopSink := context.WithCancel(ctx)
+	defer stopSink()
+
+	// Обработка прерывания: источник останавливается, стадии дорабатывают
+	// принятые значения в пределах drain-timeout
+	sd := handleSignals(cfg.drainTimeout, stopSource, stopSink, cancel)
+	defer sd.Stop()
+
+	var dl *deadLetter
+	if cfg.deadLetterPath != "" {
+		if dl, err = openDeadLetter(cfg.deadLetterPath); err != nil {
+			stageLog("errors").Error("Ошибка открытия файла недоставленных значений", "err", err)
+			return 1
+		}
+		defer dl.Close()
+	}
+	// Управление во время работы: статистика, приостановка источника, буфер.
+	// Итоги работы выводятся после обработки оставшихся ошибок
+	rc := newRunControl()
+	defer rc.stats.finish(cfg)
+	// Ошибки и отброшенные значения стадий передаются обработчику через контекст
+	stageCtx, errh := startErrorHandler(ctx, cfg, dl, rc.stats, cancel)
+	defer errh.Close()
+
+	if cfg.otlpEndpoint != "" {
+		if rc.tracer, err = newTracer(ctx, cfg); err != nil {
+			stageLog("trace").Error("Ошибка запуска трассировки", "err", err)
+			return 1
+		}
+		defer func() {
+			if err := rc.tracer.Close(); err != nil {
+				stageLog("trace").Warn("Ошибка отправки трасс", "err", err)
+			}
+		}()
+		stageLog("trace").Info("Трассы экспортируются по OTLP", "endpoint", cfg.otlpEndpoint, "mode", cfg.traceMode, "sample", cfg.traceSample)
+	}
+
+	cp, err := newCheckpointer(cfg)
+	if err != nil {
+		stageLog("checkpoint").Error("Ошибка чтения контрольной точки", "err", err)
+		return 1
+	}
+
+	input := make(chan envelope)
+	srcErr := make(chan error, 1) // Ошибка источника (до закрытия input)
+	if err := startSource(srcCtx, cfg, cp, dl, rc, input, srcErr); err != nil {
+		stageLog("source").Error("Ошибка запуска источника данных", "err", err)
+		return 1
+	}
+
+	p, err := startPipeline(stageCtx, input, cfg, rc)
+	if err != nil {
+		slog.Error("Ошибка запуска пайплайна", "err", err)
+		return 1
+	}
+	if cfg.idleTimeout > 0 {
+		go watchIdle(srcCtx, cfg.idleTimeout, cfg.idleExit, rc, stopSource)
+	}
+	if cp != nil {
+		cp.start(cfg.checkpointEvery)
+		defer cp.stop()
+	}
+	if cfg.configPath != "" {
+		go watchReload(ctx, cfg, p.chain, rc.buffer)
+	}
+	if cfg.control != "" {
+		srv, err := listenControl(cfg.control, rc.cmds)
+		if err != nil {
+			stageLog("control").Error("Ошибка открытия управляющего сокета", "err", err)
+			return 1
+		}
+		defer srv.Close()
+		registerControlCommands(srv, p)
+		go srv.serve()
+	}
+
+	var stream *broadcaster // Рассылка значений клиентам /stream (nil без HTTP-сервера)
+	if cfg.httpAddr != "" {
+		stream = newBroadcaster()
+		defer stream.Close()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", p.metrics)
+		mux.Handle("/stream", stream)
+		registerAPI(mux, rc)
+		registerHealth(mux, p.metrics, rc, sd)
+		addr, err := startHTTP(ctx, cfg.httpAddr, mux)
+		if err != nil {
+			stageLog("http").Error("Ошибка запуска HTTP-сервера", "err", err)
+			return 1
+		}
+		stageLog("http").Info("HTTP-сервер запущен", "addr", addr.String())
+	}
+	if cfg.pprofAddr != "" {
+		mux := http.NewServeMux()
+		registerDebug(mux, p, rc)
+		addr, err := startHTTP(ctx, cfg.pprofAddr, mux)
+		if err != nil {
+			stageLog("debug").Error("Ошибка запуска сервера диагностики", "err", err)
+			return 1
+		}
+		stageLog("debug").Info("Сервер диагностики запущен", "addr", addr.String())
+	}
+
+	if cfg.tui {
+		if p.dashboard, err = startDashboard(cfg, p, rc, sd); err != nil {
+			slog.Error("Ошибка запуска панели", "err", err)
+			return 1
+		}
+		defer p.dashboard.Close()
+	}
+
+	// Приемники данных: консоль, файл, сетевой получатель, топик Kafka и Redis
+	sink, closeSinks, err := openSinks(sinkCtx, cfg, p, dl)
+	if err != nil {
+		stageLog("sink").Error("Ошибка открытия приемника", "err", err)
+		return 1
+	}
+	defer closeSinks()
+	defer sink.Flush()
+
+	// Вывод обработанных данных
+	undelivered := 0 // Значения, не выведенные после истечения drain-timeout
+	for {
+		select {
+		case num, ok := <-p.out:
+			if !ok {
+				// Источник исчерпан или остановлен, пайплайн завершен
+				if cp != nil && undelivered == 0 {
+					if err := cp.finish(p.chain.Position(), sink); err != nil {
+						stageLog("checkpoint").Error("Ошибка контрольной точки", "err", err)
+					}
+				}
+				return finishRun(cfg, p, sd, undelivered, srcErr)
+			}
+			if sd.expired.Load() {
+				undelivered++
+				p.acks.skip()
+				continue
+			}
+			written := time.Now()
+			if err := p.acks.write(sink, num); err != nil {
+				if errors.Is(err, errBatchRetry) {
+					continue
+				}
+				if sd.expired.Load() {
+					undelivered++
+					continue
+				}
+				if ctx.Err() == nil {
+					stageLog("sink").Error("Ошибка вывода данных", "err", err)
+					return 1
+				}
+				continue
+			}
+			rc.tracer.written(num, written, time.Now())
+			rc.stats.emit(num)
+			stream.publish(num.Value)
+			cp.wrote(num)
+		case <-cp.next():
+			if err := cp.tick(sink); err != nil {
+				stageLog("checkpoint").Error("Ошибка контрольной точки", "err", err)
+			}
+		case <-ctx.Done():
+			cause := context.Cause(ctx)
+			if errors.Is(cause, errFailFast) {
+				slog.Error("Программа завершена", "err", cause)
+			} else {
+				slog.Warn("Программа завершена без дообработки значений")
+			}
+			return 1
+		}
+	}
+}
+
+// finishRun - завершение run после закрытия выхода пайплайна: отчет о
+// недоставленных значениях и ошибке источника. Возвращает код завершения
+// (1 при потере значений или ошибке источника).
+func finishRun(cfg config, p runningPipeline, sd *shutdown, undelivered int, srcErr <-chan error) int {
+	if sd.requested.Load() {
+		slog.Info("Программа завершена по запросу пользователя")
+		p.printLatency()
+		if undelivered > 0 {
+			slog.Warn("Значения не доставлены за время дообработки", "count", undelivered, "drain_timeout", cfg.drainTimeout)
+			return 1
+		}
+		return 0
+	}
+	select {
+	case err := <-srcErr:
+		stageLog("source").Error("Ошибка чтения входных данных", "err", err)
+		return 1
+	default:
+		// Оставшееся в приемниках записывается отложенным Flush и закрытием
+		slog.Info("Конец ввода: все значения обработаны, программа завершена")
+		return 0
+	}
+}
+
+// validateCommand - проверка конфигурации без запуска пайплайна.
+func validateCommand(args []string) int {
+	cfg, err := parseConfig(flag.NewFlagSet("validate", flag.ContinueOnError), args)
+	if err != nil {
+		return 2
+	}
+	if len(cfg.pipelines) > 0 {
+		_, err = pipelineConfigs(cfg)
+	} else {
+		err = cfg.validate()
+	}
+	if err != nil {
+		slog.Error("Ошибка конфигурации", "err", err)
+		return 1
+	}
+	fmt.Println(tr("Конфигурация корректна."))
+	return 0
+}
+
+// replayCommand - воспроизведение журнала сеанса: replay [флаги] журнал
+// равносильно run -replay журнал.
+func replayCommand(args []string) int {
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return 2
+	}
+	switch {
+	case fs.NArg() > 1, path != "" && fs.NArg() > 0:
+		err = fmt.Errorf("ожидается один журнал сеанса: replay [флаги] журнал")
+	case path == "" && fs.NArg() == 1:
+		path = fs.Arg(0)
+	}
+	if path != "" {
+		cfg.replayPath = path
+	}
+	if err == nil && cfg.replayPath == "" {
+		err = fmt.Errorf("не задан журнал сеанса: replay [флаги] журнал")
+	}
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		slog.Error("Ошибка конфигурации", "err", err)
+		return 2
+	}
+	return runPipeline(cfg)
+}
+
+// stagesCommand - сведения о стадиях: stages list выводит зарегистрированные
+// стадии, их параметры и описание.
+func stagesCommand(args []string) int {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Fprintln(os.Stderr, tr("Использование: stages list [флаги]"))
+		return 2
+	}
+	// Флаги - ради -lang
+	if _, err := parseConfig(flag.NewFlagSet("stages list", flag.ContinueOnError), args[1:]); err != nil {
+		return 2
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, tr("Стадия\tПараметры\tОписание"))
+	for _, name := range stageNames() {
+		d := stageRegistry[name]
+		params := d.params
+		if d.item != nil {
+			params = append(append(slices.Clone(params), parallelParams...), timeoutParam)
+		}
+		list := "-"
+		if len(params) > 0 {
+			list = strings.Join(params, ", ")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, list, tr(d.doc))
+	}
+	w.Flush()
+	fmt.Printf(tr("Любой стадии можно задать параметры %s (емкость очереди на входе) и %s (перезапуск после паники: never, on-failure или always).")+"\n", queueParam, restartParam)
+	return 0
+}
+
+// registerGeneratorFlags - регистрация флагов генератора g в наборе fs.
+func registerGeneratorFlags(fs *flag.FlagSet, g *pipeline.GeneratorSource) {
+	fs.IntVar(&g.Rate, "gen-rate", g.Rate, "количество значений в секунду (0 - без ограничения)")
+	fs.IntVar(&g.Count, "gen-count", g.Count, "количество генерируемых значений (0 - без ограничения)")
+	fs.DurationVar(&g.Duration, "gen-duration", g.Duration, "длительность генерации (0 - без ограничения)")
+	fs.StringVar(&g.Mode, "gen-mode", g.Mode, "режим генерации: seq или random")
+}
+
+// benchCommand - прогон синтетической нагрузки через пайплайн
+// с выводом пропускной способности.
+func benchCommand(args []string) int {
+	gen := pipeline.GeneratorSource{Count: 100000, Mode: pipeline.GenModeSeq}
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	registerGeneratorFlags(fs, &gen)
+	rings := fs.Bool("ring-bench", false, "сравнить реализации кольцевого буфера (mutex и spsc) на -gen-count значениях вместо прогона пайплайна")
+	perStage := fs.Bool("per-stage", false, "прогнать нагрузку через каждую стадию по отдельности")
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return 2
+	}
+	if err := cfg.validate(); err != nil {
+		slog.Error("Ошибка конфигурации", "err", err)
+		return 2
+	}
+	if err := gen.Validate(); err != nil {
+		slog.Error("Ошибка конфигурации генератора", "err", err)
+		return 2
+	}
+
+	if *rings {
+		benchRings(gen.Count, cfg.bufferSize)
+		return 0
+	}
+	if *perStage {
+		if err := benchStages(cfg, gen); err != nil {
+			slog.Error("Ошибка запуска пайплайна", "err", err)
+			return 1
+		}
+		return 0
+	}
+
+	ctx := context.Background()
+	input := make(chan pipeline.Num)
+	p, err := startPipeline(ctx, tagSource(ctx, input, "generator"), cfg, nil)
+	if err != nil {
+		slog.Error("Ошибка запуска пайплайна", "err", err)
+		return 1
+	}
+
+	r := runBench(ctx, gen, input, p.out)
+	fmt.Printf(tr("Отправлено: %d, получено: %d, время: %s\n"), r.sent, r.received, r.elapsed)
+	fmt.Printf(tr("Пропускная способность: вход %.0f значений/с, выход %.0f значений/с\n"), r.inRate(), r.outRate())
+	fmt.Printf(tr("Память: %d выделений (%.1f на значение), %d байт (%.0f на значение), сборок мусора: %d\n"),
+		r.allocs, r.perItem(r.allocs), r.bytes, r.perItem(r.bytes), r.gcs)
+	p.printLatency()
+	return 0
+}
+
+// benchResult - результат прогона нагрузки.
+type benchResult struct {
+	sent, received int
+	elapsed        time.Duration
+	allocs, bytes  uint64 // Выделения памяти и их объем за прогон
+	gcs            uint32 // Сборки мусора за прогон
+}
+
+// inRate - значений на входе в секунду.
+func (r benchResult) inRate() float64 { return float64(r.sent) / r.elapsed.Seconds() }
+
+// outRate - значений на выходе в секунду.
+func (r benchResult) outRate() float64 { return float64(r.received) / r.elapsed.Seconds() }
+
+// perItem - величина v в расчете на одно входное значение.
+func (r benchResult) perItem(v uint64) float64 {
+	if r.sent == 0 {
+		return 0
+	}
+	return float64(v) / float64(r.sent)
+}
+
+// runBench - передача значений генератора gen в input до исчерпания
+// и чтение out до закрытия с замером времени и выделений памяти.
+func runBench(ctx context.Context, gen pipeline.GeneratorSource, input chan<- pipeline.Num, out <-chan envelope) benchResult {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	sentCh := make(chan int, 1)
+	go func() {
+		// Закрытие входа: стадии дорабатывают принятые значения и завершаются
+		defer close(input)
+		sentCh <- gen.Run(ctx, input)
+	}()
+	var r benchResult
+	for range out {
+		r.received++
+	}
+	r.elapsed = time.Since(start)
+	r.sent = <-sentCh
+
+	runtime.ReadMemStats(&after)
+	r.allocs = after.Mallocs - before.Mallocs
+	r.bytes = after.TotalAlloc - before.TotalAlloc
+	r.gcs = after.NumGC - before.NumGC
+	return r
+}
+
+// benchStages - прогон нагрузки через каждую стадию конфигурации по отдельности.
+func benchStages(cfg config, gen pipeline.GeneratorSource) error {
+	ctx := context.Background()
+	fmt.Printf("%-3s %-16s %14s %14s %12s %12s\n", "#", tr("стадия"), tr("вход, зн/с"), tr("выход, зн/с"), tr("выд./зн"), tr("байт/зн"))
+	for i, spec := range cfg.stageSpecs() {
+		stages, _, err := buildStages([]stageSpec{spec}, buildOptions{})
+		if err != nil {
+			return err
+		}
+		input := make(chan pipeline.Num)
+		items := pipeline.Wrap(ctx, input, "generator", nil, pipeline.RealClock{})
+		r := runBench(ctx, gen, input, pipeline.ChainCap(ctx, items, cfg.chanCap, stages...))
+		fmt.Printf("%-3d %-16s %14.0f %14.0f %12.1f %12.0f\n",
+			i+1, spec.Name, r.inRate(), r.outRate(), r.perItem(r.allocs), r.perItem(r.bytes))
+	}
+	return nil
+}
+
+// benchRings - сравнение реализаций кольцевого буфера емкости size: писатель
+// и читатель в разных горутинах передают n значений.
+func benchRings(n, size int) {
+	if n <= 0 {
+		n = 1000000
+	}
+	run := func(name string, push func(v int), flush func() int) {
+		start := time.Now()
+		go func() {
+			for i := range n {
+				push(i)
+			}
+		}()
+		for received := 0; received < n; {
+			got := flush()
+			if got == 0 {
+				runtime.Gosched()
+			}
+			received += got
+		}
+		elapsed := time.Since(start)
+		fmt.Printf(tr("%-6s %d значений за %s: %.0f значений/с\n"), name, n, elapsed, float64(n)/elapsed.Seconds())
+	}
+
+	rb := pipeline.NewRingBufferPolicy[int](size, pipeline.OverflowBlock)
+	run(pipeline.RingMutex, rb.Push, func() int { return len(rb.Flush()) })
+
+	spsc := pipeline.NewSPSCRing[int](size)
+	var batch []int
+	run(pipeline.RingSPSC, func(v int) {
+		for !spsc.Push(v) {
+			runtime.Gosched()
+		}
+	}, func() int {
+		batch = spsc.PopAll(batch[:0])
+		return len(batch)
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubCommands - замена подкоманд заглушками, записывающими вызовы в calls.
+func stubCommands(t *testing.T, calls *[]string) {
+	saved := commands
+	t.Cleanup(func() { commands = saved })
+	commands = make(map[string]command, len(saved))
+	for name := range saved {
+		commands[name] = func(args []string) int {
+			*calls = append(*calls, strings.Join(append([]string{name}, args...), " "))
+			return 0
+		}
+	}
+}
+
+func TestDispatchSelectsCommand(t *testing.T) {
+	var calls []string
+	stubCommands(t, &calls)
+	for _, args := range [][]string{
+		{"validate", "-buffer-size", "5"},
+		{"bench", "-gen-count", "10"},
+		{"stages", "list"},
+	} {
+		if code := dispatch(args); code != 0 {
+			t.Fatalf("dispatch(%v) = %d", args, code)
+		}
+	}
+	want := []string{"validate -buffer-size 5", "bench -gen-count 10", "stages list"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("вызовы %q, ожидалось %q", calls, want)
+	}
+}
+
+func TestDispatchUnknownCommand(t *testing.T) {
+	var calls []string
+	stubCommands(t, &calls)
+	if code := dispatch([]string{"frobnicate"}); code != 2 {
+		t.Fatalf("код завершения %d, ожидалось 2", code)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("для неизвестной подкоманды вызваны %q", calls)
+	}
+}
+
+func TestRunIsDefault(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "in.txt")
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(input, []byte("3\n-1\n6\n7\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Флаг первым аргументом - подкоманда run
+	if code := dispatch([]string{"-input", input, "-output", output, "-quiet", "-flush-interval", "10ms"}); code != 0 {
+		t.Fatalf("код завершения run %d, ожидалось 0", code)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "3\n6\n" {
+		t.Fatalf("вывод %q, ожидалось фильтрованные 3 и 6", data)
+	}
+}
+
+// TestRunEndOfInput - конец stdin завершает run с кодом 0 сразу: остаток
+// буфера записывается без ожидания -flush-interval.
+func TestRunEndOfInput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := w.WriteString("3\n-1\n6\n9\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() { os.Stdin = stdin })
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	done := make(chan int, 1)
+	go func() {
+		done <- dispatch([]string{"run", "-output", output, "-quiet", "-buffer-size", "100", "-flush-interval", "1h"})
+	}()
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Fatalf("код завершения run %d, ожидалось 0", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run не завершилась по концу ввода")
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "3\n6\n9\n" {
+		t.Fatalf("вывод %q, ожидалось 3, 6 и 9", data)
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	if code := dispatch([]string{"validate", "-buffer-size", "5"}); code != 0 {
+		t.Fatalf("код завершения validate %d, ожидалось 0", code)
+	}
+	if code := dispatch([]string{"validate", "-buffer-size", "-1"}); code == 0 {
+		t.Fatal("validate с отрицательным buffer-size завершилась с кодом 0")
+	}
+	if code := dispatch([]string{"validate", "-no-such-flag"}); code != 2 {
+		t.Fatalf("код завершения validate с неизвестным флагом %d, ожидалось 2", code)
+	}
+}
+
+func TestBenchCommand(t *testing.T) {
+	if code := dispatch([]string{"bench", "-gen-count", "100", "-quiet"}); code != 0 {
+		t.Fatalf("код завершения bench %d, ожидалось 0", code)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig - декларативное описание пайплайна в файле конфигурации:
+// стадии одного пайплайна или несколько независимых пайплайнов.
+type fileConfig struct {
+	Stages    []stageSpec    `json:"stages" yaml:"stages"`
+	Pipelines []pipelineSpec `json:"pipelines,omitempty" yaml:"pipelines,omitempty"`
+}
+
+// pipelineSpec - независимый пайплайн в файле конфигурации: собственные
+// флаги (источник, фильтры, приемник), стадии и политика перезапуска.
+type pipelineSpec struct {
+	Name    string      `json:"name" yaml:"name"`
+	Args    []string    `json:"args,omitempty" yaml:"args,omitempty"`       // Флаги пайплайна, например ["-input", "a.txt"]
+	Stages  []stageSpec `json:"stages,omitempty" yaml:"stages,omitempty"`   // Стадии (пусто - по флагам)
+	Restart string      `json:"restart,omitempty" yaml:"restart,omitempty"` // Политика перезапуска (пусто - on-failure)
+}
+
+// loadConfigFile - чтение конфигурации из YAML- или JSON-файла
+// (формат определяется по расширению).
+func loadConfigFile(path string) (fileConfig, error) {
+	var fc fileConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fc, err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&fc)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&fc)
+	default:
+		return fc, fmt.Errorf("%s: неподдерживаемый формат %q (ожидается .yaml, .yml или .json)", path, ext)
+	}
+	if err != nil {
+		return fc, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch {
+	case len(fc.Stages) > 0 && len(fc.Pipelines) > 0:
+		return fc, fmt.Errorf("%s: stages и pipelines взаимоисключающие", path)
+	case len(fc.Pipelines) > 0:
+		names := make(map[string]bool)
+		for i, p := range fc.Pipelines {
+			if p.Name == "" {
+				return fc, fmt.Errorf("%s: пайплайн #%d: не задано имя", path, i+1)
+			}
+			if names[p.Name] {
+				return fc, fmt.Errorf("%s: пайплайн %s задан повторно", path, p.Name)
+			}
+			names[p.Name] = true
+			if err := checkStageNames(p.Stages); err != nil {
+				return fc, fmt.Errorf("%s: пайплайн %s: %w", path, p.Name, err)
+			}
+		}
+		return fc, nil
+	case len(fc.Stages) == 0:
+		return fc, fmt.Errorf("%s: не задано ни одной стадии", path)
+	}
+	if err := checkStageNames(fc.Stages); err != nil {
+		return fc, fmt.Errorf("%s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// checkStageNames - проверка, что у всех стадий задано имя.
+func checkStageNames(specs []stageSpec) error {
+	for i, spec := range specs {
+		if spec.Name == "" {
+			return fmt.Errorf("стадия #%d: не задано имя", i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Режимы цветного вывода в консоль.
+const (
+	colorAuto   = "auto"   // Если stdout - терминал и не задана переменная NO_COLOR
+	colorAlways = "always" // Всегда
+	colorNever  = "never"  // Никогда
+)
+
+// Управляющие последовательности ANSI для категорий значений.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiNegative  = "\x1b[31m"   // Красный: отрицательные значения
+	ansiHighlight = "\x1b[1;33m" // Жирный желтый: значения не меньше -highlight
+)
+
+// outputLine - данные шаблона строки вывода -output-template.
+type outputLine struct {
+	Value      pipeline.Num
+	Time       time.Time // Момент вывода
+	Seq        uint64    // Порядковый номер на входе
+	Source     string    // Источник значения
+	IngestedAt time.Time // Момент поступления в пайплайн
+	Latency    time.Duration
+	Batch      uint64            // Номер партии буфера (0 - неизвестен)
+	Anomaly    *pipeline.Anomaly // Отклонение, найденное стадией anomaly (nil - нет)
+}
+
+// parseOutputTemplate - разбор шаблона строки вывода (пусто - строка
+// "Получены данные: <число>" на языке -lang, для аномальных значений -
+// с отклонением).
+func parseOutputTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = tr("Получены данные: ") + "{{.Value}}{{with .Anomaly}}" + tr(" (аномалия: ") + `{{printf "%+.1f" .Score}}σ){{end}}`
+	}
+	t, err := template.New("output").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("output-template: %w", err)
+	}
+	return t, nil
+}
+
+// palette - раскраска значений по категориям (выключена - нулевое значение).
+type palette struct {
+	enabled   bool
+	highlight *pipeline.Num // Порог выделения больших значений (nil - не выделяются)
+}
+
+// newPalette - раскраска согласно -color и -highlight (проверены в validate).
+func newPalette(cfg config) palette {
+	p := palette{}
+	switch cfg.color {
+	case colorAlways:
+		p.enabled = true
+		enableANSI(os.Stdout)
+	case colorAuto:
+		p.enabled = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && enableANSI(os.Stdout)
+	}
+	if cfg.highlight != "" {
+		n, _ := pipeline.ParseNum(cfg.highlight)
+		p.highlight = &n
+	}
+	return p
+}
+
+// paint - текст s, раскрашенный по категории значения n: отрицательные
+// (обычно отбрасываемые фильтром negative) - красным, не меньше порога
+// выделения - жирным желтым.
+func (p palette) paint(n pipeline.Num, s string) string {
+	if !p.enabled {
+		return s
+	}
+	switch {
+	case n.Sign() < 0:
+		return ansiNegative + s + ansiReset
+	case p.highlight != nil && n.Cmp(*p.highlight) >= 0:
+		return ansiHighlight + s + ansiReset
+	}
+	return s
+}
+
+// isTerminal - является ли f терминалом.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// validateConsole - проверка флагов вывода в консоль.
+func (c config) validateConsole() error {
+	switch c.color {
+	case colorAuto, colorAlways, colorNever:
+	default:
+		return fmt.Errorf("неизвестный режим color: %q (ожидается %s, %s или %s)", c.color, colorAuto, colorAlways, colorNever)
+	}
+	if c.highlight != "" {
+		if _, err := pipeline.ParseNum(c.highlight); err != nil {
+			return fmt.Errorf("highlight: %w", err)
+		}
+	}
+	if _, err := parseOutputTemplate(c.outputTemplate); err != nil {
+		return err
+	}
+	if c.outputTemplate != "" && (c.format == formatJSONL || c.batchOutput) {
+		return fmt.Errorf("флаг output-template не поддерживается с -format jsonl и -batch-output")
+	}
+	return nil
+}
+
+// textSink - вывод значений строками по шаблону с раскраской.
+type textSink struct {
+	w       io.Writer
+	tmpl    *template.Template
+	colors  palette
+	batches *batchTracker // nil - номера партий не выводятся
+	buf     bytes.Buffer
+}
+
+// Write - вывод значения без метаданных.
+func (s *textSink) Write(n pipeline.Num) error {
+	return s.WriteItem(envelope{Value: n})
+}
+
+// WriteItem - вывод строки шаблона для значения it.
+func (s *textSink) WriteItem(it envelope) error {
+	line := outputLine{Value: it.Value, Time: time.Now(), Seq: it.Seq, Source: it.Source, IngestedAt: it.IngestedAt, Anomaly: it.Anomaly}
+	if !it.IngestedAt.IsZero() {
+		line.Latency = it.Latency(line.Time)
+	}
+	if s.batches != nil {
+		if id, _, ok := s.batches.next(); ok {
+			line.Batch = id
+		}
+	}
+	s.buf.Reset()
+	if err := s.tmpl.Execute(&s.buf, line); err != nil {
+		return err
+	}
+	_, err := io.WriteString(s.w, s.colors.paint(it.Value, s.buf.String())+"\n")
+	return err
+}
+
+// Flush - ничего не делает: строки выводятся сразу.
+func (s *textSink) Flush() error { return nil }
+
+// consoleSink - вывод обработанных данных в консоль в формате -format,
+// при -batch-output - партиями буфера.
+func consoleSink(cfg config, batches *batchTracker) pipeline.Sink[pipeline.Num] {
+	colors := newPalette(cfg)
+	switch {
+	case cfg.batchOutput:
+		var bs pipeline.BatchSink[pipeline.Num] = textBatchSink{w: os.Stdout, colors: colors}
+		if cfg.format == formatJSONL {
+			bs = newJSONLBatchSink(os.Stdout)
+		} else if !cfg.quiet {
+			fmt.Println(tr("Обработанные данные:"))
+		}
+		return &batchWriter{sink: bs, batches: batches}
+	case cfg.format == formatJSONL:
+		return newJSONLSink(os.Stdout, batches)
+	}
+	if cfg.outputTemplate == "" && !cfg.quiet {
+		fmt.Println(tr("Обработанные данные:"))
+	}
+	tmpl, _ := parseOutputTemplate(cfg.outputTemplate) // Проверен в validate
+	return &textSink{w: os.Stdout, tmpl: tmpl, colors: colors, batches: batches}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// controlHandler - обработчик команды управляющего сокета.
+// Получает аргументы команды и возвращает текст ответа.
+type controlHandler func(args string) (string, error)
+
+// commandSet - набор команд управления: имя команды и обработчик.
+type commandSet struct {
+	mu       sync.Mutex
+	handlers map[string]controlHandler
+}
+
+// newCommandSet - пустой набор команд.
+func newCommandSet() *commandSet {
+	return &commandSet{handlers: make(map[string]controlHandler)}
+}
+
+// controlServer - управляющий Unix-сокет: принимает текстовые команды
+// построчно и отвечает строкой "OK ..." или "ERROR ...".
+type controlServer struct {
+	*commandSet
+	ln net.Listener
+}
+
+// listenControl - открытие управляющего сокета по пути path.
+// Команды выполняются из набора cmds.
+func listenControl(path string, cmds *commandSet) (*controlServer, error) {
+	// Удаление сокета, оставшегося от предыдущего запуска
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &controlServer{commandSet: cmds, ln: ln}, nil
+}
+
+// handle - регистрация обработчика команды name.
+func (s *commandSet) handle(name string, h controlHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[name] = h
+}
+
+// serve - прием подключений до закрытия сокета.
+func (s *controlServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				stageLog("control").Error("Ошибка управляющего сокета", "err", err)
+			}
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn - обработка команд одного подключения.
+func (s *controlServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		reply, err := s.exec(line)
+		if err != nil {
+			fmt.Fprintf(conn, "ERROR %v\n", err)
+		} else {
+			fmt.Fprintf(conn, "OK %s\n", reply)
+		}
+	}
+}
+
+// exec - выполнение одной команды.
+func (s *commandSet) exec(line string) (string, error) {
+	name, args, _ := strings.Cut(line, " ")
+	s.mu.Lock()
+	h, ok := s.handlers[name]
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("неизвестная команда %q (доступны: %s)", name, strings.Join(s.commandNames(), ", "))
+	}
+	return h(strings.TrimSpace(args))
+}
+
+// commandNames - отсортированный список зарегистрированных команд.
+func (s *commandSet) commandNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.handlers))
+	for name := range s.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close - закрытие сокета (файл сокета удаляется автоматически).
+func (s *controlServer) Close() error {
+	return s.ln.Close()
+}
+
+// registerControlCommands - регистрация команд управления пайплайном p.
+func registerControlCommands(s *controlServer, p runningPipeline) {
+	s.handle("stages", func(string) (string, error) {
+		return strings.Join(p.chain.Names(), ","), nil
+	})
+	s.handle("reload-stages", func(args string) (string, error) {
+		names := parseStageList(args)
+		if len(names) == 0 {
+			return "", errors.New("не задан список стадий")
+		}
+		if err := p.chain.ReloadNames(names); err != nil {
+			return "", err
+		}
+		return "активные стадии: " + strings.Join(names, ","), nil
+	})
+}
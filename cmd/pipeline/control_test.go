@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+func TestReloadStagesMidStream(t *testing.T) {
+	const total = 3000
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	chain, err := newNamedChain([]stageSpec{{Name: "filter_negative"}}, 4, nil, buildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &controlServer{commandSet: newCommandSet()}
+	registerControlCommands(s, runningPipeline{chain: chain})
+
+	in := make(chan envelope)
+	out := make(chan envelope)
+	go chain.Run(ctx, in, out)
+	go func() {
+		defer close(in)
+		for i := 1; i <= total; i++ {
+			in <- envelope{Value: pipeline.IntNum(int64(i)), Seq: uint64(i)}
+		}
+	}()
+
+	var got []int64
+	reply := make(chan error, 1)
+	for it := range out {
+		v, _ := it.Value.Int64()
+		got = append(got, v)
+		if len(got) == total/3 {
+			go func() {
+				_, err := s.exec("reload-stages filter_div3")
+				reply <- err
+			}()
+		}
+	}
+	if err := <-reply; err != nil {
+		t.Fatalf("reload-stages: %v", err)
+	}
+	if names, _ := s.exec("stages"); names != "filter_div3" {
+		t.Fatalf("активные стадии %q, ожидалось filter_div3", names)
+	}
+
+	// До переключения проходят все значения, после - только кратные 3
+	var last int64 // Последнее значение старой цепочки
+	for _, v := range got {
+		if v%3 != 0 {
+			last = v
+		}
+	}
+	if last == 0 || last == total {
+		t.Fatalf("новая цепочка не применена: последнее значение старой цепочки %d", last)
+	}
+	want := last
+	for i := last + 1; i <= total; i++ {
+		if i%3 == 0 {
+			want++
+		}
+	}
+	if int64(len(got)) != want {
+		t.Fatalf("на выходе %d значений, ожидалось %d: значения потеряны при замене", len(got), want)
+	}
+	for i, v := range got[:last] {
+		if v != int64(i+1) {
+			t.Fatalf("значение %d: %d, ожидалось %d", i, v, i+1)
+		}
+	}
+	for _, v := range got[last:] {
+		if v%3 != 0 {
+			t.Fatalf("значение %d прошло после переключения на filter_div3", v)
+		}
+	}
+}
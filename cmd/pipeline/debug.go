@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Ограничения страницы /debug/pipeline.
+const (
+	debugBufferValues = 100             // Наибольшее количество выводимых значений буфера
+	debugBufferWait   = 2 * time.Second // Ожидание ответа стадии buffer
+)
+
+// Метки профилировщика горутин стадий (см. labelStage).
+const (
+	stageLabel      = "stage"
+	stageIndexLabel = "stage_index"
+)
+
+// labelStage - стадия index с метками профилировщика: горутины стадии,
+// в том числе запущенные ею, помечаются ее именем и номером. По меткам
+// /debug/pipeline и профили /debug/pprof сопоставляют горутины стадиям.
+func labelStage(index int, name string, stage pipeline.Stage[envelope]) pipeline.Stage[envelope] {
+	labels := rpprof.Labels(stageLabel, name, stageIndexLabel, strconv.Itoa(index))
+	return func(ctx context.Context, in <-chan envelope, out chan<- envelope) {
+		rpprof.Do(ctx, labels, func(ctx context.Context) {
+			stage(ctx, in, out)
+		})
+	}
+}
+
+// debugPage - ответ /debug/pipeline.
+type debugPage struct {
+	Goroutines int          `json:"goroutines"` // Все горутины процесса
+	Stages     []debugStage `json:"stages"`
+	Output     debugChannel `json:"output"` // Выход пайплайна перед приемниками
+	Buffer     debugBuffer  `json:"buffer"` // Содержимое последнего буфера
+}
+
+// debugStage - состояние стадии: счетчики, очередь на входе и горутины.
+type debugStage struct {
+	Index      int              `json:"index"`
+	Name       string           `json:"name"`
+	Running    bool             `json:"running"`
+	Failed     bool             `json:"failed"`
+	Received   uint64           `json:"received"`
+	Passed     uint64           `json:"passed"`
+	Queue      *debugChannel    `json:"queue,omitempty"` // nil - без очереди
+	Goroutines []debugGoroutine `json:"goroutines"`
+}
+
+// debugGoroutine - горутины стадии с одинаковым стеком.
+type debugGoroutine struct {
+	Count int    `json:"count"`
+	At    string `json:"at"` // Функция, в которой ожидает горутина, и позиция в ней
+}
+
+// debugChannel - заполненность канала или очереди.
+type debugChannel struct {
+	Len int `json:"len"`
+	Cap int `json:"cap"`
+}
+
+// debugBuffer - снимок содержимого буфера.
+type debugBuffer struct {
+	Values []debugValue `json:"values"`
+	Error  string       `json:"error,omitempty"` // Буфер не ответил (например, занят отправкой партии)
+}
+
+// debugValue - значение в буфере с метаданными.
+type debugValue struct {
+	Value      pipeline.Num `json:"value"`
+	Seq        uint64       `json:"seq"`
+	Source     string       `json:"source"`
+	IngestedAt time.Time    `json:"ingested_at"`
+}
+
+// registerDebug - регистрация в mux профилей net/http/pprof (/debug/pprof/)
+// и страницы /debug/pipeline с состоянием стадий запущенного пайплайна p.
+func registerDebug(mux *http.ServeMux, p runningPipeline, rc *runControl) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/pipeline", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, debugState(r.Context(), p, rc))
+	})
+}
+
+// debugState - состояние пайплайна p для /debug/pipeline.
+func debugState(ctx context.Context, p runningPipeline, rc *runControl) debugPage {
+	page := debugPage{
+		Goroutines: runtime.NumGoroutine(),
+		Stages:     []debugStage{},
+		Output:     debugChannel{Len: len(p.out), Cap: cap(p.out)},
+	}
+	groups := stageGoroutines()
+	for _, sm := range p.metrics.stageList() {
+		st := debugStage{
+			Index:      sm.index,
+			Name:       sm.name,
+			Running:    sm.running.Load(),
+			Failed:     sm.failed.Load(),
+			Received:   sm.stage.Received.Load(),
+			Passed:     sm.stage.Passed.Load(),
+			Goroutines: groups[sm.index],
+		}
+		if sm.queue != nil {
+			st.Queue = &debugChannel{Len: sm.queue.Depth(), Cap: sm.queue.Cap}
+		}
+		if st.Goroutines == nil {
+			st.Goroutines = []debugGoroutine{}
+		}
+		page.Stages = append(page.Stages, st)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, debugBufferWait)
+	defer cancel()
+	page.Buffer.Values = []debugValue{}
+	values, err := pipeline.BufferSnapshot[envelope](ctx, rc.buffer, debugBufferValues)
+	if err != nil {
+		page.Buffer.Error = "буфер не ответил за " + debugBufferWait.String() + ": " + err.Error()
+	}
+	for _, it := range values {
+		page.Buffer.Values = append(page.Buffer.Values, debugValue{Value: it.Value, Seq: it.Seq, Source: it.Source, IngestedAt: it.IngestedAt})
+	}
+	return page
+}
+
+// stageGoroutines - горутины стадий по номерам из профиля горутин (метки
+// labelStage): группы с одинаковым стеком и место ожидания каждой группы
+// (кадры среды выполнения в профиле опущены).
+func stageGoroutines() map[int][]debugGoroutine {
+	var buf bytes.Buffer
+	rpprof.Lookup("goroutine").WriteTo(&buf, 1)
+	groups := make(map[int][]debugGoroutine)
+
+	// Записи профиля: "N @ адреса", "# labels: {...}" и строки стека "#\tадрес\tфункция\tфайл:строка"
+	var g debugGoroutine
+	index := 0
+	flush := func() {
+		if index > 0 && g.Count > 0 {
+			groups[index] = append(groups[index], g)
+		}
+		g, index = debugGoroutine{}, 0
+	}
+	sc := bufio.NewScanner(&buf)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			flush()
+		case strings.Contains(line, " @ "):
+			g.Count, _ = strconv.Atoi(line[:strings.Index(line, " ")])
+		case strings.HasPrefix(line, "# labels: "):
+			var labels map[string]string
+			if json.Unmarshal([]byte(strings.TrimPrefix(line, "# labels: ")), &labels) == nil {
+				index, _ = strconv.Atoi(labels[stageIndexLabel])
+			}
+		case strings.HasPrefix(line, "#\t") && g.At == "":
+			fields := strings.Fields(line[2:])
+			if len(fields) < 3 {
+				continue
+			}
+			fn, _, _ := strings.Cut(fields[1], "+0x")
+			g.At = fn + " " + fields[2]
+		}
+	}
+	flush()
+	return groups
+}
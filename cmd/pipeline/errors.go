@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Обработка ошибок стадий.
+const (
+	onErrorLog        = "log"         // Вывод в журнал
+	onErrorDrop       = "drop"        // Отбрасывание без вывода
+	onErrorDeadLetter = "dead-letter" // Запись в файл -dead-letter
+)
+
+// errorsQueueSize - емкость канала ошибок стадий.
+const errorsQueueSize = 64
+
+// errFailFast - остановка пайплайна из-за ошибки стадии (-fail-fast).
+var errFailFast = errors.New("пайплайн остановлен из-за ошибки стадии")
+
+// validateOnError - проверка режима обработки ошибок.
+func validateOnError(mode string) error {
+	switch mode {
+	case onErrorLog, onErrorDrop, onErrorDeadLetter:
+		return nil
+	}
+	return fmt.Errorf("неизвестный режим обработки ошибок: %q (ожидается %s, %s или %s)",
+		mode, onErrorLog, onErrorDrop, onErrorDeadLetter)
+}
+
+// deadLetterRecord - запись файла недоставленных значений.
+type deadLetterRecord struct {
+	Time   time.Time `json:"time"`
+	Stage  string    `json:"stage,omitempty"`
+	Value  any       `json:"value,omitempty"`
+	Reason string    `json:"reason"`
+}
+
+// deadLetter - файл недоставленных значений, по одному JSON-объекту в строке.
+type deadLetter struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// openDeadLetter - открытие файла недоставленных значений для дозаписи.
+func openDeadLetter(path string) (*deadLetter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &deadLetter{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// write - запись значения с причиной.
+func (d *deadLetter) write(rec deadLetterRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	rec.Time = time.Now()
+	if err := d.enc.Encode(rec); err != nil {
+		stageLog("errors").Error("Ошибка записи в файл недоставленных значений", "err", err)
+	}
+}
+
+// Close - закрытие файла.
+func (d *deadLetter) Close() error {
+	return d.f.Close()
+}
+
+// errorHandler - обработчик ошибок стадий из канала pipeline.WithErrors
+// и отброшенных значений из канала pipeline.WithRejects.
+type errorHandler struct {
+	errs     chan error
+	rejects  chan *pipeline.Rejection // nil - отброшенные значения не записываются и не учитываются
+	mode     string
+	dl       *deadLetter
+	dlReject bool                    // Записывать отброшенные значения в dl (-dead-letter-rejects)
+	stats    *runStats               // Учет ошибок и отброшенных значений (nil - не ведется)
+	failFast context.CancelCauseFunc // Остановка пайплайна (nil без -fail-fast)
+	done     chan struct{}
+	finished chan struct{}
+}
+
+// startErrorHandler - запуск обработчика ошибок согласно конфигурации.
+// Возвращает контекст для стадий, в котором ошибки (и при -dead-letter-rejects
+// отброшенные значения) передаются обработчику. При -fail-fast первая ошибка
+// отменяет контекст через cancel. Ошибки и отброшенные значения учитываются в st.
+func startErrorHandler(ctx context.Context, cfg config, dl *deadLetter, st *runStats, cancel context.CancelCauseFunc) (context.Context, *errorHandler) {
+	h := &errorHandler{
+		errs:     make(chan error, errorsQueueSize),
+		mode:     cfg.onError,
+		dl:       dl,
+		dlReject: cfg.deadLetterRejects,
+		stats:    st,
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	if cfg.failFast {
+		h.failFast = cancel
+	}
+	ctx = pipeline.WithErrors(ctx, h.errs)
+	if cfg.deadLetterRejects || st != nil {
+		h.rejects = make(chan *pipeline.Rejection, errorsQueueSize)
+		ctx = pipeline.WithRejects(ctx, h.rejects)
+	}
+	go h.run()
+	return ctx, h
+}
+
+// run - обработка ошибок до остановки с дочиткой оставшихся в каналах.
+func (h *errorHandler) run() {
+	defer close(h.finished)
+	for {
+		select {
+		case err := <-h.errs:
+			h.handle(err)
+		case r := <-h.rejects:
+			h.reject(r)
+		case <-h.done:
+			for {
+				select {
+				case err := <-h.errs:
+					h.handle(err)
+				case r := <-h.rejects:
+					h.reject(r)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle - обработка одной ошибки.
+func (h *errorHandler) handle(err error) {
+	var se *pipeline.StageError
+	errors.As(err, &se)
+	h.stats.failed()
+	// Паника стадии журналируется со стеком при любом режиме обработки ошибок
+	var pe *pipeline.PanicError
+	if se != nil && errors.As(err, &pe) {
+		stageLog(se.Stage).Error("Паника стадии", "value", se.Value, "err", pe, "stack", string(pe.Stack))
+	}
+	switch {
+	case se != nil && errors.Is(err, pipeline.ErrSlowItem):
+		// Медленное значение журналируется и записывается в -dead-letter при любом режиме
+		stageLog(se.Stage).Warn("Превышено время обработки значения, значение отброшено", "value", se.Value, "err", se.Err)
+		if h.dl != nil {
+			h.dl.write(deadLetterRecord{Stage: se.Stage, Value: se.Value, Reason: se.Err.Error()})
+		}
+	case h.mode == onErrorLog:
+		switch {
+		case pe != nil:
+			// Записана в журнал выше
+		case se != nil:
+			stageLog(se.Stage).Warn("Ошибка обработки значения", "value", se.Value, "err", se.Err)
+		default:
+			stageLog("errors").Warn("Ошибка стадии", "err", err)
+		}
+	case h.mode == onErrorDeadLetter:
+		rec := deadLetterRecord{Reason: err.Error()}
+		if se != nil {
+			rec.Stage, rec.Value, rec.Reason = se.Stage, se.Value, se.Err.Error()
+		}
+		h.dl.write(rec)
+	}
+	if h.failFast != nil {
+		h.failFast(fmt.Errorf("%w: %w", errFailFast, err))
+	}
+}
+
+// reject - учет отброшенного значения и запись в файл недоставленных значений.
+func (h *errorHandler) reject(r *pipeline.Rejection) {
+	h.stats.reject(r)
+	if h.dlReject {
+		h.dl.write(deadLetterRecord{Stage: r.Stage, Value: r.Value, Reason: r.Reason})
+	}
+}
+
+// Close - остановка обработчика после обработки оставшихся ошибок.
+func (h *errorHandler) Close() {
+	close(h.done)
+	<-h.finished
+}
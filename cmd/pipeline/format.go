@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Форматы ввода и вывода.
+const (
+	formatText  = "text"  // По одному числу в строке
+	formatJSONL = "jsonl" // По одному JSON-объекту в строке
+	formatCSV   = "csv"   // CSV, число в столбце -column (только ввод)
+)
+
+// lineParser - функция разбора входной строки для формата cfg.format.
+func lineParser(cfg config) func(line string) (pipeline.Num, error) {
+	if cfg.format == formatJSONL {
+		return jsonFieldParser(cfg.jsonField)
+	}
+	return textParser(cfg)
+}
+
+// textParser - разбор текстовой строки: число - вся строка или, с
+// -delimiter и -extract, поле -column и (или) текст, выделенный выражением.
+func textParser(cfg config) func(line string) (pipeline.Num, error) {
+	var chain []pipeline.Extractor
+	if cfg.delimiter != "" {
+		chain = append(chain, pipeline.FieldExtractor(cfg.delimiter, cfg.csvColumn))
+	}
+	if cfg.extract != "" {
+		re, err := pipeline.RegexpExtractor(cfg.extract)
+		if err != nil { // Выражение проверено в validate
+			return func(string) (pipeline.Num, error) { return pipeline.Num{}, err }
+		}
+		chain = append(chain, re)
+	}
+	if len(chain) == 0 {
+		return pipeline.ParseNumLine
+	}
+	return pipeline.ParseExtracted(pipeline.ChainExtractors(chain...))
+}
+
+// validateExtract - проверка флагов выделения числа из строки.
+func (c config) validateExtract() error {
+	if c.extract == "" && c.delimiter == "" {
+		return nil
+	}
+	if c.format != formatText {
+		return fmt.Errorf("флаги extract и delimiter применяются только к формату text")
+	}
+	if c.extract != "" {
+		if _, err := pipeline.RegexpExtractor(c.extract); err != nil {
+			return fmt.Errorf("extract: %w", err)
+		}
+	}
+	return nil
+}
+
+// newInputSource - источник чисел из r в формате конфигурации.
+func newInputSource(r io.Reader, cfg config, onInvalid func(lineNo int, line string, err error)) pipeline.Source[pipeline.Num] {
+	if cfg.format == formatCSV {
+		return pipeline.NewCSVSource(r, cfg.csvColumn, cfg.skipHeader, onInvalid)
+	}
+	return pipeline.NewLineSource(r, lineParser(cfg), onInvalid)
+}
+
+// jsonFieldParser - разбор JSON-объекта с числом в поле field. Число может
+// быть записано строкой (так часто передаются большие целые).
+func jsonFieldParser(field string) func(line string) (pipeline.Num, error) {
+	return func(line string) (pipeline.Num, error) {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			return pipeline.Num{}, err
+		}
+		raw, ok := obj[field]
+		if !ok {
+			return pipeline.Num{}, fmt.Errorf("нет поля %q", field)
+		}
+		var num pipeline.Num
+		if err := json.Unmarshal(raw, &num); err != nil {
+			return pipeline.Num{}, fmt.Errorf("поле %q: ожидается число: %s", field, raw)
+		}
+		return num, nil
+	}
+}
+
+// batchTracker - сопоставление значений на выходе с партиями буфера.
+// Значения партии выходят подряд, поэтому достаточно очереди размеров партий.
+type batchTracker struct {
+	mu        sync.Mutex
+	queue     []batchInfo
+	cur       batchInfo
+	remaining int
+}
+
+// batchInfo - отправленная партия буфера.
+type batchInfo struct {
+	id uint64
+	n  int
+}
+
+// flushed - регистрация отправки партии (обработчик BufferOptions.OnFlush).
+// Допускает nil-получатель.
+func (t *batchTracker) flushed(id uint64, n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queue = append(t.queue, batchInfo{id: id, n: n})
+}
+
+// next - номер партии очередного значения на выходе; last - значение
+// последнее в партии (ok = false - партия неизвестна).
+func (t *batchTracker) next() (id uint64, last, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.remaining == 0 {
+		if len(t.queue) == 0 {
+			return 0, false, false
+		}
+		t.cur, t.queue = t.queue[0], t.queue[1:]
+		t.remaining = t.cur.n
+	}
+	t.remaining--
+	return t.cur.id, t.remaining == 0, true
+}
+
+// itemSink - приемник, выводящий значения вместе с метаданными.
+type itemSink interface {
+	WriteItem(it envelope) error
+}
+
+// writeItem - вывод значения it приемником sink, с метаданными - если
+// приемник их поддерживает.
+func writeItem(sink pipeline.Sink[pipeline.Num], it envelope) error {
+	if s, ok := sink.(itemSink); ok {
+		return s.WriteItem(it)
+	}
+	return sink.Write(it.Value)
+}
+
+// jsonRecord - запись вывода в формате jsonl.
+type jsonRecord struct {
+	Value      pipeline.Num      `json:"value"`
+	ReceivedAt time.Time         `json:"received_at"`
+	Batch      *uint64           `json:"batch,omitempty"`
+	Seq        uint64            `json:"seq,omitempty"`         // Порядковый номер на входе
+	Source     string            `json:"source,omitempty"`      // Источник значения
+	IngestedAt *time.Time        `json:"ingested_at,omitempty"` // Момент поступления в пайплайн
+	LatencyMs  *float64          `json:"latency_ms,omitempty"`  // Задержка от входа до выхода
+	Anomaly    *pipeline.Anomaly `json:"anomaly,omitempty"`     // Отклонение, найденное стадией anomaly
+}
+
+// jsonlSink - приемник, выводящий значения JSON-объектами по одному в строке.
+type jsonlSink struct {
+	enc     *json.Encoder
+	batches *batchTracker // nil - номера партий не выводятся
+}
+
+// newJSONLSink - создание приемника jsonl, выводящего в w.
+func newJSONLSink(w io.Writer, batches *batchTracker) *jsonlSink {
+	return &jsonlSink{enc: json.NewEncoder(w), batches: batches}
+}
+
+// Write - вывод значения с временем получения и номером партии.
+func (s *jsonlSink) Write(n pipeline.Num) error {
+	return s.enc.Encode(s.record(n))
+}
+
+// WriteItem - вывод значения с метаданными: номером, источником, моментом
+// поступления, задержкой от входа до выхода и найденным отклонением.
+func (s *jsonlSink) WriteItem(it envelope) error {
+	rec := s.record(it.Value)
+	latency := float64(it.Latency(rec.ReceivedAt)) / float64(time.Millisecond)
+	rec.Seq, rec.Source, rec.IngestedAt, rec.LatencyMs = it.Seq, it.Source, &it.IngestedAt, &latency
+	rec.Anomaly = it.Anomaly
+	return s.enc.Encode(rec)
+}
+
+// record - запись значения n с временем получения и номером партии.
+func (s *jsonlSink) record(n pipeline.Num) jsonRecord {
+	rec := jsonRecord{Value: n, ReceivedAt: time.Now()}
+	if s.batches != nil {
+		if id, _, ok := s.batches.next(); ok {
+			rec.Batch = &id
+		}
+	}
+	return rec
+}
+
+// Flush - ничего не делает: записи выводятся сразу.
+func (s *jsonlSink) Flush() error { return nil }
+
+// batchWriter - приемник, собирающий значения на выходе в партии буфера
+// и передающий их целиком в sink (режим -batch-output). Значения вне
+// известных партий передаются по одному.
+type batchWriter struct {
+	sink    pipeline.BatchSink[pipeline.Num]
+	batches *batchTracker
+	cur     []pipeline.Num
+}
+
+// Write - добавление значения в текущую партию; последнее значение партии
+// передает ее в sink.
+func (w *batchWriter) Write(n pipeline.Num) error {
+	w.cur = append(w.cur, n)
+	if _, last, ok := w.batches.next(); ok && !last {
+		return nil
+	}
+	batch := w.cur
+	w.cur = nil
+	return w.sink.WriteBatch(batch)
+}
+
+// Flush - передача незавершенной партии и сброс sink.
+func (w *batchWriter) Flush() error {
+	if len(w.cur) > 0 {
+		batch := w.cur
+		w.cur = nil
+		if err := w.sink.WriteBatch(batch); err != nil {
+			return err
+		}
+	}
+	return w.sink.Flush()
+}
+
+// textBatchSink - вывод партий строками вида "Получена партия: 3 6 9".
+type textBatchSink struct {
+	w      io.Writer
+	colors palette // Раскраска значений партии
+}
+
+// WriteBatch - вывод партии.
+func (s textBatchSink) WriteBatch(batch []pipeline.Num) error {
+	var b strings.Builder
+	b.WriteString(tr("Получена партия:"))
+	for _, n := range batch {
+		b.WriteByte(' ')
+		b.WriteString(s.colors.paint(n, n.String()))
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(s.w, b.String())
+	return err
+}
+
+// Flush - ничего не делает: партии выводятся сразу.
+func (s textBatchSink) Flush() error { return nil }
+
+// jsonBatchRecord - партия в формате jsonl.
+type jsonBatchRecord struct {
+	Values     []pipeline.Num `json:"values"`
+	ReceivedAt time.Time      `json:"received_at"`
+}
+
+// jsonlBatchSink - вывод партий JSON-объектами по одному в строке.
+type jsonlBatchSink struct {
+	enc *json.Encoder
+}
+
+// newJSONLBatchSink - создание приемника партий jsonl, выводящего в w.
+func newJSONLBatchSink(w io.Writer) jsonlBatchSink {
+	return jsonlBatchSink{enc: json.NewEncoder(w)}
+}
+
+// WriteBatch - вывод партии с временем получения.
+func (s jsonlBatchSink) WriteBatch(batch []pipeline.Num) error {
+	return s.enc.Encode(jsonBatchRecord{Values: batch, ReceivedAt: time.Now()})
+}
+
+// Flush - ничего не делает: партии выводятся сразу.
+func (s jsonlBatchSink) Flush() error { return nil }
+
+// validateFormat - проверка имени формата.
+func validateFormat(format string) error {
+	switch format {
+	case formatText, formatJSONL, formatCSV:
+		return nil
+	}
+	return fmt.Errorf("неизвестный формат: %q (ожидается %s)", format, strings.Join([]string{formatText, formatJSONL, formatCSV}, ", "))
+}
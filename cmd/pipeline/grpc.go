@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+	"github.com/MosinEvgeny/Pipline/pipelinepb"
+)
+
+// Режимы создания пайплайна подкоманды grpc.
+const (
+	grpcPerStream = "per-stream" // Отдельный пайплайн на каждый вызов Process
+	grpcShared    = "shared"     // Один пайплайн на все вызовы
+)
+
+// grpcStreamBuffer - количество обработанных значений, ожидающих отправки
+// одному клиенту в режиме shared.
+const grpcStreamBuffer = 256
+
+// grpcCommand - сервер gRPC: значения вызовов Process обрабатываются стадиями
+// конфигурации и возвращаются клиенту в том же потоке.
+func grpcCommand(args []string) int {
+	fs := flag.NewFlagSet("grpc", flag.ContinueOnError)
+	addr := fs.String("grpc-addr", ":9090", "адрес gRPC-сервера")
+	mode := fs.String("grpc-mode", grpcPerStream, "пайплайн: per-stream (на каждый вызов) или shared (общий)")
+	linger := fs.Duration("grpc-linger", 0, "в режиме shared: ожидание результатов после завершения ввода клиента (0 - удвоенный -flush-interval)")
+	cfg, err := parseConfig(fs, args)
+	if err != nil {
+		return 2
+	}
+	if err := cfg.validate(); err != nil {
+		slog.Error("Ошибка конфигурации", "err", err)
+		return 2
+	}
+	if *mode != grpcPerStream && *mode != grpcShared {
+		slog.Error("Ошибка конфигурации", "err", fmt.Errorf("неизвестный режим -grpc-mode: %q (ожидается per-stream или shared)", *mode))
+		return 2
+	}
+	if *linger < 0 {
+		slog.Error("Ошибка конфигурации", "err", errors.New("-grpc-linger не может быть отрицательным"))
+		return 2
+	}
+	if *linger == 0 {
+		*linger = 2 * cfg.flushInterval
+	}
+
+	// Стадии не отменяются сигналом: вызовы дообрабатываются до -drain-timeout
+	base, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	ctx, errh := startErrorHandler(base, cfg, nil, newRunStats(), cancel)
+	defer errh.Close()
+
+	s := &grpcServer{ctx: ctx, cfg: cfg}
+	if *mode == grpcShared {
+		if s.shared, err = startSharedPipeline(ctx, cfg, *linger); err != nil {
+			slog.Error("Ошибка запуска пайплайна", "err", err)
+			return 1
+		}
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		slog.Error("Ошибка запуска gRPC-сервера", "err", err)
+		return 1
+	}
+	srv := grpc.NewServer()
+	pipelinepb.RegisterPipelineServer(srv, s)
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus(pipelinepb.Pipeline_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	reflection.Register(srv)
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-sigCtx.Done()
+		stop()
+		hs.Shutdown()
+		slog.Info("Завершение: новые вызовы не принимаются, ожидание текущих", "drain_timeout", cfg.drainTimeout)
+		done := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(cfg.drainTimeout):
+			slog.Warn("Истекло время дообработки, вызовы прерваны")
+			srv.Stop()
+		}
+	}()
+
+	slog.Info("Программа запущена. Прием значений по gRPC", "addr", ln.Addr().String(), "mode", *mode)
+	if err := srv.Serve(ln); err != nil {
+		slog.Error("Ошибка gRPC-сервера", "err", err)
+		return 1
+	}
+	<-stopped
+	slog.Info("Программа завершена")
+	return 0
+}
+
+// grpcServer - реализация сервиса pipeline.v1.Pipeline.
+type grpcServer struct {
+	pipelinepb.UnimplementedPipelineServer
+	ctx    context.Context // Контекст стадий с обработчиком ошибок
+	cfg    config
+	shared *sharedPipeline // nil - пайплайн на каждый вызов
+	calls  atomic.Uint64   // Номера вызовов в идентификаторах источника
+}
+
+// Process - обработка значений клиента. В режиме per-stream поток завершается
+// после обработки всех значений, отправленных до CloseSend.
+func (s *grpcServer) Process(stream pipelinepb.Pipeline_ProcessServer) error {
+	source := fmt.Sprintf("grpc:%s#%d", peerAddr(stream.Context()), s.calls.Add(1))
+	if s.shared != nil {
+		return s.shared.process(stream, source)
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	defer context.AfterFunc(stream.Context(), cancel)()
+
+	input := make(chan pipeline.Num)
+	p, err := startPipeline(ctx, tagSource(ctx, input, source), s.cfg, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "запуск пайплайна: %v", err)
+	}
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(input)
+		recvErr <- receiveValues(ctx, stream, source, func(v pipeline.Num) bool {
+			select {
+			case input <- v:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	for it := range p.out {
+		if err := stream.Send(valueMessage(it)); err != nil {
+			return err
+		}
+	}
+	// Выход закрывается и при отмене: прием мог не завершиться
+	if err := context.Cause(s.ctx); err != nil {
+		return status.Errorf(codes.Aborted, "пайплайн остановлен: %v", err)
+	}
+	if err := stream.Context().Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return <-recvErr
+}
+
+// receiveValues - прием значений клиента до CloseSend с передачей в push.
+// Некорректные значения пропускаются с предупреждением в журнале.
+// Возвращает ошибку потока или nil при штатном завершении ввода.
+func receiveValues(ctx context.Context, stream pipelinepb.Pipeline_ProcessServer, source string, push func(pipeline.Num) bool) error {
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		n, err := pipeline.ParseNum(msg.GetNumber())
+		if err != nil {
+			stageLog("source").Warn("Некорректный ввод", "origin", source, "line", msg.GetNumber(), "err", err)
+			continue
+		}
+		if !push(n) {
+			return nil
+		}
+	}
+}
+
+// valueMessage - сообщение с обработанным значением it.
+func valueMessage(it envelope) *pipelinepb.Value {
+	return &pipelinepb.Value{
+		Number:    it.Value.String(),
+		Seq:       it.Seq,
+		Source:    it.Source,
+		LatencyMs: float64(time.Since(it.IngestedAt)) / float64(time.Millisecond),
+	}
+}
+
+// peerAddr - адрес клиента вызова.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// sharedPipeline - пайплайн, общий для всех вызовов Process. Результаты
+// возвращаются вызову по источнику значения, поэтому стадии, объединяющие
+// значения (агрегаты), выдают результат вызову первого значения окна.
+type sharedPipeline struct {
+	in     chan envelope
+	seq    pipeline.Sequence
+	linger time.Duration // Ожидание результатов после завершения ввода клиента
+
+	mu      sync.Mutex
+	streams map[string]*sharedStream // Источник -> вызов
+}
+
+// sharedStream - очередь результатов одного вызова.
+type sharedStream struct {
+	out  chan envelope
+	done chan struct{} // Закрывается по завершении вызова
+}
+
+// startSharedPipeline - запуск общего пайплайна стадий конфигурации cfg.
+func startSharedPipeline(ctx context.Context, cfg config, linger time.Duration) (*sharedPipeline, error) {
+	stages, _, err := buildStages(cfg.stageSpecs(), buildOptions{queue: cfg.queueCap, restart: cfg.stageRestart, timeout: cfg.stageTimeout})
+	if err != nil {
+		return nil, err
+	}
+	sp := &sharedPipeline{
+		in:      make(chan envelope),
+		linger:  linger,
+		streams: make(map[string]*sharedStream),
+	}
+	go sp.dispatch(pipeline.ChainCap(ctx, sp.in, cfg.chanCap, stages...))
+	return sp, nil
+}
+
+// dispatch - распределение результатов по вызовам. Результаты завершенных
+// вызовов отбрасываются; медленный клиент задерживает весь пайплайн.
+func (sp *sharedPipeline) dispatch(out <-chan envelope) {
+	for it := range out {
+		sp.mu.Lock()
+		st := sp.streams[it.Source]
+		sp.mu.Unlock()
+		if st == nil {
+			stageLog("grpc").Debug("Результат завершенного вызова отброшен", "source", it.Source, "value", it.Value)
+			continue
+		}
+		select {
+		case st.out <- it:
+		case <-st.done:
+		}
+	}
+}
+
+// process - обработка вызова: значения передаются в общий пайплайн,
+// результаты с источником source возвращаются клиенту. После CloseSend
+// вызов завершается, если результатов нет в течение sp.linger.
+func (sp *sharedPipeline) process(stream pipelinepb.Pipeline_ProcessServer, source string) error {
+	ctx := stream.Context()
+	st := &sharedStream{out: make(chan envelope, grpcStreamBuffer), done: make(chan struct{})}
+	sp.mu.Lock()
+	sp.streams[source] = st
+	sp.mu.Unlock()
+	defer func() {
+		sp.mu.Lock()
+		delete(sp.streams, source)
+		sp.mu.Unlock()
+		close(st.done)
+	}()
+
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- receiveValues(ctx, stream, source, func(v pipeline.Num) bool {
+			it := envelope{Value: v, IngestedAt: time.Now(), Seq: sp.seq.Next(), Source: source}
+			select {
+			case sp.in <- it:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	var linger <-chan time.Time
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case it := <-st.out:
+			if err := stream.Send(valueMessage(it)); err != nil {
+				return err
+			}
+			if timer != nil {
+				timer.Reset(sp.linger)
+			}
+		case err := <-recvErr:
+			if err != nil {
+				return err
+			}
+			recvErr = nil
+			timer = time.NewTimer(sp.linger)
+			linger = timer.C
+		case <-linger:
+			return nil
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
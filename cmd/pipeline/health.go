@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// healthProbeTimeout - наибольшее время проверки подключения источника в /readyz.
+const healthProbeTimeout = 2 * time.Second
+
+// sourceStatus - состояние источника данных для проб готовности.
+type sourceStatus struct {
+	name   string
+	closed atomic.Bool                     // Источник завершен, вход пайплайна закрыт
+	probe  func(ctx context.Context) error // Проверка подключения (nil - подключен, пока не завершен)
+}
+
+// check - ошибка подключения источника или nil, если он подключен.
+func (s *sourceStatus) check(ctx context.Context) error {
+	if s.closed.Load() {
+		return errors.New("источник завершен")
+	}
+	if s.probe == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	return s.probe(ctx)
+}
+
+// kafkaProbe - проверка доступности хотя бы одного из брокеров.
+func kafkaProbe(brokers []string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, b := range brokers {
+			conn, err := kafka.DialContext(ctx, "tcp", b)
+			if err == nil {
+				return conn.Close()
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// trackLiveness - стадия с учетом работы ее горутины в sm. Стадия считается
+// остановившейся аварийно, если она завершилась до закрытия входа и отмены ctx.
+func trackLiveness(sm *stageMetric, stage pipeline.Stage[envelope]) pipeline.Stage[envelope] {
+	return func(ctx context.Context, in <-chan envelope, out chan<- envelope) {
+		sm.running.Store(true)
+		defer sm.running.Store(false)
+		stage(ctx, in, out)
+		if ctx.Err() != nil {
+			return
+		}
+		// Незакрытый вход после завершения стадии больше никто не читает
+		select {
+		case _, ok := <-in:
+			if !ok {
+				return
+			}
+		default:
+		}
+		sm.failed.Store(true)
+		stageLog(sm.name).Error("Стадия завершилась до закрытия входа", "index", sm.index)
+	}
+}
+
+// healthStage - состояние стадии в ответах /healthz и /readyz.
+type healthStage struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Running bool   `json:"running"` // Горутина стадии работает
+	Alive   bool   `json:"alive"`   // Стадия не завершилась аварийно
+}
+
+// healthBuffer - заполненность стадии buffer.
+type healthBuffer struct {
+	Index     int   `json:"index"`
+	Occupancy int64 `json:"occupancy"` // Значений в буфере
+	Size      int   `json:"size"`      // Размер буфера (0 - неизвестен)
+	Spilled   int64 `json:"spilled"`   // Значений в файле сброса
+}
+
+// healthSource - состояние источника в ответе /readyz.
+type healthSource struct {
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	Paused    bool   `json:"paused"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthReport - ответ /healthz и /readyz.
+type healthReport struct {
+	Status   string         `json:"status"` // ok или fail
+	Reason   string         `json:"reason,omitempty"`
+	Stages   []healthStage  `json:"stages"`
+	Buffers  []healthBuffer `json:"buffers,omitempty"`
+	Source   *healthSource  `json:"source,omitempty"`
+	Draining bool           `json:"draining,omitempty"` // Получен сигнал завершения
+}
+
+// registerHealth - регистрация проб в mux: GET /healthz (живость: все стадии
+// работают) и GET /readyz (готовность: стадии работают, источник подключен,
+// завершение не начато). Обе пробы сообщают заполненность буферов; при
+// неуспехе код ответа - 503.
+func registerHealth(mux *http.ServeMux, m *metrics, rc *runControl, sd *shutdown) {
+	report := func() healthReport {
+		m.mu.Lock()
+		stages, control := m.stages, m.control
+		m.mu.Unlock()
+		rep := healthReport{Status: "ok", Stages: []healthStage{}}
+		for _, sm := range stages {
+			alive := !sm.failed.Load()
+			rep.Stages = append(rep.Stages, healthStage{Index: sm.index, Name: sm.name, Running: sm.running.Load(), Alive: alive})
+			if !alive && rep.Reason == "" {
+				rep.Status, rep.Reason = "fail", fmt.Sprintf(tr("стадия %s завершилась аварийно"), sm.name)
+			}
+			if sm.buffer != nil {
+				b := healthBuffer{Index: sm.index, Occupancy: sm.buffer.Occupancy.Load(), Spilled: sm.buffer.Spilled.Load()}
+				if control != nil {
+					b.Size = control.buffer.Settings().Size
+				}
+				rep.Buffers = append(rep.Buffers, b)
+			}
+		}
+		return rep
+	}
+	respond := func(w http.ResponseWriter, rep healthReport) {
+		code := http.StatusOK
+		if rep.Status != "ok" {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, rep)
+	}
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, report())
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		rep := report()
+		src := &healthSource{Name: rc.source.name, Connected: true, Paused: rc.gate.paused()}
+		if err := rc.source.check(r.Context()); err != nil {
+			src.Connected, src.Error = false, err.Error()
+			if rep.Reason == "" {
+				rep.Status, rep.Reason = "fail", "источник не подключен"
+			}
+		}
+		rep.Source = src
+		if sd.requested.Load() {
+			rep.Draining = true
+			if rep.Reason == "" {
+				rep.Status, rep.Reason = "fail", "завершение работы"
+			}
+		}
+		respond(w, rep)
+	})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpShutdownTimeout - время на завершение активных HTTP-запросов.
+const httpShutdownTimeout = 5 * time.Second
+
+// startHTTP - запуск HTTP-сервера с обработчиками mux на адресе addr.
+// Сервер останавливается при отмене ctx.
+func startHTTP(ctx context.Context, addr string, mux *http.ServeMux) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			stageLog("http").Error("Ошибка HTTP-сервера", "err", err)
+		}
+	}()
+	context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	})
+	return ln.Addr(), nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// watchIdle - контроль простоя источника: если за timeout не поступило ни одной
+// входной строки, в журнал выводится предупреждение, повторяемое каждые timeout,
+// пока простой продолжается. При exit источник вместо этого останавливается
+// вызовом stop, и пайплайн завершается после дообработки принятых значений.
+// Приостановленный командой pause источник простаивающим не считается.
+func watchIdle(ctx context.Context, timeout time.Duration, exit bool, rc *runControl, stop func()) {
+	log := stageLog("source")
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+		if rc.gate.paused() {
+			rc.stats.received() // Отсчет простоя - с момента возобновления
+		}
+		idle := time.Since(rc.stats.lastInput())
+		if idle < timeout {
+			timer.Reset(timeout - idle)
+			continue
+		}
+		if exit {
+			log.Warn("Нет входных данных, источник остановлен", "idle", idle.Round(time.Millisecond))
+			stop()
+			return
+		}
+		log.Warn("Нет входных данных", "idle", idle.Round(time.Millisecond))
+		timer.Reset(timeout)
+	}
+}
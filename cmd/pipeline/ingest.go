@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// maxIngestBody - наибольший размер тела запроса /ingest.
+const maxIngestBody = 10 << 20
+
+// ingestResponse - ответ на запрос /ingest.
+type ingestResponse struct {
+	Accepted int    `json:"accepted"`        // Значения, переданные в пайплайн
+	Rejected int    `json:"rejected"`        // Некорректные значения
+	Error    string `json:"error,omitempty"` // Причина прерывания приема
+}
+
+// ingestHandler - обработчик POST /ingest: прием чисел в теле запроса
+// (text/plain - по одному в строке, application/json - массив,
+// application/x-ndjson - JSON Lines) и передача их в input.
+type ingestHandler struct {
+	ctx   context.Context // Контекст источника: после отмены запросы не принимаются
+	cfg   config
+	rej   *rejects
+	input chan<- pipeline.Num
+
+	mu      sync.Mutex
+	stopped bool
+	active  sync.WaitGroup // Запросы, передающие значения в input
+}
+
+// begin - регистрация запроса; false, если прием остановлен.
+func (h *ingestHandler) begin() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped || h.ctx.Err() != nil {
+		return false
+	}
+	h.active.Add(1)
+	return true
+}
+
+// stop - остановка приема и ожидание запросов, передающих значения.
+// После возврата input можно закрыть.
+func (h *ingestHandler) stop() {
+	h.mu.Lock()
+	h.stopped = true
+	h.mu.Unlock()
+	h.active.Wait()
+}
+
+// ServeHTTP - прием чисел из тела запроса.
+func (h *ingestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "ожидается POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.begin() {
+		http.Error(w, "прием данных остановлен", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.active.Done()
+
+	// Передача прерывается при отключении клиента или остановке источника
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stopReq := context.AfterFunc(h.ctx, cancel)
+	defer stopReq()
+
+	var resp ingestResponse
+	origin := "http:" + r.RemoteAddr
+	report := h.rej.report(origin)
+	onInvalid := func(lineNo int, line string, err error) {
+		resp.Rejected++
+		report(lineNo, line, err)
+	}
+	src, err := h.source(r, onInvalid)
+	if err != nil {
+		writeIngestResponse(w, http.StatusBadRequest, ingestResponse{Error: err.Error()})
+		return
+	}
+	for {
+		n, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			status := http.StatusBadRequest
+			if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			resp.Error = err.Error()
+			writeIngestResponse(w, status, resp)
+			return
+		}
+		h.rej.rec.value(n)
+		select {
+		case h.input <- n:
+			resp.Accepted++
+		case <-ctx.Done():
+			resp.Error = "прием прерван"
+			writeIngestResponse(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+	}
+	writeIngestResponse(w, http.StatusAccepted, resp)
+}
+
+// source - источник чисел тела запроса по его типу содержимого.
+func (h *ingestHandler) source(r *http.Request, onInvalid func(lineNo int, line string, err error)) (pipeline.Source[pipeline.Num], error) {
+	body := http.MaxBytesReader(nil, r.Body, maxIngestBody)
+	mediaType := "text/plain"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный Content-Type: %w", err)
+		}
+		mediaType = mt
+	}
+	switch mediaType {
+	case "text/plain", "application/x-www-form-urlencoded": // Второй - тип curl --data по умолчанию
+		return pipeline.NewLineSource(body, textParser(h.cfg), onInvalid), nil
+	case "application/json":
+		var items []json.RawMessage
+		if err := json.NewDecoder(body).Decode(&items); err != nil {
+			return nil, fmt.Errorf("ожидается JSON-массив чисел: %w", err)
+		}
+		var nums []pipeline.Num
+		for i, raw := range items {
+			n, err := parseJSONNum(string(raw))
+			if err != nil {
+				onInvalid(i+1, string(raw), err)
+				continue
+			}
+			nums = append(nums, n)
+		}
+		return pipeline.NewSliceSource(nums...), nil
+	case "application/x-ndjson", "application/jsonl", "application/jsonlines":
+		field := jsonFieldParser(h.cfg.jsonField)
+		return pipeline.NewLineSource(body, func(line string) (pipeline.Num, error) {
+			// Строка - число или объект с полем -json-field
+			if n, err := parseJSONNum(line); err == nil {
+				return n, nil
+			}
+			return field(line)
+		}, onInvalid), nil
+	}
+	return nil, fmt.Errorf("неподдерживаемый Content-Type %q (ожидается text/plain, application/json или application/x-ndjson)", mediaType)
+}
+
+// parseJSONNum - разбор числа (или строки с числом) в записи JSON.
+func parseJSONNum(s string) (pipeline.Num, error) {
+	s = strings.TrimSpace(s)
+	var n pipeline.Num
+	if err := json.Unmarshal([]byte(s), &n); err != nil {
+		return n, fmt.Errorf("ожидается число: %s", s)
+	}
+	return n, nil
+}
+
+// writeIngestResponse - запись ответа /ingest в формате JSON.
+func writeIngestResponse(w http.ResponseWriter, status int, resp ingestResponse) {
+	writeJSON(w, status, resp)
+}
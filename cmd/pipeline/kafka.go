@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Начальная позиция чтения топика для группы без сохраненных смещений.
+const (
+	kafkaOffsetEarliest = "earliest"
+	kafkaOffsetLatest   = "latest"
+)
+
+// kafkaBatchSize - количество сообщений, накапливаемых приемником Kafka
+// перед отправкой.
+const kafkaBatchSize = 100
+
+// validateKafkaOffset - проверка начальной позиции чтения.
+func validateKafkaOffset(offset string) error {
+	switch offset {
+	case kafkaOffsetEarliest, kafkaOffsetLatest:
+		return nil
+	}
+	return fmt.Errorf("неизвестная позиция чтения Kafka: %q (ожидается %s или %s)", offset, kafkaOffsetEarliest, kafkaOffsetLatest)
+}
+
+// kafkaBrokers - список брокеров из строки через запятую.
+func kafkaBrokers(s string) []string {
+	var brokers []string
+	for _, b := range strings.Split(s, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
+
+// kafkaSource - источник чисел из топика Kafka в группе потребителей.
+// Смещения фиксируются в commit только для сообщений, значения которых
+// обработаны и выведены (доставка не менее одного раза).
+type kafkaSource struct {
+	r     *kafka.Reader
+	parse func(line string) (pipeline.Num, error)
+	rej   *rejects
+	acks  sourceAcks[kafka.Message] // Сообщения, смещения которых еще не зафиксированы
+}
+
+// newKafkaSource - потребитель топика cfg.kafkaTopic в группе cfg.kafkaGroup.
+func newKafkaSource(cfg config, rej *rejects) *kafkaSource {
+	start := kafka.FirstOffset
+	if cfg.kafkaOffset == kafkaOffsetLatest {
+		start = kafka.LastOffset
+	}
+	return &kafkaSource{
+		r: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     kafkaBrokers(cfg.kafkaBrokers),
+			GroupID:     cfg.kafkaGroup,
+			Topic:       cfg.kafkaTopic,
+			StartOffset: start,
+		}),
+		parse: lineParser(cfg),
+		rej:   rej,
+	}
+}
+
+// run - чтение сообщений в input до отмены ctx или ошибки.
+func (s *kafkaSource) run(ctx context.Context, input chan<- pipeline.Num) error {
+	for {
+		msg, err := s.r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("kafka: %w", err)
+		}
+		n, err := s.parse(string(msg.Value))
+		if err != nil {
+			origin := fmt.Sprintf("kafka:%s/%d", msg.Topic, msg.Partition)
+			s.rej.report(origin)(int(msg.Offset), string(msg.Value), err)
+		} else {
+			s.rej.rec.value(n)
+			select {
+			case input <- n:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		s.acks.add(msg, err == nil)
+	}
+}
+
+// commit - фиксация смещений сообщений, все значения которых входят
+// в первые values значений источника.
+func (s *kafkaSource) commit(values uint64) error {
+	msgs := s.acks.take(values)
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := s.r.CommitMessages(context.Background(), msgs...); err != nil {
+		return fmt.Errorf("kafka: фиксация смещений: %w", err)
+	}
+	return nil
+}
+
+// Close - остановка потребителя.
+func (s *kafkaSource) Close() error {
+	return s.r.Close()
+}
+
+// kafkaSink - приемник, отправляющий обработанные числа в топик Kafka.
+// Сообщения накапливаются и отправляются партиями по kafkaBatchSize и при Flush.
+type kafkaSink struct {
+	ctx   context.Context
+	w     *kafka.Writer
+	json  bool // Сообщения - JSON-объекты {"value": n}
+	keys  bool // Сообщения с ключом идемпотентности в заголовке (-ack)
+	batch []kafka.Message
+}
+
+// kafkaKeyHeader - заголовок сообщения с ключом идемпотентности
+// <источник>/<номер>, по которому получатель отбрасывает повторы партий.
+const kafkaKeyHeader = "pipeline-key"
+
+// newKafkaSink - отправитель в топик cfg.kafkaOutTopic; попытки отправки
+// прекращаются при отмене ctx.
+func newKafkaSink(ctx context.Context, cfg config) *kafkaSink {
+	return &kafkaSink{
+		ctx: ctx,
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(kafkaBrokers(cfg.kafkaBrokers)...),
+			Topic:        cfg.kafkaOutTopic,
+			Balancer:     &kafka.LeastBytes{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		json: cfg.format == formatJSONL,
+		keys: cfg.ackBatches,
+	}
+}
+
+// Write - добавление числа n в партию.
+func (s *kafkaSink) Write(n pipeline.Num) error {
+	return s.add(s.message(n))
+}
+
+// WriteItem - добавление значения it в партию, при -ack - с ключом идемпотентности.
+func (s *kafkaSink) WriteItem(it envelope) error {
+	msg := s.message(it.Value)
+	if s.keys {
+		key := fmt.Sprintf("%s/%d", it.Source, it.Seq)
+		msg.Headers = []kafka.Header{{Key: kafkaKeyHeader, Value: []byte(key)}}
+	}
+	return s.add(msg)
+}
+
+// message - сообщение с числом n.
+func (s *kafkaSink) message(n pipeline.Num) kafka.Message {
+	value := []byte(n.String())
+	if s.json {
+		value, _ = json.Marshal(map[string]pipeline.Num{"value": n})
+	}
+	return kafka.Message{Value: value}
+}
+
+// add - добавление сообщения msg в партию с отправкой заполненной партии.
+func (s *kafkaSink) add(msg kafka.Message) error {
+	s.batch = append(s.batch, msg)
+	if len(s.batch) >= kafkaBatchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush - отправка накопленной партии с подтверждением брокеров.
+func (s *kafkaSink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	// При ошибке партия сохраняется для повторной отправки
+	if err := s.w.WriteMessages(s.ctx, s.batch...); err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	s.batch = s.batch[:0]
+	return nil
+}
+
+// Reset - отбрасывание неотправленной партии.
+func (s *kafkaSink) Reset() {
+	s.batch = s.batch[:0]
+}
+
+// Close - отправка остатка и закрытие отправителя.
+func (s *kafkaSink) Close() error {
+	return errors.Join(s.Flush(), s.w.Close())
+}
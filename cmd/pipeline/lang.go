@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Языки сообщений программы.
+const (
+	langRU = "ru"
+	langEN = "en"
+)
+
+// lang - язык сообщений: вывода в консоль и журнала (устанавливается -lang).
+var lang = langRU
+
+// validateLang - проверка языка сообщений.
+func validateLang(l string) error {
+	if l != langRU && l != langEN {
+		return fmt.Errorf("неизвестный язык: %q (ожидается %s или %s)", l, langRU, langEN)
+	}
+	return nil
+}
+
+// tr - перевод сообщения s на язык lang. Ключом служит исходное сообщение на
+// русском; сообщения без перевода выводятся как есть.
+func tr(s string) string {
+	if t, ok := translations[lang][s]; ok {
+		return t
+	}
+	return s
+}
+
+// translatingHandler - журнал с переводом сообщений записей через tr.
+// Атрибуты и тексты ошибок не переводятся.
+type translatingHandler struct {
+	slog.Handler
+}
+
+// Handle - запись с переведенным сообщением.
+func (h translatingHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.Message = tr(r.Message)
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs - журнал с атрибутами attrs и переводом сообщений.
+func (h translatingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return translatingHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup - журнал с группой атрибутов name и переводом сообщений.
+func (h translatingHandler) WithGroup(name string) slog.Handler {
+	return translatingHandler{h.Handler.WithGroup(name)}
+}
+
+// translations - переводы сообщений по языкам.
+var translations = map[string]map[string]string{
+	langEN: {
+		// Вывод в консоль
+		"Обработанные данные:":                      "Processed data:",
+		"Получены данные: ":                         "Received: ",
+		" (аномалия: ":                              " (anomaly: ",
+		"Получена партия:":                          "Received batch:",
+		"Конфигурация корректна.":                   "Configuration is valid.",
+		"Ошибка конфигурации:":                      "Configuration error:",
+		"Неизвестная подкоманда: %s (доступны: %s)": "Unknown subcommand: %s (available: %s)",
+		"Использование: stages list [флаги]":        "Usage: stages list [flags]",
+		"Стадия\tПараметры\tОписание":               "Stage\tParameters\tDescription",
+		"Любой стадии можно задать параметры %s (емкость очереди на входе) и %s (перезапуск после паники: never, on-failure или always).": "Any stage also accepts %s (input queue capacity) and %s (restart after panic: never, on-failure or always).",
+
+		// Панель -tui
+		"работает":      "running",
+		"приостановлен": "paused",
+		"Пайплайн: источник %s, %s, время работы %s":                                       "Pipeline: source %s, %s, uptime %s",
+		"Прочитано %d, некорректных %d, выведено %d, ошибок стадий %d, отправок буфера %d": "Read %d, invalid %d, emitted %d, stage errors %d, buffer flushes %d",
+		"Стадия":   "Stage",
+		"Принято":  "Received",
+		"Передано": "Passed",
+		"Вход/с":   "In/s",
+		"Выход/с":  "Out/s",
+		"Очередь":  "Queue",
+		"Буфер %s %d/%d, потеряно при переполнении %d":          "Buffer %s %d/%d, lost on overflow %d",
+		"Отброшено стадиями: %d":                                "Dropped by stages: %d",
+		"Последние значения: ":                                  "Recent values: ",
+		"Журнал:":                                               "Log:",
+		"p - пауза/продолжение, f - отправка буфера, q - выход": "p - pause/resume, f - flush buffer, q - quit",
+		"Клавиши недоступны: нет терминала; выход - Ctrl+C":     "Keys unavailable: no terminal; quit with Ctrl+C",
+		"завершение: обработка оставшихся значений":             "shutting down: processing remaining values",
+		"источник приостановлен":                                "source paused",
+		"источник уже приостановлен":                            "source is already paused",
+		"чтение источника возобновлено":                         "source resumed",
+		"источник не приостановлен":                             "source is not paused",
+		"запрошена отправка буфера":                             "buffer flush requested",
+
+		// Описания стадий (stages list)
+		"агрегат func окон по size значений или по времени interval":                               "aggregate func over windows of size values or of interval duration",
+		"пометка выбросов, отклоняющихся от окна window больше чем на sigmas σ":                    "mark outliers deviating from the window of window values by more than sigmas σ",
+		"кольцевой буфер размера size с отправкой по flush_interval":                               "ring buffer of size values flushed every flush_interval",
+		"удаление повторов: подряд идущих или в окне size или ttl":                                 "drop duplicates: consecutive or within a window of size or ttl",
+		"обработка значений внешним процессом command по строкам JSON":                             "process values with an external command over JSON lines",
+		"отбрасывание значений, для которых выражение expression над x ложно":                      "drop values for which expression over x is false",
+		"отбрасывание значений, не прошедших фильтр predicate (например, range:0-100)":             "drop values not matching predicate (e.g. range:0-100)",
+		"отбрасывание чисел, не кратных 3, и нуля":                                                 "drop numbers not divisible by 3, and zero",
+		"отбрасывание отрицательных чисел":                                                         "drop negative numbers",
+		"преобразование значений функцией func (например, scale:2)":                                "transform values with func (e.g. scale:2)",
+		"стадия из модуля Go path (символ symbol)":                                                 "stage from Go plugin path (symbol symbol)",
+		"ограничение скорости rate (например, 100/s) с запасом burst":                              "limit throughput to rate (e.g. 100/s) with burst",
+		"разветвление по условиям when ветвей branches":                                            "route values to branches by their when conditions",
+		"выборка части значений: каждое n-е, percent процентов или резервуар size":                 "sample values: every n-th, percent of them or a reservoir of size",
+		"пропуск значения, не менявшегося в течение duration":                                      "pass a value once it has not changed for duration",
+		"наиболее частое значение каждого окна interval":                                           "most frequent value of each interval window",
+		"Задержка от входа до выхода:":                                                             "End-to-end latency:",
+		"Отправлено: %d, получено: %d, время: %s\n":                                                "Sent: %d, received: %d, elapsed: %s\n",
+		"Пропускная способность: вход %.0f значений/с, выход %.0f значений/с\n":                    "Throughput: in %.0f values/s, out %.0f values/s\n",
+		"Память: %d выделений (%.1f на значение), %d байт (%.0f на значение), сборок мусора: %d\n": "Memory: %d allocs (%.1f per value), %d bytes (%.0f per value), GC cycles: %d\n",
+		"стадия":      "stage",
+		"вход, зн/с":  "in, v/s",
+		"выход, зн/с": "out, v/s",
+		"выд./зн":     "allocs/v",
+		"байт/зн":     "bytes/v",
+		"%-6s %d значений за %s: %.0f значений/с\n": "%-6s %d values in %s: %.0f values/s\n",
+
+		// Журнал
+		"Программа запущена. Начинайте вводить числа":                     "Started. Enter numbers",
+		"Ошибка запуска панели":                                           "Failed to start dashboard",
+		"Программа запущена. Чтение stdin":                                "Started. Reading stdin",
+		"Перечитывание конфигурации по сигналу недоступно в этой системе": "Reloading configuration on signal is not supported on this system",
+		"Программа запущена. Чтение файлов":                               "Started. Reading files",
+		"Программа запущена. Чтение топика Kafka":                         "Started. Reading Kafka topic",
+		"Программа запущена. Чтение Redis":                                "Started. Reading Redis",
+		"Программа запущена. Подписка на топик MQTT":                      "Started. Subscribed to MQTT topic",
+		"Подключение к брокеру MQTT установлено":                          "Connected to MQTT broker",
+		"Соединение с брокером MQTT потеряно, переподключение":            "Lost connection to MQTT broker, reconnecting",
+		"Ошибка подписки на топик MQTT":                                   "Failed to subscribe to MQTT topic",
+		"Программа запущена. Прием чисел по сети":                         "Started. Accepting numbers over the network",
+		"Программа запущена. Прием чисел по HTTP":                         "Started. Accepting numbers over HTTP",
+		"Программа запущена. Прием значений по gRPC":                      "Started. Accepting values over gRPC",
+		"Программа запущена. Воспроизведение журнала сеанса":              "Started. Replaying session log",
+		"Программа завершена":                                             "Stopped",
+		"Программа завершена по запросу пользователя":                     "Stopped by user request",
+		"Программа завершена без дообработки значений":                    "Stopped without draining values",
+		"Повторное прерывание: немедленное завершение":                    "Second interrupt: exiting immediately",
+		"Завершение: источник остановлен, обработка оставшихся значений":  "Shutting down: source stopped, draining remaining values",
+		"Завершение: новые вызовы не принимаются, ожидание текущих":       "Shutting down: rejecting new calls, waiting for active ones",
+		"Истекло время дообработки, оставшиеся значения не выводятся":     "Drain timeout expired, remaining values are not written",
+		"Истекло время дообработки, вызовы прерваны":                      "Drain timeout expired, calls aborted",
+		"Значения не доставлены за время дообработки":                     "Values not delivered within drain timeout",
+		"Конец ввода: все значения обработаны, программа завершена":       "End of input: all values processed, exiting",
+		"Ввод завершен":                           "Input finished",
+		"Некорректный ввод":                       "Invalid input",
+		"Некорректный ввод. Введите число":        "Invalid input. Enter a number",
+		"Нет входных данных":                      "No input",
+		"Нет входных данных, источник остановлен": "No input, source stopped",
+		"Источник приостановлен":                  "Source paused",
+		"Чтение источника возобновлено":           "Source resumed",
+		"Ошибка конфигурации":                     "Configuration error",
+		"Ошибка конфигурации генератора":          "Generator configuration error",
+		"Ошибка запуска пайплайна":                "Failed to start pipeline",
+		"Ошибка запуска источника данных":         "Failed to start data source",
+		"Ошибка запуска HTTP-сервера":             "Failed to start HTTP server",
+		"Ошибка запуска gRPC-сервера":             "Failed to start gRPC server",
+		"Ошибка HTTP-сервера":                     "HTTP server error",
+		"Ошибка gRPC-сервера":                     "gRPC server error",
+		"HTTP-сервер запущен":                     "HTTP server started",
+		"Ошибка запуска сервера диагностики":      "Failed to start diagnostics server",
+		"Сервер диагностики запущен":              "Diagnostics server started",
+		"Ошибка чтения":                           "Read error",
+		"Ошибка чтения входных данных":            "Input read error",
+		"Ошибка чтения Redis":                     "Redis read error",
+		"Ошибка отправки":                         "Send error",
+		"Ошибка отправки в Redis":                 "Redis send error",
+		"Ошибка вывода данных":                    "Output error",
+		"Ошибка записи в приемник, повтор":        "Sink write error, retrying",
+		"Партия не записана после повторов, значения записаны в файл недоставленных значений": "Batch not written after retries, values written to the dead letter file",
+		"Ошибка вывода партии, партия будет отправлена повторно":                              "Batch output error, the batch will be resent",
+		"Ошибка открытия приемника":                                                           "Failed to open sink",
+		"Ошибка открытия файла недоставленных значений":                                       "Failed to open dead-letter file",
+		"Ошибка записи в файл недоставленных значений":                                        "Failed to write dead-letter file",
+		"Ошибка открытия управляющего сокета":                                                 "Failed to open control socket",
+		"Ошибка управляющего сокета":                                                          "Control socket error",
+		"Ошибка записи итогов работы":                                                         "Failed to write run summary",
+		"Ошибка записи журнала сеанса, запись прекращена":                                     "Session log write error, recording stopped",
+		"Ошибка удаления прежнего файла вывода":                                               "Failed to remove old output file",
+		"Ошибка запуска трассировки":                                                          "Failed to start tracing",
+		"Ошибка отправки трасс":                                                               "Failed to export traces",
+		"Трассы экспортируются по OTLP":                                                       "Exporting traces over OTLP",
+		"Ошибка обработки значения":                                                           "Value processing error",
+		"Превышено время обработки значения, значение отброшено":                              "Value processing timed out, value dropped",
+		"Ошибка стадии":                                                                       "Stage error",
+		"Паника стадии":                                                                       "Stage panic",
+		"Стадия перезапущена, ее состояние сброшено":                                          "Stage restarted, its state was reset",
+		"Стадия завершилась до закрытия входа":                                                "Stage exited before its input was closed",
+		"Ошибка контрольной точки":                                                            "Checkpoint error",
+		"Ошибка чтения контрольной точки":                                                     "Failed to read checkpoint",
+		"Контрольная точка записана":                                                          "Checkpoint written",
+		"Продолжение с контрольной точки":                                                     "Resuming from checkpoint",
+		"Список входных файлов изменился с момента контрольной точки":                         "Input file list changed since checkpoint",
+		"Ошибка перечитывания конфигурации, действует прежняя":                                "Failed to reload configuration, keeping previous one",
+		"Ошибка перезапуска стадий, действует прежняя конфигурация":                           "Failed to restart stages, keeping previous configuration",
+		"Переход к нескольким пайплайнам требует перезапуска программы, действует прежняя конфигурация": "Switching to multiple pipelines requires a restart, keeping previous configuration",
+		"Конфигурация перечитана, изменений нет":                                                        "Configuration reloaded, no changes",
+		"Конфигурация перечитана, параметры буфера изменены без перезапуска":                            "Configuration reloaded, buffer settings changed without restart",
+		"Конфигурация перечитана, изменения потребовали перезапуска стадий, их состояние сброшено":      "Configuration reloaded, stages restarted and their state reset",
+		"Ошибка изменения параметров буфера":                                                            "Failed to change buffer settings",
+		"Размер буфера изменен":                     "Buffer size changed",
+		"Интервал отправки буфера изменен":          "Buffer flush interval changed",
+		"Настройки изменены":                        "Settings changed",
+		"Значение потеряно при переполнении буфера": "Value lost on buffer overflow",
+		"Заполненность очереди":                     "Queue occupancy",
+		"Очередь на входе стадии заполнена: стадия не успевает обрабатывать вход": "Stage input queue is full: stage cannot keep up",
+		"Очередь на входе стадии освободилась":                                    "Stage input queue drained",
+		"Обработанные данные записываются в файл":                                 "Writing processed data to file",
+		"Обработанные данные отправляются получателю":                             "Forwarding processed data",
+		"Обработанные данные отправляются в Kafka":                                "Sending processed data to Kafka",
+		"Обработанные данные публикуются в MQTT":                                  "Publishing processed data to MQTT",
+		"Обработанные данные записываются в базу данных":                          "Writing processed data to the database",
+		"Обработанные данные отправляются в Redis":                                "Sending processed data to Redis",
+		"Файл вывода ротирован":                                                   "Output file rotated",
+		"Перехвачены незавершенные записи Redis":                                  "Claimed pending Redis entries",
+		"Клиент подключен":                                                        "Client connected",
+		"Клиент отключился":                                                       "Client disconnected",
+		"Клиент не успевает принимать данные и отключен":                          "Client too slow, disconnected",
+		"Поток клиента закрыт":                                                    "Client stream closed",
+		"Результат завершенного вызова отброшен":                                  "Result of finished call dropped",
+		"Пайплайн запущен":                                                        "Pipeline started",
+		"Пайплайн завершен":                                                       "Pipeline finished",
+		"Пайплайн завершен, перезапуск":                                           "Pipeline finished, restarting",
+
+		// Причины перезапуска и отчеты проб
+		"стадия завершилась до закрытия входа": "stage exited before its input was closed",
+		"стадия %s завершилась аварийно":       "stage %s failed",
+	},
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// setLang - язык сообщений l на время теста.
+func setLang(t *testing.T, l string) {
+	saved := lang
+	t.Cleanup(func() { lang = saved })
+	lang = l
+}
+
+func TestTranslations(t *testing.T) {
+	setLang(t, langEN)
+	for _, s := range []string{
+		"Ошибка конфигурации:",
+		"Стадия завершилась до закрытия входа",
+		"стадия %s завершилась аварийно",
+	} {
+		if tr(s) == s {
+			t.Errorf("нет перевода %q", s)
+		}
+	}
+	lang = langRU
+	if got := tr("Ошибка конфигурации:"); got != "Ошибка конфигурации:" {
+		t.Fatalf("tr на русском = %q", got)
+	}
+}
+
+// TestRestartCauseTranslated - причина перезапуска стадии, завершившейся до
+// закрытия входа, переводится.
+func TestRestartCauseTranslated(t *testing.T) {
+	setLang(t, langEN)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	causes := make(chan error, 1)
+	stage := pipeline.Supervise("exits", func(ctx context.Context, in <-chan int, out chan<- int) {
+		close(out)
+	}, pipeline.SuperviseOptions{
+		Policy:      pipeline.RestartAlways,
+		MaxRestarts: 1,
+		MinBackoff:  time.Millisecond,
+		OnRestart:   func(_ int, cause error) { causes <- cause },
+	})
+	in, out := make(chan int), make(chan int)
+	go stage(ctx, in, out)
+	select {
+	case cause := <-causes:
+		if tr(cause.Error()) == cause.Error() {
+			t.Fatalf("нет перевода причины %q", cause)
+		}
+	case <-ctx.Done():
+		t.Fatal("стадия не перезапущена")
+	}
+	close(in)
+	for range out {
+	}
+}
+
+// TestConfigErrorTranslated - ошибка переменной окружения выводится на языке
+// -lang.
+func TestConfigErrorTranslated(t *testing.T) {
+	setLang(t, langRU)
+	t.Setenv(envBufferSize, "много")
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	_, err = parseConfig(flag.NewFlagSet("run", flag.ContinueOnError), []string{"-lang", "en"})
+	os.Stderr = stderr
+	w.Close()
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if err == nil {
+		t.Fatal("некорректная переменная окружения принята")
+	}
+	if !strings.HasPrefix(string(data), "Configuration error:") {
+		t.Fatalf("вывод %q, ожидалось сообщение на английском", data)
+	}
+}
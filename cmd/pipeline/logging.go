@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Форматы журнала.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// newLogger - создание журнала с уровнем level (debug, info, warn, error)
+// и форматом format (text или json), пишущего в w. Сообщения переводятся
+// на язык -lang.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("неизвестный уровень журнала %q (ожидается debug, info, warn или error)", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case logFormatText:
+		return slog.New(translatingHandler{slog.NewTextHandler(w, opts)}), nil
+	case logFormatJSON:
+		return slog.New(translatingHandler{slog.NewJSONHandler(w, opts)}), nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат журнала %q (ожидается text или json)", format)
+	}
+}
+
+// stageLog - журнал с меткой стадии stage.
+func stageLog(stage string) *slog.Logger {
+	return slog.With("stage", stage)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Виды источников, объединяемых флагом -merge. Каждый вид, кроме stdin,
+// настраивается собственными флагами. Kafka и Redis подтверждают обработку по
+// количеству обработанных значений и поэтому не объединяются с другими.
+const (
+	sourceStdin  = "stdin"  // Консоль
+	sourceInput  = "input"  // Файлы -input или -input-dir
+	sourceListen = "listen" // Сетевые клиенты -listen
+	sourceIngest = "ingest" // HTTP-запросы -ingest
+	sourceMQTT   = "mqtt"   // Топик MQTT -mqtt-topic
+)
+
+// sourceKinds - виды объединяемых источников.
+var sourceKinds = []string{sourceStdin, sourceInput, sourceListen, sourceIngest, sourceMQTT}
+
+// mergedSource - источник в объединении -merge и его вес.
+type mergedSource struct {
+	kind   string
+	weight int
+}
+
+// parseMerge - разбор списка -merge вида stdin,listen=2,input.
+func parseMerge(s string) ([]mergedSource, error) {
+	var sources []mergedSource
+	for _, part := range strings.Split(s, ",") {
+		kind, w, hasWeight := strings.Cut(strings.TrimSpace(part), "=")
+		if !slices.Contains(sourceKinds, kind) {
+			return nil, fmt.Errorf("merge: неизвестный источник %q (доступны: %s)", kind, strings.Join(sourceKinds, ", "))
+		}
+		weight := 1
+		if hasWeight {
+			n, err := strconv.Atoi(w)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("merge: вес источника %s должен быть положительным целым: %q", kind, w)
+			}
+			weight = n
+		}
+		if slices.ContainsFunc(sources, func(m mergedSource) bool { return m.kind == kind }) {
+			return nil, fmt.Errorf("merge: источник %s указан дважды", kind)
+		}
+		sources = append(sources, mergedSource{kind: kind, weight: weight})
+	}
+	return sources, nil
+}
+
+// configured - заданы ли флаги источника вида kind (консоль не требует флагов).
+func (c config) configured(kind string) bool {
+	switch kind {
+	case sourceInput:
+		return c.input != "" || c.inputDir != ""
+	case sourceListen:
+		return c.listen != ""
+	case sourceIngest:
+		return c.ingest != ""
+	case sourceMQTT:
+		return c.mqttTopic != ""
+	}
+	return true
+}
+
+// only - конфигурация с единственным источником вида kind из c.
+func (c config) only(kind string) config {
+	s := c
+	s.merge = ""
+	if kind != sourceInput {
+		s.input, s.inputDir = "", ""
+	}
+	if kind != sourceListen {
+		s.listen = ""
+	}
+	if kind != sourceIngest {
+		s.ingest = ""
+	}
+	if kind != sourceMQTT {
+		s.mqttTopic = ""
+	}
+	return s
+}
+
+// readsStdin - читает ли конфигурация консоль.
+func (c config) readsStdin() bool {
+	if c.merge == "" {
+		return sourceName(c) == sourceStdin
+	}
+	sources, _ := parseMerge(c.merge) // Проверено в validate
+	return slices.ContainsFunc(sources, func(m mergedSource) bool { return m.kind == sourceStdin })
+}
+
+// validateMerge - проверка -merge: каждый источник списка настроен, и все
+// настроенные источники входят в список.
+func (c config) validateMerge() error {
+	sources, err := parseMerge(c.merge)
+	if err != nil {
+		return err
+	}
+	for _, m := range sources {
+		if !c.configured(m.kind) {
+			return fmt.Errorf("merge: не заданы флаги источника %s", m.kind)
+		}
+	}
+	for _, kind := range sourceKinds {
+		if kind != sourceStdin && c.configured(kind) && !slices.ContainsFunc(sources, func(m mergedSource) bool { return m.kind == kind }) {
+			return fmt.Errorf("merge: источник %s задан флагами, но не указан в списке", kind)
+		}
+	}
+	switch {
+	case c.replayPath != "" || c.acksSource():
+		return fmt.Errorf("флаг merge не поддерживается с -replay, -kafka-topic и -source")
+	case c.checkpointPath != "":
+		return fmt.Errorf("контрольная точка не поддерживается для нескольких источников -merge")
+	}
+	return nil
+}
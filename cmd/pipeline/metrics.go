@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// stageMetric - метрики одной стадии цепочки.
+type stageMetric struct {
+	index  int    // Позиция стадии в цепочке (с 1)
+	name   string // Имя стадии в реестре
+	stage  pipeline.StageMetrics
+	buffer *pipeline.BufferMetrics // Только для стадии buffer
+	queue  *pipeline.Queue         // Очередь на входе стадии (nil - без очереди)
+
+	running atomic.Bool   // Горутина стадии работает
+	failed  atomic.Bool   // Стадия завершилась до закрытия входа
+	slow    atomic.Uint64 // Значения, не обработанные за время timeout
+}
+
+// watermarkLogInterval - минимальный интервал между сообщениями о заполнении очереди.
+const watermarkLogInterval = 10 * time.Second
+
+// newStageQueue - очередь емкости n на входе стадии sm с выводом отметок
+// заполненности в журнал (о заполнении - не чаще watermarkLogInterval).
+func newStageQueue(sm *stageMetric, n int) *pipeline.Queue {
+	log := stageLog(sm.name).With("index", sm.index, "cap", n)
+	var (
+		mu       sync.Mutex
+		last     time.Time
+		episodes int // Заполнения очереди с предыдущего сообщения
+	)
+	return &pipeline.Queue{
+		Cap: n,
+		OnHigh: func(depth int) {
+			mu.Lock()
+			defer mu.Unlock()
+			episodes++
+			if time.Since(last) < watermarkLogInterval {
+				return
+			}
+			log.Warn("Очередь на входе стадии заполнена: стадия не успевает обрабатывать вход",
+				"depth", depth, "episodes", episodes)
+			last, episodes = time.Now(), 0
+		},
+		OnLow: func(depth int) {
+			log.Debug("Очередь на входе стадии освободилась", "depth", depth)
+		},
+	}
+}
+
+// metrics - метрики пайплайна в формате Prometheus. Методы допускают nil-получатель.
+type metrics struct {
+	mu       sync.Mutex
+	stages   []*stageMetric
+	latency  *pipeline.LatencyRecorder[envelope]
+	endToEnd *pipeline.ItemLatencyRecorder[pipeline.Num]
+	control  *runControl              // Настройки, изменяемые во время работы (nil - не выводятся)
+	acks     *pipeline.Acks[envelope] // Подтверждение вывода партий (nil - без -ack)
+}
+
+// newMetrics - создание набора метрик.
+func newMetrics() *metrics {
+	return &metrics{}
+}
+
+// setStages - замена метрик стадий (например, после перезагрузки цепочки).
+func (m *metrics) setStages(stages []*stageMetric) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stages = stages
+}
+
+// stageList - текущие метрики стадий.
+func (m *metrics) stageList() []*stageMetric {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stages
+}
+
+// setLatency - задание гистограмм интервалов на выходе пайплайна и задержки
+// от входа до выхода.
+func (m *metrics) setLatency(r *pipeline.LatencyRecorder[envelope], e *pipeline.ItemLatencyRecorder[pipeline.Num]) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency, m.endToEnd = r, e
+}
+
+// setControl - задание управления запуском, настройки которого выводятся в метриках.
+func (m *metrics) setControl(rc *runControl) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.control = rc
+}
+
+// setAcks - задание подтверждения вывода партий буфера.
+func (m *metrics) setAcks(acks *pipeline.Acks[envelope]) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acks = acks
+}
+
+// ServeHTTP - вывод метрик в текстовом формате Prometheus.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write - запись метрик в текстовом формате Prometheus.
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	stages, latency, endToEnd, rc, acks := m.stages, m.latency, m.endToEnd, m.control, m.acks
+	m.mu.Unlock()
+
+	counter := func(name, help string, value func(sm *stageMetric) uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, sm := range stages {
+			fmt.Fprintf(w, "%s{stage=%q,index=\"%d\"} %d\n", name, sm.name, sm.index, value(sm))
+		}
+	}
+	counter("pipeline_stage_received_total", "Значения, принятые стадией.", func(sm *stageMetric) uint64 {
+		return sm.stage.Received.Load()
+	})
+	counter("pipeline_stage_passed_total", "Значения, переданные стадией дальше.", func(sm *stageMetric) uint64 {
+		return sm.stage.Passed.Load()
+	})
+	counter("pipeline_stage_dropped_total", "Значения, отброшенные стадией.", func(sm *stageMetric) uint64 {
+		dropped := sm.stage.Dropped()
+		if sm.buffer != nil {
+			// Значения в буфере и файле сброса еще не отброшены
+			dropped -= min(dropped, uint64(sm.buffer.Occupancy.Load()+sm.buffer.Spilled.Load()))
+		}
+		return dropped
+	})
+	counter("pipeline_slow_items_total", "Значения, обработка которых превысила время timeout стадии.", func(sm *stageMetric) uint64 {
+		return sm.slow.Load()
+	})
+
+	gauge := func(name, help string, value func(q *pipeline.Queue) int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, sm := range stages {
+			if sm.queue != nil {
+				fmt.Fprintf(w, "%s{stage=%q,index=\"%d\"} %d\n", name, sm.name, sm.index, value(sm.queue))
+			}
+		}
+	}
+	gauge("pipeline_stage_queue_depth", "Значения в очереди на входе стадии.", (*pipeline.Queue).Depth)
+	gauge("pipeline_stage_queue_capacity", "Емкость очереди на входе стадии.", func(q *pipeline.Queue) int { return q.Cap })
+
+	fmt.Fprintf(w, "# HELP pipeline_buffer_occupancy Текущее количество элементов в кольцевом буфере.\n# TYPE pipeline_buffer_occupancy gauge\n")
+	for _, sm := range stages {
+		if sm.buffer != nil {
+			fmt.Fprintf(w, "pipeline_buffer_occupancy{index=\"%d\"} %d\n", sm.index, sm.buffer.Occupancy.Load())
+		}
+	}
+	fmt.Fprintf(w, "# HELP pipeline_buffer_spilled Текущее количество значений в файле сброса буфера на диск.\n# TYPE pipeline_buffer_spilled gauge\n")
+	for _, sm := range stages {
+		if sm.buffer != nil {
+			fmt.Fprintf(w, "pipeline_buffer_spilled{index=\"%d\"} %d\n", sm.index, sm.buffer.Spilled.Load())
+		}
+	}
+	fmt.Fprintf(w, "# HELP pipeline_buffer_flushes_total Количество непустых отправок буфера.\n# TYPE pipeline_buffer_flushes_total counter\n")
+	for _, sm := range stages {
+		if sm.buffer != nil {
+			fmt.Fprintf(w, "pipeline_buffer_flushes_total{index=\"%d\"} %d\n", sm.index, sm.buffer.Flushes.Load())
+		}
+	}
+	fmt.Fprintf(w, "# HELP pipeline_buffer_dropped_total Значения, потерянные при переполнении буфера.\n# TYPE pipeline_buffer_dropped_total counter\n")
+	for _, sm := range stages {
+		if sm.buffer != nil {
+			fmt.Fprintf(w, "pipeline_buffer_dropped_total{index=\"%d\"} %d\n", sm.index, sm.buffer.Dropped.Load())
+		}
+	}
+
+	if acks != nil {
+		fmt.Fprintf(w, "# HELP pipeline_batches_inflight Партии буфера, вывод которых не подтвержден.\n# TYPE pipeline_batches_inflight gauge\npipeline_batches_inflight %d\n", acks.InFlight())
+		fmt.Fprintf(w, "# HELP pipeline_batch_retries_total Повторные отправки партий после ошибки вывода.\n# TYPE pipeline_batch_retries_total counter\npipeline_batch_retries_total %d\n", acks.Retried())
+	}
+
+	if rc != nil {
+		s := rc.buffer.Settings()
+		paused := 0
+		if rc.gate.paused() {
+			paused = 1
+		}
+		fmt.Fprintf(w, "# HELP pipeline_buffer_size Текущий размер кольцевого буфера.\n# TYPE pipeline_buffer_size gauge\npipeline_buffer_size %d\n", s.Size)
+		fmt.Fprintf(w, "# HELP pipeline_buffer_flush_interval_seconds Текущий интервал отправки буфера.\n# TYPE pipeline_buffer_flush_interval_seconds gauge\npipeline_buffer_flush_interval_seconds %g\n", s.FlushInterval.Seconds())
+		fmt.Fprintf(w, "# HELP pipeline_source_paused Источник приостановлен (1) или нет (0).\n# TYPE pipeline_source_paused gauge\npipeline_source_paused %d\n", paused)
+	}
+
+	if latency != nil {
+		writeHistogram(w, "pipeline_output_interarrival_seconds",
+			"Интервалы между соседними значениями на выходе пайплайна.", latency.Snapshot())
+	}
+	if endToEnd != nil {
+		writeHistogram(w, "pipeline_end_to_end_latency_seconds",
+			"Задержка значений от поступления в пайплайн до выхода.", endToEnd.Snapshot())
+	}
+}
+
+// writeHistogram - запись гистограммы h с накопленными корзинами.
+func writeHistogram(w io.Writer, name, help string, h pipeline.LatencyHistogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative uint64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound.Seconds(), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.Count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.Sum.Seconds())
+	fmt.Fprintf(w, "%s_count %d\n", name, h.Count)
+}
+
+// reportQueues - периодический вывод заполненности очередей стадий в журнал
+// до отмены ctx: текущая и наибольшая за интервал.
+func (m *metrics) reportQueues(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			stages := m.stages
+			m.mu.Unlock()
+			for _, sm := range stages {
+				if sm.queue == nil {
+					continue
+				}
+				stageLog(sm.name).Info("Заполненность очереди",
+					"index", sm.index, "depth", sm.queue.Depth(), "peak", sm.queue.TakePeak(),
+					"cap", sm.queue.Cap, "congested", sm.queue.Congested())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Параметры повторного подключения к получателю.
+const (
+	forwardMinBackoff = 100 * time.Millisecond
+	forwardMaxBackoff = 10 * time.Second
+)
+
+// splitAddr - разбор адреса вида "unix:/path" или "host:port" (TCP).
+func splitAddr(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", addr
+}
+
+// listenInts - прием чисел, разделенных переводом строки, от TCP- или
+// Unix-клиентов в input в формате конфигурации cfg. Некорректные строки
+// передаются в rej. Работает до отмены ctx.
+func listenInts(ctx context.Context, ln net.Listener, cfg config, rej *rejects, input chan<- pipeline.Num) error {
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer conn.Close()
+			closeConn := context.AfterFunc(ctx, func() { conn.Close() })
+			defer closeConn()
+
+			remote := conn.RemoteAddr().String()
+			src := recordSource(newInputSource(conn, cfg, rej.report(remote)), rej.rec)
+			if err := pipeline.Feed[pipeline.Num](ctx, src, input); err != nil && ctx.Err() == nil {
+				stageLog("source").Error("Ошибка чтения", "remote", remote, "err", err)
+			}
+		}()
+	}
+}
+
+// forwarder - приемник, отправляющий обработанные числа получателю
+// с переподключением и экспоненциальной задержкой между попытками.
+type forwarder struct {
+	ctx     context.Context
+	network string
+	address string
+	conn    net.Conn
+}
+
+// newForwarder - создание отправителя на адрес addr.
+// Повторные попытки прекращаются при отмене ctx.
+func newForwarder(ctx context.Context, addr string) *forwarder {
+	network, address := splitAddr(addr)
+	return &forwarder{ctx: ctx, network: network, address: address}
+}
+
+// Write - отправка числа n. Повторяет попытки до успеха или отмены контекста.
+func (f *forwarder) Write(n pipeline.Num) error {
+	ctx := f.ctx
+	backoff := forwardMinBackoff
+	line := n.String() + "\n"
+	for {
+		err := f.connect(ctx)
+		if err == nil {
+			if _, err = f.conn.Write([]byte(line)); err == nil {
+				return nil
+			}
+			f.conn.Close()
+			f.conn = nil
+		}
+		stageLog("sink").Warn("Ошибка отправки", "addr", f.address, "err", err, "retry_in", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, forwardMaxBackoff)
+	}
+}
+
+// Flush - ничего не делает: числа отправляются сразу.
+func (f *forwarder) Flush() error { return nil }
+
+// connect - установка соединения, если оно отсутствует.
+func (f *forwarder) connect(ctx context.Context) error {
+	if f.conn != nil {
+		return nil
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, f.network, f.address)
+	if err != nil {
+		return err
+	}
+	f.conn = conn
+	return nil
+}
+
+// Close - закрытие соединения с получателем.
+func (f *forwarder) Close() error {
+	if f.conn == nil {
+		return nil
+	}
+	return f.conn.Close()
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// rotatedLayout - формат момента ротации в имени прежнего файла вывода.
+const rotatedLayout = "2006-01-02T15-04-05.000"
+
+// byteUnits - множители единиц размера (степени 1024).
+var byteUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseByteSize - разбор размера вида 100MB, 512K или 1048576 (байт).
+func parseByteSize(s string) (int64, error) {
+	num, mult := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("ожидается положительный размер (например, 100MB): %q", s)
+	}
+	return n * mult, nil
+}
+
+// rotatingFile - файл вывода с ротацией по размеру и времени: текущий файл
+// переименовывается в path.<момент ротации>, и запись продолжается в новый.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64         // Размер, при превышении которого файл ротируется (0 - без ограничения)
+	interval time.Duration // Время записи в один файл (0 - без ограничения)
+	keep     int           // Количество сохраняемых прежних файлов (0 - все)
+	f        *os.File
+	size     int64
+	opened   time.Time
+}
+
+// openRotatingFile - открытие файла path для дописывания.
+func openRotatingFile(path string, maxSize int64, interval time.Duration, keep int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, interval: interval, keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open - открытие текущего файла с учетом уже записанного размера.
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// Write - запись p с предварительной ротацией, если p не помещается
+// в текущий файл или истекло время записи в него. Запись p не разбивается
+// между файлами.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	full := r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize
+	expired := r.interval > 0 && time.Since(r.opened) >= r.interval
+	if full || expired {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate - переименование текущего файла, открытие нового и удаление
+// прежних файлов сверх r.keep.
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	// Имя прежнего файла не должно совпасть с ротированным в ту же миллисекунду
+	at := time.Now()
+	name := r.path + "." + at.Format(rotatedLayout)
+	for _, err := os.Stat(name); err == nil; _, err = os.Stat(name) {
+		at = at.Add(time.Millisecond)
+		name = r.path + "." + at.Format(rotatedLayout)
+	}
+	if err := os.Rename(r.path, name); err != nil {
+		return fmt.Errorf("ротация %s: %w", r.path, err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	stageLog("sink").Info("Файл вывода ротирован", "path", r.path)
+	if r.keep > 0 {
+		// Имена с моментом ротации упорядочены по времени
+		old, _ := filepath.Glob(r.path + ".????-??-??T??-??-??.???")
+		for len(old) > r.keep {
+			if err := os.Remove(old[0]); err != nil {
+				stageLog("sink").Warn("Ошибка удаления прежнего файла вывода", "path", old[0], "err", err)
+			}
+			old = old[1:]
+		}
+	}
+	return nil
+}
+
+// Close - закрытие текущего файла.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// multiSink - приемник, передающий каждое значение всем приемникам по очереди.
+// Ошибки приемников объединяются; значение передается всем, даже если
+// один из них вернул ошибку.
+type multiSink []pipeline.Sink[pipeline.Num]
+
+// Write - передача значения n всем приемникам.
+func (m multiSink) Write(n pipeline.Num) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Write(n))
+	}
+	return errors.Join(errs...)
+}
+
+// WriteItem - передача значения it всем приемникам, с метаданными - тем,
+// которые их поддерживают.
+func (m multiSink) WriteItem(it envelope) error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, writeItem(s, it))
+	}
+	return errors.Join(errs...)
+}
+
+// Flush - сброс всех приемников.
+func (m multiSink) Flush() error {
+	var errs []error
+	for _, s := range m {
+		errs = append(errs, s.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Reset - отбрасывание неотправленных значений приемников.
+func (m multiSink) Reset() {
+	for _, s := range m {
+		resetSink(s)
+	}
+}
+
+// consoleOutput - выводятся ли обработанные данные в консоль: по -stdout
+// или если не задан ни один другой приемник.
+func (c config) consoleOutput() bool {
+	return c.stdout || (c.outputPath == "" && c.forward == "" && c.kafkaOutTopic == "" && c.sinkURL == "" && c.dbDSN == "" && c.mqttOutTopic == "")
+}
+
+// fileFormat - формат файла вывода -output: -output-format или формат
+// вывода -format.
+func (c config) fileFormat() string {
+	switch {
+	case c.outputFormat != "":
+		return c.outputFormat
+	case c.format == formatJSONL:
+		return formatJSONL
+	}
+	return formatText
+}
+
+// validateOutput - проверка флагов приемников.
+func (c config) validateOutput() error {
+	if c.outputFormat != "" && c.outputFormat != formatText && c.outputFormat != formatJSONL {
+		return fmt.Errorf("неизвестный формат output-format: %q (ожидается %s или %s)", c.outputFormat, formatText, formatJSONL)
+	}
+	if c.outputPath == "" && (c.outputFormat != "" || c.rotateSize != "" || c.rotateInterval != 0 || c.rotateKeep != 0) {
+		return fmt.Errorf("флаги output-format, rotate, rotate-interval и rotate-keep требуют -output")
+	}
+	if c.rotateSize != "" {
+		if _, err := parseByteSize(c.rotateSize); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+	if c.rotateInterval < 0 {
+		return fmt.Errorf("rotate-interval не может быть отрицательным: %s", c.rotateInterval)
+	}
+	if c.rotateKeep < 0 {
+		return fmt.Errorf("rotate-keep не может быть отрицательным: %d", c.rotateKeep)
+	}
+	if c.batchOutput && !c.consoleOutput() {
+		return fmt.Errorf("флаг batch-output поддерживается только для вывода в консоль")
+	}
+	return nil
+}
+
+// openSinks - открытие приемников конфигурации: консоль, файл с ротацией,
+// сетевой получатель, топики Kafka и MQTT, Redis и база данных. Значения передаются всем заданным
+// приемникам, каждый выводит их в своем формате. Отправка во внешние
+// приемники прекращается при отмене ctx. Запись в файл и внешние приемники
+// повторяется согласно -sink-retries, партии после исчерпания попыток
+// записываются в файл недоставленных значений dl (nil - ошибка возвращается).
+// Возвращает функцию закрытия приемников.
+func openSinks(ctx context.Context, cfg config, p runningPipeline, dl *deadLetter) (pipeline.Sink[pipeline.Num], func(), error) {
+	var sinks multiSink
+	var closers []io.Closer
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}
+	retrying := func(name string, s pipeline.Sink[pipeline.Num]) pipeline.Sink[pipeline.Num] {
+		if cfg.sinkRetries == 0 {
+			return s
+		}
+		return newRetryingSink(ctx, cfg, name, s, dl)
+	}
+
+	if cfg.outputPath != "" {
+		var maxSize int64
+		if cfg.rotateSize != "" {
+			maxSize, _ = parseByteSize(cfg.rotateSize) // Проверено в validate
+		}
+		f, err := openRotatingFile(cfg.outputPath, maxSize, cfg.rotateInterval, cfg.rotateKeep)
+		if err != nil {
+			return nil, nil, fmt.Errorf("файл вывода: %w", err)
+		}
+		closers = append(closers, f)
+		if cfg.fileFormat() == formatJSONL {
+			sinks = append(sinks, retrying("output", newJSONLSink(f, p.fileBatches)))
+		} else {
+			sinks = append(sinks, retrying("output", pipeline.NewWriterSink[pipeline.Num](f, "%s\n")))
+		}
+		stageLog("sink").Info("Обработанные данные записываются в файл", "path", cfg.outputPath, "format", cfg.fileFormat(), "rotate", cfg.rotateSize, "rotate_interval", cfg.rotateInterval)
+	}
+	if cfg.sinkURL != "" {
+		t, err := parseRedisURL(cfg.sinkURL)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("адрес приемника: %w", err)
+		}
+		rs := newRedisSink(ctx, t, cfg)
+		closers = append(closers, rs)
+		sinks = append(sinks, retrying("redis", rs))
+		stageLog("sink").Info("Обработанные данные отправляются в Redis", "addr", t.opts.Addr, "key", t.key, "type", t.kind)
+	}
+	if cfg.kafkaOutTopic != "" {
+		ks := newKafkaSink(ctx, cfg)
+		closers = append(closers, ks)
+		sinks = append(sinks, retrying("kafka", ks))
+		stageLog("sink").Info("Обработанные данные отправляются в Kafka", "topic", cfg.kafkaOutTopic)
+	}
+	if cfg.mqttOutTopic != "" {
+		ms := newMQTTSink(ctx, cfg)
+		closers = append(closers, ms)
+		sinks = append(sinks, retrying("mqtt", ms))
+		stageLog("sink").Info("Обработанные данные публикуются в MQTT", "broker", cfg.mqttBroker, "topic", cfg.mqttOutTopic, "qos", cfg.mqttQoS)
+	}
+	if cfg.dbDSN != "" {
+		ds, err := openDBSink(ctx, cfg, p.dbBatches)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		closers = append(closers, ds)
+		sinks = append(sinks, retrying("db", ds))
+		stageLog("sink").Info("Обработанные данные записываются в базу данных", "table", cfg.dbTable)
+	}
+	if cfg.forward != "" {
+		fwd := newForwarder(ctx, cfg.forward)
+		closers = append(closers, fwd)
+		sinks = append(sinks, retrying("forward", fwd))
+		stageLog("sink").Info("Обработанные данные отправляются получателю", "addr", cfg.forward)
+	}
+	switch {
+	case cfg.consoleOutput() && p.dashboard != nil:
+		sinks = append(sinks, dashboardSink{p.dashboard})
+	case cfg.consoleOutput():
+		sinks = append(sinks, consoleSink(cfg, p.batches))
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], closeAll, nil
+	}
+	return sinks, closeAll, nil
+}
+
+// retryPolicy - политика повтора записи во внешние приемники (-sink-retries).
+func (c config) retryPolicy(name string) pipeline.RetryPolicy {
+	return pipeline.RetryPolicy{
+		MaxAttempts: c.sinkRetries + 1,
+		MinBackoff:  c.sinkRetryBackoff,
+		MaxBackoff:  c.sinkRetryMaxBackoff,
+		Jitter:      c.sinkRetryJitter,
+		OnRetry: func(retry int, err error, wait time.Duration) {
+			stageLog("sink").Warn("Ошибка записи в приемник, повтор", "sink", name, "retry", retry, "err", err, "retry_in", wait)
+		},
+	}
+}
+
+// retryingSink - приемник значений с метаданными, повторяющий запись
+// партий (pipeline.RetrySink). Партии, не записанные за все попытки,
+// выводятся в файл недоставленных значений.
+type retryingSink struct {
+	r *pipeline.RetrySink[envelope]
+}
+
+// newRetryingSink - повтор записи в приемник sink с именем name согласно
+// -sink-retries; при заданном dl партии после исчерпания попыток записываются
+// в него, иначе ошибка возвращается.
+func newRetryingSink(ctx context.Context, cfg config, name string, sink pipeline.Sink[pipeline.Num], dl *deadLetter) retryingSink {
+	var exhausted func(batch []envelope, err error)
+	if dl != nil {
+		exhausted = func(batch []envelope, err error) {
+			stageLog("sink").Error("Партия не записана после повторов, значения записаны в файл недоставленных значений", "sink", name, "count", len(batch), "err", err)
+			for _, it := range batch {
+				dl.write(deadLetterRecord{Stage: "sink", Value: it.Value, Reason: fmt.Sprintf("%s: %v", name, err)})
+			}
+		}
+	}
+	return retryingSink{pipeline.NewRetrySink[envelope](ctx, itemsSink{sink}, cfg.retryPolicy(name), exhausted)}
+}
+
+// Write - запись значения n.
+func (s retryingSink) Write(n pipeline.Num) error { return s.r.Write(envelope{Value: n}) }
+
+// WriteItem - запись значения it с метаданными.
+func (s retryingSink) WriteItem(it envelope) error { return s.r.Write(it) }
+
+// Flush - сброс приемника.
+func (s retryingSink) Flush() error { return s.r.Flush() }
+
+// Reset - отбрасывание неотправленной партии.
+func (s retryingSink) Reset() { s.r.Reset() }
+
+// itemsSink - вывод значений с метаданными в приемник значений (например,
+// для повтора записи или в ветви route).
+type itemsSink struct {
+	sink pipeline.Sink[pipeline.Num]
+}
+
+// Write - вывод значения it.
+func (s itemsSink) Write(it envelope) error { return writeItem(s.sink, it) }
+
+// Flush - сброс приемника.
+func (s itemsSink) Flush() error { return s.sink.Flush() }
+
+// Reset - отбрасывание неотправленных значений приемника.
+func (s itemsSink) Reset() { resetSink(s.sink) }
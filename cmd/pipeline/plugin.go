@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"plugin"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// defaultPluginSymbol - имя экспортируемого символа стадии в подключаемом модуле.
+const defaultPluginSymbol = "Stage"
+
+// loadPluginStage - стадия из подключаемого модуля Go path (сборка с
+// -buildmode=plugin). Символ symbol - функция или переменная одного из типов:
+//
+//	func(context.Context, <-chan pipeline.Item[pipeline.Num], chan<- pipeline.Item[pipeline.Num])
+//	pipeline.Stage[pipeline.Item[pipeline.Num]]
+//	func(pipeline.Num) (pipeline.Num, bool, error)
+//	pipeline.ItemFunc[pipeline.Num]
+//
+// Для функции значения стадия не создается: возвращается сама функция,
+// которую вызывающий применяет к каждому значению с сохранением метаданных.
+// Модуль загружается один раз за время работы программы: повторная загрузка
+// того же файла возвращает уже загруженный модуль.
+func loadPluginStage(path, symbol string) (pipeline.Stage[envelope], pipeline.ItemFunc[pipeline.Num], error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("загрузка модуля: %w", err)
+	}
+	sym, err := p.Lookup(symbol)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch s := sym.(type) {
+	case func(context.Context, <-chan envelope, chan<- envelope):
+		return s, nil, nil
+	case *func(context.Context, <-chan envelope, chan<- envelope):
+		return *s, nil, nil
+	case *pipeline.Stage[envelope]:
+		return *s, nil, nil
+	case func(pipeline.Num) (pipeline.Num, bool, error):
+		return nil, s, nil
+	case *func(pipeline.Num) (pipeline.Num, bool, error):
+		return nil, *s, nil
+	case *pipeline.ItemFunc[pipeline.Num]:
+		return nil, *s, nil
+	}
+	return nil, nil, fmt.Errorf("символ %s модуля %s имеет неподдерживаемый тип %T", symbol, path, sym)
+}
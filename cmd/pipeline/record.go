@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// recordEntry - запись журнала сеанса: входное значение или некорректная строка
+// с моментом поступления.
+type recordEntry struct {
+	Time  time.Time     `json:"time"`
+	Value *pipeline.Num `json:"value,omitempty"`
+	Line  *string       `json:"line,omitempty"` // Некорректная входная строка
+}
+
+// recorder - запись входных данных в журнал сеанса (флаг -record).
+// Запись после Close игнорируется.
+type recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// openRecorder - создание журнала сеанса path (пусто - запись не ведется, nil).
+func openRecorder(path string) (*recorder, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// value - запись входного значения.
+func (r *recorder) value(n pipeline.Num) {
+	r.write(recordEntry{Time: time.Now(), Value: &n})
+}
+
+// invalid - запись некорректной входной строки.
+func (r *recorder) invalid(line string) {
+	r.write(recordEntry{Time: time.Now(), Line: &line})
+}
+
+// write - запись e в журнал; ошибка записи выводится в журнал программы,
+// и запись прекращается.
+func (r *recorder) write(e recordEntry) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return
+	}
+	if err := r.enc.Encode(e); err != nil {
+		stageLog("record").Error("Ошибка записи журнала сеанса, запись прекращена", "err", err)
+		r.f.Close()
+		r.f = nil
+	}
+}
+
+// Close - закрытие журнала сеанса.
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// recordedSource - источник, записывающий возвращаемые значения в журнал сеанса.
+// Значения записываются в той же горутине, что и некорректные строки источника,
+// поэтому порядок записей совпадает с порядком ввода.
+type recordedSource struct {
+	pipeline.Source[pipeline.Num]
+	rec *recorder
+}
+
+// recordSource - src с записью значений в журнал rec (nil - src без изменений).
+func recordSource(src pipeline.Source[pipeline.Num], rec *recorder) pipeline.Source[pipeline.Num] {
+	if rec == nil {
+		return src
+	}
+	return recordedSource{Source: src, rec: rec}
+}
+
+// Next - очередное значение источника с записью в журнал.
+func (s recordedSource) Next() (pipeline.Num, error) {
+	n, err := s.Source.Next()
+	if err == nil {
+		s.rec.value(n)
+	}
+	return n, err
+}
+
+// parseSpeed - разбор скорости воспроизведения: "1x", "10x", "0.5" или "max"
+// (без пауз, результат 0).
+func parseSpeed(s string) (float64, error) {
+	if s == "max" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("скорость воспроизведения %q: ожидается положительное число (например, 10x) или max", s)
+	}
+	return speed, nil
+}
+
+// replayRecords - воспроизведение журнала сеанса path в input с исходными
+// интервалами между записями, ускоренными в speed раз (0 - без пауз).
+// Некорректные строки журнала разбираются заново: если разбор исправлен,
+// значение передается в input, иначе строка выводится в rej.
+func replayRecords(ctx context.Context, path string, speed float64, cfg config, rej *rejects, input chan<- pipeline.Num) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	parse := lineParser(cfg)
+	report := rej.report("replay:" + path)
+
+	var first time.Time // Момент первой записи
+	start := time.Now()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		var e recordEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if first.IsZero() {
+			first = e.Time
+		}
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(e.Time.Sub(first)) / speed))
+			if wait := time.Until(at); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil
+				}
+			}
+		}
+		n := e.Value
+		if e.Line != nil {
+			v, err := parse(*e.Line)
+			if err != nil {
+				report(lineNo, *e.Line, err)
+				continue
+			}
+			n = &v
+		}
+		if n == nil {
+			return fmt.Errorf("%s:%d: запись без value и line", path, lineNo)
+		}
+		rej.rec.value(*n)
+		select {
+		case input <- *n:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
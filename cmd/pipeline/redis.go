@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+	"github.com/redis/go-redis/v9"
+)
+
+// Тип ключа Redis в адресе источника или приемника.
+const (
+	redisStream = "stream" // Поток (XADD/XREADGROUP)
+	redisList   = "list"   // Список (RPUSH/BLMOVE)
+)
+
+// Параметры обмена с Redis.
+const (
+	redisBatchSize        = 100              // Записи, читаемые или отправляемые за один запрос
+	redisReadBlock        = 5 * time.Second  // Ожидание новых записей в одном запросе чтения
+	redisDefaultClaimIdle = 30 * time.Second // Простой записи другого потребителя до ее перехвата
+)
+
+// redisTarget - ключ Redis из адреса вида
+// redis://[user:password@]host[:port]/key?type=stream|list&group=...&consumer=...
+type redisTarget struct {
+	opts      *redis.Options
+	key       string
+	kind      string        // redisStream или redisList
+	group     string        // Группа потребителей потока
+	consumer  string        // Имя потребителя в группе
+	field     string        // Поле записи потока с числом
+	claimIdle time.Duration // Простой незавершенной записи до ее перехвата
+}
+
+// parseRedisURL - разбор адреса источника или приемника Redis.
+// Схема rediss включает TLS.
+func parseRedisURL(raw string) (redisTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redisTarget{}, fmt.Errorf("некорректный адрес Redis: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return redisTarget{}, fmt.Errorf("неподдерживаемая схема адреса %q (ожидается redis или rediss)", u.Scheme)
+	}
+	t := redisTarget{
+		opts:      &redis.Options{Addr: u.Host},
+		key:       strings.TrimPrefix(u.Path, "/"),
+		kind:      redisStream,
+		group:     "pipeline",
+		field:     "value",
+		claimIdle: redisDefaultClaimIdle,
+	}
+	if t.key == "" {
+		return redisTarget{}, fmt.Errorf("в адресе Redis не задан ключ: %s", raw)
+	}
+	if u.Port() == "" {
+		t.opts.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		t.opts.Username = u.User.Username()
+		t.opts.Password, _ = u.User.Password()
+	}
+	if u.Scheme == "rediss" {
+		t.opts.TLSConfig = &tls.Config{ServerName: u.Hostname()}
+	}
+	q := u.Query()
+	if v := q.Get("type"); v != "" {
+		if v != redisStream && v != redisList {
+			return redisTarget{}, fmt.Errorf("неизвестный тип ключа Redis: %q (ожидается %s или %s)", v, redisStream, redisList)
+		}
+		t.kind = v
+	}
+	if v := q.Get("group"); v != "" {
+		t.group = v
+	}
+	if v := q.Get("field"); v != "" {
+		t.field = v
+	}
+	if t.consumer = q.Get("consumer"); t.consumer == "" {
+		// Имя узла не меняется между перезапусками, поэтому незавершенные
+		// записи прошлого запуска возвращаются этому же потребителю
+		if t.consumer, err = os.Hostname(); err != nil {
+			t.consumer = "pipeline"
+		}
+	}
+	if v := q.Get("db"); v != "" {
+		if t.opts.DB, err = strconv.Atoi(v); err != nil || t.opts.DB < 0 {
+			return redisTarget{}, fmt.Errorf("некорректный номер базы Redis: %q", v)
+		}
+	}
+	if v := q.Get("claim"); v != "" {
+		if t.claimIdle, err = time.ParseDuration(v); err != nil || t.claimIdle <= 0 {
+			return redisTarget{}, fmt.Errorf("некорректный простой перехвата записей: %q", v)
+		}
+	}
+	return t, nil
+}
+
+// processing - список значений, взятых в обработку (для ключа-списка).
+func (t redisTarget) processing() string {
+	return t.key + ":processing:" + t.consumer
+}
+
+// redisSource - источник чисел из потока или списка Redis.
+//
+// Записи потока читаются в группе потребителей; записи, не подтвержденные
+// другими потребителями дольше claimIdle, перехватываются. Значения списка
+// атомарно переносятся в список обработки processing(). Записи подтверждаются
+// (XACK или LREM) в commit только после вывода их значений, а при перезапуске
+// незавершенные записи этого потребителя обрабатываются повторно (доставка
+// не менее одного раза). При ошибках соединения чтение повторяется.
+type redisSource struct {
+	t     redisTarget
+	c     *redis.Client
+	parse func(line string) (pipeline.Num, error)
+	rej   *rejects
+	acks  sourceAcks[string] // Идентификаторы записей потока или значения списка
+}
+
+// newRedisSource - источник из ключа t. Значения списка разбираются
+// в формате cfg, поле записи потока - как число.
+func newRedisSource(t redisTarget, cfg config, rej *rejects) *redisSource {
+	parse := lineParser(cfg)
+	if t.kind == redisStream {
+		parse = pipeline.ParseNumLine
+	}
+	return &redisSource{t: t, c: redis.NewClient(t.opts), parse: parse, rej: rej}
+}
+
+// run - чтение записей в input до отмены ctx.
+func (s *redisSource) run(ctx context.Context, input chan<- pipeline.Num) {
+	log := stageLog("source")
+	backoff := forwardMinBackoff
+	recovered := false // Незавершенные записи прошлого запуска прочитаны
+	for {
+		err := s.recover(ctx, input, &recovered)
+		for err == nil {
+			backoff = forwardMinBackoff
+			err = s.read(ctx, input)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("Ошибка чтения Redis", "key", s.t.key, "err", err, "retry_in", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, forwardMaxBackoff)
+	}
+}
+
+// recover - подготовка ключа и однократное чтение записей, взятых этим
+// потребителем в обработку, но не подтвержденных (например, до сбоя).
+func (s *redisSource) recover(ctx context.Context, input chan<- pipeline.Num, done *bool) error {
+	if s.t.kind == redisStream {
+		err := s.c.XGroupCreateMkStream(ctx, s.t.key, s.t.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return err
+		}
+	}
+	if *done {
+		return nil
+	}
+	if s.t.kind == redisList {
+		vals, err := s.c.LRange(ctx, s.t.processing(), 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		for _, v := range vals {
+			if !s.emit(ctx, input, v, v) {
+				return ctx.Err()
+			}
+		}
+	} else {
+		// Чтение с идентификатора "0" возвращает записи, выданные этому потребителю
+		for start := "0"; ; {
+			streams, err := s.c.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    s.t.group,
+				Consumer: s.t.consumer,
+				Streams:  []string{s.t.key, start},
+				Count:    redisBatchSize,
+				Block:    -1,
+			}).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if len(streams) == 0 || len(streams[0].Messages) == 0 {
+				break
+			}
+			msgs := streams[0].Messages
+			if !s.emitMessages(ctx, input, msgs) {
+				return ctx.Err()
+			}
+			start = msgs[len(msgs)-1].ID
+		}
+	}
+	*done = true
+	return nil
+}
+
+// read - одно чтение новых записей: перехват простаивающих записей других
+// потребителей потока, затем ожидание новых записей до redisReadBlock.
+func (s *redisSource) read(ctx context.Context, input chan<- pipeline.Num) error {
+	if s.t.kind == redisList {
+		v, err := s.c.BLMove(ctx, s.t.key, s.t.processing(), "LEFT", "RIGHT", redisReadBlock).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !s.emit(ctx, input, v, v) {
+			return ctx.Err()
+		}
+		return nil
+	}
+	if err := s.claim(ctx, input); err != nil {
+		return err
+	}
+	streams, err := s.c.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.t.group,
+		Consumer: s.t.consumer,
+		Streams:  []string{s.t.key, ">"},
+		Count:    redisBatchSize,
+		Block:    redisReadBlock,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, st := range streams {
+		if !s.emitMessages(ctx, input, st.Messages) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// claim - перехват записей других потребителей, не подтвержденных дольше claimIdle.
+// Собственные незавершенные записи не перехватываются: они еще в обработке.
+func (s *redisSource) claim(ctx context.Context, input chan<- pipeline.Num) error {
+	pending, err := s.c.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: s.t.key,
+		Group:  s.t.group,
+		Idle:   s.t.claimIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  redisBatchSize,
+	}).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	var ids []string
+	for _, p := range pending {
+		if p.Consumer != s.t.consumer {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	msgs, err := s.c.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   s.t.key,
+		Group:    s.t.group,
+		Consumer: s.t.consumer,
+		MinIdle:  s.t.claimIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if len(msgs) > 0 {
+		stageLog("source").Info("Перехвачены незавершенные записи Redis", "key", s.t.key, "count", len(msgs))
+	}
+	if !s.emitMessages(ctx, input, msgs) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// emitMessages - передача значений записей потока; false при отмене ctx.
+func (s *redisSource) emitMessages(ctx context.Context, input chan<- pipeline.Num, msgs []redis.XMessage) bool {
+	for _, m := range msgs {
+		v, _ := m.Values[s.t.field].(string)
+		if !s.emit(ctx, input, m.ID, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// emit - передача значения записи id в input или в rej; false при отмене ctx.
+func (s *redisSource) emit(ctx context.Context, input chan<- pipeline.Num, id, raw string) bool {
+	n, err := s.parse(raw)
+	if err != nil {
+		s.rej.report("redis:"+s.t.key)(0, raw, err)
+	} else {
+		s.rej.rec.value(n)
+		select {
+		case input <- n:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	s.acks.add(id, err == nil)
+	return true
+}
+
+// commit - подтверждение записей, все значения которых входят в первые
+// values значений источника.
+func (s *redisSource) commit(values uint64) error {
+	ids := s.acks.take(values)
+	if len(ids) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	var err error
+	if s.t.kind == redisStream {
+		err = s.c.XAck(ctx, s.t.key, s.t.group, ids...).Err()
+	} else {
+		_, err = s.c.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for _, v := range ids {
+				p.LRem(ctx, s.t.processing(), 1, v)
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("redis: подтверждение записей: %w", err)
+	}
+	return nil
+}
+
+// Close - закрытие соединений.
+func (s *redisSource) Close() error {
+	return s.c.Close()
+}
+
+// redisSink - приемник, добавляющий обработанные числа в поток (XADD)
+// или в конец списка (RPUSH) Redis. Числа отправляются партиями по
+// redisBatchSize и при Flush; при ошибках отправка повторяется.
+type redisSink struct {
+	ctx   context.Context
+	t     redisTarget
+	c     *redis.Client
+	json  bool // Значения списка - JSON-объекты {"value": n}
+	batch []pipeline.Num
+}
+
+// newRedisSink - отправитель в ключ t; попытки отправки прекращаются при отмене ctx.
+func newRedisSink(ctx context.Context, t redisTarget, cfg config) *redisSink {
+	return &redisSink{ctx: ctx, t: t, c: redis.NewClient(t.opts), json: cfg.format == formatJSONL}
+}
+
+// Write - добавление числа n в партию.
+func (s *redisSink) Write(n pipeline.Num) error {
+	s.batch = append(s.batch, n)
+	if len(s.batch) >= redisBatchSize {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush - отправка накопленной партии одним конвейером команд. Повторяет
+// попытки до успеха или отмены контекста.
+func (s *redisSink) Flush() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	ctx := s.ctx
+	backoff := forwardMinBackoff
+	for {
+		_, err := s.c.Pipelined(ctx, s.send)
+		if err == nil {
+			s.batch = s.batch[:0]
+			return nil
+		}
+		stageLog("sink").Warn("Ошибка отправки в Redis", "key", s.t.key, "err", err, "retry_in", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, forwardMaxBackoff)
+	}
+}
+
+// send - команды добавления партии в конвейер p.
+func (s *redisSink) send(p redis.Pipeliner) error {
+	if s.t.kind == redisStream {
+		for _, n := range s.batch {
+			p.XAdd(s.ctx, &redis.XAddArgs{Stream: s.t.key, Values: []any{s.t.field, n.String()}})
+		}
+		return nil
+	}
+	values := make([]any, len(s.batch))
+	for i, n := range s.batch {
+		values[i] = n.String()
+		if s.json {
+			b, _ := json.Marshal(map[string]pipeline.Num{"value": n})
+			values[i] = string(b)
+		}
+	}
+	p.RPush(s.ctx, s.t.key, values...)
+	return nil
+}
+
+// Reset - отбрасывание неотправленной партии.
+func (s *redisSink) Reset() {
+	s.batch = s.batch[:0]
+}
+
+// Close - отправка остатка и закрытие соединений.
+func (s *redisSink) Close() error {
+	return errors.Join(s.Flush(), s.c.Close())
+}
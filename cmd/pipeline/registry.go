@@ -0,0 +1,965 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// stageParams - параметры стадии из конфигурации.
+type stageParams map[string]any
+
+// int - целочисленный параметр key (def, если не задан).
+func (p stageParams) int(key string, def int) (int, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	switch v := v.(type) {
+	case int:
+		return v, nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("параметр %s: ожидается целое число, получено %v", key, v)
+}
+
+// duration - параметр длительности key (def, если не задан).
+func (p stageParams) duration(key string, def time.Duration) (time.Duration, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("параметр %s: ожидается длительность (например, 5s), получено %v", key, v)
+}
+
+// string - строковый параметр key (def, если не задан).
+func (p stageParams) string(key string, def string) (string, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("параметр %s: ожидается строка, получено %v", key, v)
+}
+
+// bool - логический параметр key (def, если не задан).
+func (p stageParams) bool(key string, def bool) (bool, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("параметр %s: ожидается true или false, получено %v", key, v)
+}
+
+// float - числовой параметр key (def, если не задан).
+func (p stageParams) float(key string, def float64) (float64, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("параметр %s: ожидается число, получено %v", key, v)
+}
+
+// strings - параметр-список строк key: список или строка, разделяемая
+// пробелами (def, если не задан).
+func (p stageParams) strings(key string, def []string) ([]string, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v), nil
+	case []string:
+		return v, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("параметр %s: ожидается список строк, получен элемент %v", key, e)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("параметр %s: ожидается список строк, получено %v", key, v)
+}
+
+// envelope - значение пайплайна с метаданными (время поступления, номер, источник).
+// Значения - числа pipeline.Num: целые любой величины и с плавающей точкой.
+type envelope = pipeline.Item[pipeline.Num]
+
+// Режимы стадии dedup.
+const (
+	dedupWindow      = "window"      // Повторы среди последних значений
+	dedupConsecutive = "consecutive" // Только подряд идущие повторы
+)
+
+// Упорядочивание партий стадии buffer.
+const (
+	sortAsc  = "asc"  // По возрастанию
+	sortDesc = "desc" // По убыванию
+)
+
+// sortFunc - функция упорядочивания партий для order (пусто - без упорядочивания).
+func sortFunc(order string) (func(a, b envelope) int, error) {
+	switch order {
+	case "":
+		return nil, nil
+	case sortAsc:
+		return func(a, b envelope) int { return a.Value.Cmp(b.Value) }, nil
+	case sortDesc:
+		return func(a, b envelope) int { return b.Value.Cmp(a.Value) }, nil
+	}
+	return nil, fmt.Errorf("ожидается %s или %s, получено %q", sortAsc, sortDesc, order)
+}
+
+// stageDef - описание именованной стадии. Стадии без состояния задаются
+// функцией item и дополнительно принимают параметры workers и ordered.
+type stageDef struct {
+	doc         string   // Краткое описание (stages list)
+	params      []string // Допустимые параметры
+	build       func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error)
+	item        func(p stageParams) (pipeline.ItemFunc[pipeline.Num], error) // Обработка одного значения
+	passthrough bool                                                         // Значения передаются без изменений
+	restart     string                                                       // Политика перезапуска без параметра restart и -stage-restart
+}
+
+// stageEnv - окружение создания стадии: метрики и обработчики событий.
+type stageEnv struct {
+	metric  *stageMetric              // Метрики стадии (nil - не собираются)
+	onFlush func(batch uint64, n int) // Обработчик отправки партии буфера (может быть nil)
+	control *pipeline.BufferControl   // Управление буфером во время работы (может быть nil)
+	acks    *pipeline.Acks[envelope]  // Подтверждение доставки партий буфера (может быть nil)
+	timeout time.Duration             // Время обработки значения без параметра timeout (0 - не ограничено)
+}
+
+// buildOptions - параметры создания цепочки стадий.
+type buildOptions struct {
+	instrument bool                      // Оборачивать стадии счетчиками
+	onFlush    func(batch uint64, n int) // Обработчик отправки партии последней стадии buffer
+	queue      int                       // Емкость очереди на входе стадий без параметра queue
+	control    *pipeline.BufferControl   // Управление последней стадией buffer (может быть nil)
+	restart    string                    // Политика перезапуска стадий без параметра restart (пусто - без надзора)
+	tracer     *tracer                   // Трассировка прохождения стадий (nil - отключена)
+	acks       *pipeline.Acks[envelope]  // Подтверждение доставки партий последней стадии buffer (nil - без подтверждения)
+	labels     bool                      // Помечать горутины стадий метками профилировщика (-pprof)
+	timeout    time.Duration             // Время обработки значения стадиями без параметра timeout (0 - не ограничено)
+	ordered    bool                      // Стадии должны сохранять порядок значений (контрольные точки)
+}
+
+// queueParam - параметр любой стадии: емкость наблюдаемой очереди на входе (0 - без очереди).
+const queueParam = "queue"
+
+// restartParam - параметр любой стадии: политика перезапуска после паники
+// (never, on-failure или always).
+const restartParam = "restart"
+
+// parallelParams - параметры параллельного выполнения стадий без состояния.
+var parallelParams = []string{"workers", "ordered"}
+
+// timeoutParam - параметр стадий обработки отдельных значений: наибольшее
+// время обработки одного значения (0 - не ограничено).
+const timeoutParam = "timeout"
+
+// buildItem - создание стадии name без состояния с учетом параметров workers,
+// ordered и timeout. Функция применяется к значению, метаданные сохраняются.
+func (d stageDef) buildItem(name string, p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+	valueFn, err := d.item(p)
+	if err != nil {
+		return nil, err
+	}
+	if valueFn, err = withTimeout(name, valueFn, p, env); err != nil {
+		return nil, err
+	}
+	fn := pipeline.LiftItem(valueFn)
+	workers, err := p.int("workers", 1)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		return nil, fmt.Errorf("параметр workers должен быть положительным: %d", workers)
+	}
+	ordered, err := p.bool("ordered", false)
+	if err != nil {
+		return nil, err
+	}
+	if ordered {
+		return pipeline.ParallelOrdered(fn, workers), nil
+	}
+	return pipeline.Parallel(pipeline.ItemStage(fn), workers), nil
+}
+
+// withTimeout - fn стадии name с ограничением времени обработки значения
+// параметром timeout (по умолчанию - env.timeout). Значение, обработка
+// которого не уложилась в срок, отбрасывается с ошибкой pipeline.ErrSlowItem
+// и учитывается в метриках стадии. Стадии, получающие канал целиком (exec,
+// plugin с символом Stage), так не ограничиваются.
+func withTimeout(name string, fn pipeline.ItemFunc[pipeline.Num], p stageParams, env stageEnv) (pipeline.ItemFunc[pipeline.Num], error) {
+	timeout, err := p.duration(timeoutParam, env.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("параметр timeout не может быть отрицательным: %s", timeout)
+	}
+	fn = pipeline.WithDeadline(name, fn, timeout, pipeline.RealClock{})
+	if timeout == 0 || env.metric == nil {
+		return fn, nil
+	}
+	sm := env.metric
+	return func(n pipeline.Num) (pipeline.Num, bool, error) {
+		v, pass, err := fn(n)
+		if errors.Is(err, pipeline.ErrSlowItem) {
+			sm.slow.Add(1)
+		}
+		return v, pass, err
+	}, nil
+}
+
+// stageRegistry - реестр именованных стадий.
+var stageRegistry = map[string]stageDef{
+	"filter_negative": {
+		doc: "отбрасывание отрицательных чисел",
+		build: func(stageParams, stageEnv) (pipeline.Stage[envelope], error) {
+			return pipeline.ItemStage(pipeline.LiftItem(func(n pipeline.Num) (pipeline.Num, bool, error) {
+				if !pipeline.KeepNonNegativeNum(n) {
+					return n, false, pipeline.NewRejection("filter_negative", n, "отрицательное число")
+				}
+				return n, true, nil
+			})), nil
+		},
+	},
+	"filter_div3": {
+		doc: "отбрасывание чисел, не кратных 3, и нуля",
+		build: func(stageParams, stageEnv) (pipeline.Stage[envelope], error) {
+			return pipeline.ItemStage(pipeline.LiftItem(func(n pipeline.Num) (pipeline.Num, bool, error) {
+				if !pipeline.KeepDivisibleBy3Num(n) {
+					return n, false, pipeline.NewRejection("filter_div3", n, "не кратно 3")
+				}
+				return n, true, nil
+			})), nil
+		},
+	},
+	"filter": {
+		doc:    "отбрасывание значений, не прошедших фильтр predicate (например, range:0-100)",
+		params: []string{"predicate"},
+		item: func(p stageParams) (pipeline.ItemFunc[pipeline.Num], error) {
+			spec, err := p.string("predicate", "")
+			if err != nil {
+				return nil, err
+			}
+			if spec == "" {
+				return nil, fmt.Errorf("не задан параметр predicate")
+			}
+			pred, err := pipeline.ParseFilter(spec)
+			if err != nil {
+				return nil, err
+			}
+			return func(n pipeline.Num) (pipeline.Num, bool, error) {
+				if !pred(n) {
+					return n, false, pipeline.NewRejection("filter", n, "не прошло фильтр "+spec)
+				}
+				return n, true, nil
+			}, nil
+		},
+	},
+	"expr": {
+		doc:    "отбрасывание значений, для которых выражение expression над x ложно",
+		params: []string{"expression"},
+		item: func(p stageParams) (pipeline.ItemFunc[pipeline.Num], error) {
+			src, err := p.string("expression", "")
+			if err != nil {
+				return nil, err
+			}
+			check, err := pipeline.CompileExprChecked(src)
+			if err != nil {
+				return nil, fmt.Errorf("выражение %q: %w", src, err)
+			}
+			return func(n pipeline.Num) (pipeline.Num, bool, error) {
+				pass, err := check(n)
+				if err != nil {
+					return n, false, pipeline.NewStageError("expr", n, err)
+				}
+				if !pass {
+					return n, false, pipeline.NewRejection("expr", n, "выражение ложно: "+src)
+				}
+				return n, true, nil
+			}, nil
+		},
+	},
+	"map": {
+		doc:    "преобразование значений функцией func (например, scale:2)",
+		params: []string{"func"},
+		item: func(p stageParams) (pipeline.ItemFunc[pipeline.Num], error) {
+			spec, err := p.string("func", "")
+			if err != nil {
+				return nil, err
+			}
+			if spec == "" {
+				return nil, fmt.Errorf("не задан параметр func")
+			}
+			fn, err := pipeline.ParseMap(spec)
+			if err != nil {
+				return nil, err
+			}
+			return func(n pipeline.Num) (pipeline.Num, bool, error) { return fn(n), true, nil }, nil
+		},
+	},
+	"rate_limit": {
+		doc:         "ограничение скорости rate (например, 100/s) с запасом burst",
+		params:      []string{"rate", "burst"},
+		passthrough: true,
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			spec, err := p.string("rate", "")
+			if err != nil {
+				return nil, err
+			}
+			if spec == "" {
+				return nil, fmt.Errorf("не задан параметр rate (например, 100/s)")
+			}
+			rate, err := pipeline.ParseRate(spec)
+			if err != nil {
+				return nil, fmt.Errorf("параметр rate: %w", err)
+			}
+			burst, err := p.int("burst", 1)
+			if err != nil {
+				return nil, err
+			}
+			if burst <= 0 {
+				return nil, fmt.Errorf("параметр burst должен быть положительным: %d", burst)
+			}
+			return pipeline.RateLimit[envelope](rate, burst, pipeline.RealClock{}), nil
+		},
+	},
+	"dedup": {
+		doc:    "удаление повторов: подряд идущих или в окне size или ttl",
+		params: []string{"mode", "size", "ttl"},
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			mode, err := p.string("mode", dedupWindow)
+			if err != nil {
+				return nil, err
+			}
+			size, err := p.int("size", pipeline.DefaultDedupSize)
+			if err != nil {
+				return nil, err
+			}
+			if size <= 0 {
+				return nil, fmt.Errorf("параметр size должен быть положительным: %d", size)
+			}
+			ttl, err := p.duration("ttl", 0)
+			if err != nil {
+				return nil, err
+			}
+			if ttl < 0 {
+				return nil, fmt.Errorf("параметр ttl не может быть отрицательным: %s", ttl)
+			}
+			switch mode {
+			case dedupWindow:
+				return pipeline.DedupBy(pipeline.ItemValue[pipeline.Num], size, ttl, pipeline.RealClock{}), nil
+			case dedupConsecutive:
+				return pipeline.DedupConsecutiveBy(pipeline.ItemValue[pipeline.Num]), nil
+			}
+			return nil, fmt.Errorf("параметр mode: ожидается %s или %s, получено %q", dedupWindow, dedupConsecutive, mode)
+		},
+	},
+	"sample": {
+		doc:    "выборка части значений: каждое n-е, percent процентов или резервуар size",
+		params: []string{"mode", "n", "percent", "size", "interval"},
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			mode, err := p.string("mode", sampleEvery)
+			if err != nil {
+				return nil, err
+			}
+			switch mode {
+			case sampleEvery:
+				n, err := p.int("n", 0)
+				if err != nil {
+					return nil, err
+				}
+				if n <= 0 {
+					return nil, fmt.Errorf("параметр n должен быть положительным: %d", n)
+				}
+				return pipeline.SampleEvery[envelope](n), nil
+			case sampleRandom:
+				percent, err := p.float("percent", -1)
+				if err != nil {
+					return nil, err
+				}
+				if percent < 0 || percent > 100 {
+					return nil, fmt.Errorf("параметр percent должен быть от 0 до 100: %v", percent)
+				}
+				return pipeline.SampleRandom[envelope](percent/100, nil), nil
+			case sampleReservoir:
+				size, err := p.int("size", 0)
+				if err != nil {
+					return nil, err
+				}
+				if size <= 0 {
+					return nil, fmt.Errorf("параметр size должен быть положительным: %d", size)
+				}
+				interval, err := p.duration("interval", 0)
+				if err != nil {
+					return nil, err
+				}
+				if interval < 0 {
+					return nil, fmt.Errorf("параметр interval не может быть отрицательным: %s", interval)
+				}
+				return pipeline.SampleReservoir[envelope](size, interval, pipeline.RealClock{}, nil), nil
+			}
+			return nil, fmt.Errorf("параметр mode: ожидается %s, %s или %s, получено %q", sampleEvery, sampleRandom, sampleReservoir, mode)
+		},
+	},
+	"stable": {
+		doc:    "пропуск значения, не менявшегося в течение duration",
+		params: []string{"duration"},
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			d, err := p.duration("duration", 0)
+			if err != nil {
+				return nil, err
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("параметр duration должен быть положительным: %s", d)
+			}
+			return pipeline.NewStableGateBy(pipeline.ItemValue[pipeline.Num], d, pipeline.RealClock{}), nil
+		},
+	},
+	"window_mode": {
+		doc:    "наиболее частое значение каждого окна interval",
+		params: []string{"interval"},
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			d, err := p.duration("interval", 0)
+			if err != nil {
+				return nil, err
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("параметр interval должен быть положительным: %s", d)
+			}
+			return pipeline.NewWindowModeFunc(pipeline.ItemValue[pipeline.Num], pipeline.Num.Cmp, d, pipeline.RealClock{}), nil
+		},
+	},
+	"aggregate": {
+		doc:    "агрегат func окон по size значений или по времени interval",
+		params: []string{"func", "size", "interval", "sliding"},
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			name, err := p.string("func", "")
+			if err != nil {
+				return nil, err
+			}
+			agg, err := pipeline.ParseNumAggregate(name)
+			if err != nil {
+				return nil, fmt.Errorf("параметр func: %w", err)
+			}
+			size, err := p.int("size", 0)
+			if err != nil {
+				return nil, err
+			}
+			interval, err := p.duration("interval", 0)
+			if err != nil {
+				return nil, err
+			}
+			sliding, err := p.bool("sliding", false)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case size < 0 || interval < 0:
+				return nil, fmt.Errorf("параметры size и interval не могут быть отрицательными")
+			case (size > 0) == (interval > 0):
+				return nil, fmt.Errorf("необходимо задать ровно один из параметров size или interval")
+			case size > 0:
+				return pipeline.NewCountWindowBy(size, sliding, pipeline.ItemAggregate(agg)), nil
+			}
+			return pipeline.NewTimeWindowBy(interval, sliding, pipeline.ItemAggregate(agg), pipeline.RealClock{}), nil
+		},
+	},
+	"anomaly": {
+		doc:    "пометка выбросов, отклоняющихся от окна window больше чем на sigmas σ",
+		params: []string{"window", "sigmas", "output", "format"},
+		// Файл output закрывается при завершении стадии
+		restart: pipeline.RestartNever,
+		build:   buildAnomaly,
+	},
+	"exec": {
+		doc:    "обработка значений внешним процессом command по строкам JSON",
+		params: []string{"command", "args", "dir", "in_flight"},
+		// Без надзора стадия после завершения процесса перестает принимать значения
+		restart: pipeline.RestartNever,
+		build: func(p stageParams, _ stageEnv) (pipeline.Stage[envelope], error) {
+			command, err := p.string("command", "")
+			if err != nil {
+				return nil, err
+			}
+			if command == "" {
+				return nil, fmt.Errorf("необходимо задать параметр command")
+			}
+			if _, err := exec.LookPath(command); err != nil {
+				return nil, fmt.Errorf("параметр command: %w", err)
+			}
+			args, err := p.strings("args", nil)
+			if err != nil {
+				return nil, err
+			}
+			dir, err := p.string("dir", "")
+			if err != nil {
+				return nil, err
+			}
+			inFlight, err := p.int("in_flight", pipeline.DefaultExecInFlight)
+			if err != nil {
+				return nil, err
+			}
+			if inFlight <= 0 {
+				return nil, fmt.Errorf("параметр in_flight должен быть положительным: %d", inFlight)
+			}
+			return pipeline.ExecStage[pipeline.Num](pipeline.ExecOptions{
+				Name:     "exec",
+				Command:  command,
+				Args:     args,
+				Dir:      dir,
+				InFlight: inFlight,
+				Stderr:   os.Stderr,
+			}), nil
+		},
+	},
+	"plugin": {
+		doc:    "стадия из модуля Go path (символ symbol)",
+		params: []string{"path", "symbol", timeoutParam},
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			path, err := p.string("path", "")
+			if err != nil {
+				return nil, err
+			}
+			if path == "" {
+				return nil, fmt.Errorf("необходимо задать параметр path")
+			}
+			symbol, err := p.string("symbol", defaultPluginSymbol)
+			if err != nil {
+				return nil, err
+			}
+			stage, fn, err := loadPluginStage(path, symbol)
+			if err != nil {
+				return nil, err
+			}
+			if fn == nil {
+				if _, ok := p[timeoutParam]; ok {
+					return nil, fmt.Errorf("параметр timeout поддерживается только для функции значения")
+				}
+				return stage, nil
+			}
+			if fn, err = withTimeout("plugin", fn, p, env); err != nil {
+				return nil, err
+			}
+			return pipeline.ItemStage(pipeline.LiftItem(fn)), nil
+		},
+	},
+	"buffer": {
+		doc:    "кольцевой буфер размера size с отправкой по flush_interval",
+		params: []string{"size", "flush_interval", "overflow", "spill", "batch", "ring", "priority", "priority_mode", "sort", "limit"},
+		build: func(p stageParams, env stageEnv) (pipeline.Stage[envelope], error) {
+			size, err := p.int("size", pipeline.DefaultBufferSize)
+			if err != nil {
+				return nil, err
+			}
+			if size <= 0 {
+				return nil, fmt.Errorf("параметр size должен быть положительным: %d", size)
+			}
+			interval, err := p.duration("flush_interval", pipeline.DefaultFlushInterval)
+			if err != nil {
+				return nil, err
+			}
+			if interval <= 0 {
+				return nil, fmt.Errorf("параметр flush_interval должен быть положительным: %s", interval)
+			}
+			overflow, err := p.string("overflow", pipeline.OverflowOverwrite)
+			if err != nil {
+				return nil, err
+			}
+			if err := pipeline.ValidateOverflow(overflow); err != nil {
+				return nil, fmt.Errorf("параметр overflow: %w", err)
+			}
+			ring, err := p.string("ring", pipeline.RingMutex)
+			if err != nil {
+				return nil, err
+			}
+			if err := pipeline.ValidateRing(ring); err != nil {
+				return nil, fmt.Errorf("параметр ring: %w", err)
+			}
+			priority, err := p.string("priority", "")
+			if err != nil {
+				return nil, err
+			}
+			priorityMode, err := p.string("priority_mode", pipeline.PriorityImmediate)
+			if err != nil {
+				return nil, err
+			}
+			if err := pipeline.ValidatePriorityMode(priorityMode); err != nil {
+				return nil, fmt.Errorf("параметр priority_mode: %w", err)
+			}
+			order, err := p.string("sort", "")
+			if err != nil {
+				return nil, err
+			}
+			limit, err := p.int("limit", 0)
+			if err != nil {
+				return nil, err
+			}
+			if limit < 0 {
+				return nil, fmt.Errorf("параметр limit не может быть отрицательным: %d", limit)
+			}
+			if limit > 0 && order == "" {
+				order = sortDesc // Первые значения - наибольшие
+			}
+			sortFn, err := sortFunc(order)
+			if err != nil {
+				return nil, fmt.Errorf("параметр sort: %w", err)
+			}
+			spill, err := p.string("spill", "")
+			if err != nil {
+				return nil, err
+			}
+			batch, err := p.int("batch", 0)
+			if err != nil {
+				return nil, err
+			}
+			if batch < 0 || batch > size {
+				return nil, fmt.Errorf("параметр batch должен быть от 0 до size: %d", batch)
+			}
+			log := stageLog("buffer")
+			opts := pipeline.BufferOptions[envelope]{
+				SpillPath:     spill,
+				BatchSize:     batch,
+				Size:          size,
+				FlushInterval: interval,
+				Overflow:      overflow,
+				Ring:          ring,
+				Sort:          sortFn,
+				Limit:         limit,
+				OnEvict: func(v envelope) {
+					log.Debug("Значение потеряно при переполнении буфера", "value", v.Value, "seq", v.Seq)
+				},
+			}
+			if env.metric != nil {
+				env.metric.buffer = &pipeline.BufferMetrics{}
+				opts.Metrics = env.metric.buffer
+			}
+			if priority != "" {
+				urgent, err := pipeline.CompileExpr(priority)
+				if err != nil {
+					return nil, fmt.Errorf("параметр priority: выражение %q: %w", priority, err)
+				}
+				opts.Priority = func(it envelope) int {
+					if urgent(it.Value) {
+						return 1
+					}
+					return 0
+				}
+				opts.PriorityMode = priorityMode
+			}
+			opts.OnFlush = env.onFlush
+			opts.Control = env.control
+			opts.Acks = env.acks
+			return pipeline.NewBufferWith(opts), nil
+		},
+	},
+}
+
+// stageNames - отсортированный список зарегистрированных стадий.
+func stageNames() []string {
+	names := make([]string, 0, len(stageRegistry))
+	for name := range stageRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseStageList - разбор списка имен стадий, разделенных запятыми.
+func parseStageList(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// stageSpec - стадия в конфигурации: имя из реестра и параметры.
+type stageSpec struct {
+	Name   string      `json:"name" yaml:"name"`
+	Params stageParams `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// buildStage - создание стадии по описанию из конфигурации.
+func buildStage(spec stageSpec, env stageEnv) (pipeline.Stage[envelope], error) {
+	def, ok := stageRegistry[spec.Name]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная стадия (доступны: %s)", strings.Join(stageNames(), ", "))
+	}
+	for key := range spec.Params {
+		if key == queueParam || key == restartParam {
+			continue
+		}
+		if !slices.Contains(def.params, key) && (def.item == nil || !slices.Contains(parallelParams, key) && key != timeoutParam) {
+			return nil, fmt.Errorf("неизвестный параметр %q", key)
+		}
+	}
+	if def.item != nil {
+		return def.buildItem(spec.Name, spec.Params, env)
+	}
+	return def.build(spec.Params, env)
+}
+
+// buildStages - создание стадий по описаниям из конфигурации.
+// При opts.instrument стадии оборачиваются счетчиками, которые возвращаются вторым
+// значением вместе с очередями на входе стадий.
+func buildStages(specs []stageSpec, opts buildOptions) ([]pipeline.Stage[envelope], []*stageMetric, error) {
+	stages := make([]pipeline.Stage[envelope], 0, len(specs))
+	var metrics []*stageMetric
+	// Партии видны на выходе у последней стадии, после которой значения не меняются
+	flushAt := len(specs) - 1
+	for flushAt > 0 && stageRegistry[specs[flushAt].Name].passthrough {
+		flushAt--
+	}
+	// Управление во время работы относится к последнему буферу
+	controlAt := -1
+	for i, spec := range specs {
+		if spec.Name == "buffer" {
+			controlAt = i
+		}
+	}
+	// Подтверждение сопоставляет значения на выходе с партиями буфера
+	if opts.acks != nil && (len(specs) == 0 || specs[flushAt].Name != "buffer") {
+		return nil, nil, fmt.Errorf("флаг ack требует стадии buffer, после которой значения не меняются")
+	}
+	// Позиция контрольной точки определяется по номерам выведенных значений
+	for i, spec := range specs {
+		if reason := reorders(spec); opts.ordered && reason != "" {
+			return nil, nil, fmt.Errorf("стадия #%d (%s): %s, а контрольные точки требуют сохранения порядка значений", i+1, spec.Name, reason)
+		}
+	}
+	for i, spec := range specs {
+		var env stageEnv
+		if opts.instrument {
+			env.metric = &stageMetric{index: i + 1, name: spec.Name}
+		}
+		if i == flushAt {
+			env.onFlush = opts.onFlush
+			env.acks = opts.acks
+		}
+		if i == controlAt {
+			env.control = opts.control
+		}
+		env.timeout = opts.timeout
+		stage, err := buildStage(spec, env)
+		if err != nil {
+			return nil, nil, fmt.Errorf("стадия #%d (%s): %w", i+1, spec.Name, err)
+		}
+		queue, err := spec.Params.int(queueParam, opts.queue)
+		if err != nil {
+			return nil, nil, fmt.Errorf("стадия #%d (%s): %w", i+1, spec.Name, err)
+		}
+		if queue < 0 {
+			return nil, nil, fmt.Errorf("стадия #%d (%s): параметр queue не может быть отрицательным: %d", i+1, spec.Name, queue)
+		}
+		restart, err := spec.Params.string(restartParam, cmp.Or(opts.restart, stageRegistry[spec.Name].restart))
+		if err != nil {
+			return nil, nil, fmt.Errorf("стадия #%d (%s): %w", i+1, spec.Name, err)
+		}
+		if restart != "" {
+			if err := pipeline.ValidateRestart(restart); err != nil {
+				return nil, nil, fmt.Errorf("стадия #%d (%s): %w", i+1, spec.Name, err)
+			}
+			stage = superviseStage(i+1, spec.Name, restart, stage)
+		}
+
+		sm := env.metric
+		if sm != nil {
+			stage = pipeline.Instrument(&sm.stage, stage)
+		}
+		if queue > 0 {
+			// Очередь снаружи счетчиков: значения в ней еще не приняты стадией
+			if sm == nil {
+				sm = &stageMetric{index: i + 1, name: spec.Name}
+			}
+			sm.queue = newStageQueue(sm, queue)
+			stage = pipeline.WithQueue(sm.queue, stage)
+		}
+		// Время в очереди входит в спан стадии
+		stage = opts.tracer.stage(i+1, spec, stage)
+		if sm != nil {
+			stage = trackLiveness(sm, stage)
+			metrics = append(metrics, sm)
+		}
+		if opts.labels {
+			stage = labelStage(i+1, spec.Name, stage)
+		}
+		stages = append(stages, stage)
+	}
+	return stages, metrics, nil
+}
+
+// reorders - причина, по которой стадия spec может менять порядок значений
+// (пусто - порядок сохраняется). Ошибки параметров сообщает buildStage.
+func reorders(spec stageSpec) string {
+	p := spec.Params
+	switch spec.Name {
+	case "route":
+		return "ветви выдают значения независимо"
+	case "buffer":
+		if priority, _ := p.string("priority", ""); priority != "" {
+			return "срочные значения обгоняют остальные (priority)"
+		}
+		order, _ := p.string("sort", "")
+		limit, _ := p.int("limit", 0)
+		if order != "" || limit > 0 {
+			return "партии сортируются (sort, limit)"
+		}
+	}
+	workers, _ := p.int("workers", 1)
+	ordered, _ := p.bool("ordered", false)
+	if workers > 1 && !ordered {
+		return "обработчики workers выдают значения без ordered"
+	}
+	return ""
+}
+
+// superviseStage - стадия index под надзором: после паники перезапускается
+// согласно политике restart с записью в журнал.
+func superviseStage(index int, name, restart string, stage pipeline.Stage[envelope]) pipeline.Stage[envelope] {
+	log := stageLog(name).With("index", index)
+	return pipeline.Supervise(name, stage, pipeline.SuperviseOptions{
+		Policy: restart,
+		OnRestart: func(n int, cause error) {
+			// Причины без перевода (паники) выводятся как есть
+			log.Warn("Стадия перезапущена, ее состояние сброшено", "restarts", n, "cause", tr(cause.Error()))
+		},
+	})
+}
+
+// namedChain - заменяемая цепочка стадий реестра с учетом их имен.
+type namedChain struct {
+	*pipeline.ReloadableChain[envelope]
+	metrics *metrics     // Метрики стадий (nil - не собираются)
+	opts    buildOptions // Параметры создания стадий при перезагрузке
+
+	mu    sync.Mutex
+	specs []stageSpec
+}
+
+// newNamedChain - создание заменяемой цепочки из стадий реестра.
+func newNamedChain(specs []stageSpec, chanCap int, m *metrics, opts buildOptions) (*namedChain, error) {
+	opts.instrument = m != nil
+	stages, sms, err := buildStages(specs, opts)
+	if err != nil {
+		return nil, err
+	}
+	m.setStages(sms)
+	return &namedChain{
+		ReloadableChain: pipeline.NewReloadableChain(stages...).WithChanCap(chanCap),
+		metrics:         m,
+		opts:            opts,
+		specs:           slices.Clone(specs),
+	}, nil
+}
+
+// Names - имена стадий активной цепочки.
+func (c *namedChain) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, len(c.specs))
+	for i, spec := range c.specs {
+		names[i] = spec.Name
+	}
+	return names
+}
+
+// ReloadNames - замена активной цепочки на стадии реестра names.
+// Параметры стадий, уже присутствующих в цепочке, сохраняются.
+func (c *namedChain) ReloadNames(names []string) error {
+	c.mu.Lock()
+	specs := make([]stageSpec, len(names))
+	for i, name := range names {
+		specs[i] = stageSpec{Name: name}
+		for _, old := range c.specs {
+			if old.Name == name {
+				specs[i].Params = old.Params
+				break
+			}
+		}
+	}
+	c.mu.Unlock()
+	return c.ReloadSpecs(specs)
+}
+
+// ReloadSpecs - замена активной цепочки на стадии по описаниям specs.
+func (c *namedChain) ReloadSpecs(specs []stageSpec) error {
+	stages, sms, err := buildStages(specs, c.opts)
+	if err != nil {
+		return err
+	}
+	if err := c.Reload(stages...); err != nil {
+		return err
+	}
+	c.metrics.setStages(sms)
+	c.setSpecs(specs)
+	return nil
+}
+
+// Specs - описания стадий активной цепочки.
+func (c *namedChain) Specs() []stageSpec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.specs)
+}
+
+// setSpecs - замена описаний стадий без перезапуска цепочки (параметры
+// изменены в работающих стадиях).
+func (c *namedChain) setSpecs(specs []stageSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.specs = slices.Clone(specs)
+}
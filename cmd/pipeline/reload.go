@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// bufferTunables - параметры последней стадии buffer, изменяемые без перезапуска.
+var bufferTunables = []string{"size", "flush_interval"}
+
+// watchReload - перечитывание файла конфигурации cfg.configPath по SIGHUP
+// до отмены ctx (см. reloadConfig).
+func watchReload(ctx context.Context, cfg config, chain *namedChain, buffer *pipeline.BufferControl) {
+	if len(reloadSignals) == 0 { // Без сигналов Notify подписывается на все
+		stageLog("config").Debug("Перечитывание конфигурации по сигналу недоступно в этой системе")
+		return
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, reloadSignals...)
+	defer signal.Stop(sigs)
+	for {
+		select {
+		case <-sigs:
+			reloadConfig(cfg, chain, buffer)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reloadConfig - применение измененного файла конфигурации: размер и интервал
+// последнего буфера меняются в работающей стадии через buffer, остальные
+// изменения стадий требуют перезапуска цепочки chain - состояние окон,
+// удаления повторов и буфера при этом сбрасывается. Флаги командной строки
+// не перечитываются. При ошибке в файле действует прежняя конфигурация.
+func reloadConfig(cfg config, chain *namedChain, buffer *pipeline.BufferControl) {
+	log := stageLog("config")
+	fc, err := loadConfigFile(cfg.configPath)
+	if err != nil {
+		log.Error("Ошибка перечитывания конфигурации, действует прежняя", "err", err)
+		return
+	}
+	if len(fc.Pipelines) > 0 {
+		log.Error("Переход к нескольким пайплайнам требует перезапуска программы, действует прежняя конфигурация", "path", cfg.configPath)
+		return
+	}
+	specs := fc.Stages
+	opts := buildOptions{ordered: cfg.checkpoints()}
+	if cfg.ackBatches {
+		opts.acks = pipeline.NewAcks[envelope]() // Проверка стадии buffer для подтверждения
+	}
+	if _, _, err := buildStages(specs, opts); err != nil {
+		log.Error("Ошибка перечитывания конфигурации, действует прежняя", "path", cfg.configPath, "err", err)
+		return
+	}
+	tuned, err := bufferSettings(specs)
+	if err != nil {
+		log.Error("Ошибка перечитывания конфигурации, действует прежняя", "path", cfg.configPath, "err", err)
+		return
+	}
+
+	old := chain.Specs()
+	if restart := changedStages(old, specs); len(restart) > 0 {
+		// Новая цепочка получает параметры буфера из файла, а не заданные ранее
+		if err := buffer.Apply(tuned); err != nil {
+			log.Error("Ошибка изменения параметров буфера", "err", err)
+		}
+		if err := chain.ReloadSpecs(specs); err != nil {
+			log.Error("Ошибка перезапуска стадий, действует прежняя конфигурация", "err", err)
+			return
+		}
+		log.Warn("Конфигурация перечитана, изменения потребовали перезапуска стадий, их состояние сброшено",
+			"changed", restart)
+		return
+	}
+	if tuned == buffer.Settings() {
+		log.Info("Конфигурация перечитана, изменений нет")
+		return
+	}
+	if err := buffer.Apply(tuned); err != nil {
+		log.Error("Ошибка изменения параметров буфера", "err", err)
+		return
+	}
+	chain.setSpecs(specs)
+	log.Info("Конфигурация перечитана, параметры буфера изменены без перезапуска",
+		"buffer_size", tuned.Size, "flush_interval", tuned.FlushInterval)
+}
+
+// lastBuffer - индекс последней стадии buffer (-1 - нет).
+func lastBuffer(specs []stageSpec) int {
+	for i := len(specs) - 1; i >= 0; i-- {
+		if specs[i].Name == "buffer" {
+			return i
+		}
+	}
+	return -1
+}
+
+// bufferSettings - размер и интервал последней стадии buffer
+// (нулевые значения - стадии buffer нет).
+func bufferSettings(specs []stageSpec) (pipeline.BufferSettings, error) {
+	var s pipeline.BufferSettings
+	i := lastBuffer(specs)
+	if i < 0 {
+		return s, nil
+	}
+	var err error
+	if s.Size, err = specs[i].Params.int("size", pipeline.DefaultBufferSize); err != nil {
+		return s, err
+	}
+	if s.FlushInterval, err = specs[i].Params.duration("flush_interval", pipeline.DefaultFlushInterval); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// changedStages - стадии (номер и имя), изменение которых требует перезапуска
+// цепочки: все различия old и specs, кроме параметров bufferTunables последнего буфера.
+func changedStages(old, specs []stageSpec) []string {
+	old, specs = withoutTunables(old), withoutTunables(specs)
+	var changed []string
+	for i := range max(len(old), len(specs)) {
+		switch {
+		case i >= len(old):
+			changed = append(changed, fmt.Sprintf("#%d %s (добавлена)", i+1, specs[i].Name))
+		case i >= len(specs):
+			changed = append(changed, fmt.Sprintf("#%d %s (удалена)", i+1, old[i].Name))
+		case old[i].Name != specs[i].Name || !reflect.DeepEqual(old[i].Params, specs[i].Params):
+			changed = append(changed, fmt.Sprintf("#%d %s", i+1, specs[i].Name))
+		}
+	}
+	return changed
+}
+
+// withoutTunables - копия specs без параметров bufferTunables последнего буфера.
+func withoutTunables(specs []stageSpec) []stageSpec {
+	specs = slices.Clone(specs)
+	if i := lastBuffer(specs); i >= 0 {
+		params := maps.Clone(specs[i].Params)
+		for _, key := range bufferTunables {
+			delete(params, key)
+		}
+		if len(params) == 0 {
+			params = nil
+		}
+		specs[i].Params = params
+	}
+	return specs
+}
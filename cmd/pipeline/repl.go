@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// runControl - управление запуском во время работы: статистика, приостановка
+// источника, буфер и команды консоли и управляющего сокета.
+type runControl struct {
+	stats  *runStats
+	gate   *sourceGate
+	buffer *pipeline.BufferControl
+	cmds   *commandSet
+	source *sourceStatus // Состояние источника для /readyz
+	tracer *tracer       // Трассировка значений (nil - отключена)
+}
+
+// newRunControl - управление запуском с командами flush, stats, pause,
+// resume и set (см. также registerAPI).
+func newRunControl() *runControl {
+	rc := &runControl{
+		stats:  newRunStats(),
+		gate:   newSourceGate(),
+		buffer: pipeline.NewBufferControl(),
+		cmds:   newCommandSet(),
+		source: &sourceStatus{},
+	}
+	rc.registerCommands()
+	return rc
+}
+
+// registerCommands - регистрация команд управления запуском.
+func (rc *runControl) registerCommands() {
+	rc.cmds.handle("flush", func(string) (string, error) {
+		rc.buffer.Flush()
+		return "запрошена отправка буфера", nil
+	})
+	rc.cmds.handle("stats", func(string) (string, error) {
+		if !rc.stats.running() {
+			return "", errors.New("пайплайн не запущен")
+		}
+		r := rc.stats.report()
+		var rejected uint64
+		for _, rs := range r.Rejected {
+			rejected += rs.Count
+		}
+		c := rc.config()
+		return fmt.Sprintf("прочитано %d, некорректных %d, отброшено %d, выведено %d, ошибок %d, отправок буфера %d, буфер %d, интервал %s, источник приостановлен: %s",
+			r.Read, r.Invalid, rejected, r.Emitted, r.Errors, r.Flushes, c.BufferSize, c.FlushInterval, yesNo(c.Paused)), nil
+	})
+	rc.cmds.handle("pause", func(string) (string, error) {
+		if !rc.gate.pause() {
+			return "источник уже приостановлен", nil
+		}
+		stageLog("source").Info("Источник приостановлен")
+		return "источник приостановлен", nil
+	})
+	rc.cmds.handle("resume", func(string) (string, error) {
+		if !rc.gate.resume() {
+			return "источник не приостановлен", nil
+		}
+		stageLog("source").Info("Чтение источника возобновлено")
+		return "чтение источника возобновлено", nil
+	})
+	rc.cmds.handle("set", func(args string) (string, error) {
+		name, value, _ := strings.Cut(args, " ")
+		value = strings.TrimSpace(value)
+		switch name {
+		case "flush-interval":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return "", fmt.Errorf("ожидается длительность (например, 2s): %q", value)
+			}
+			if err := rc.buffer.SetFlushInterval(d); err != nil {
+				return "", err
+			}
+			stageLog("buffer").Info("Интервал отправки буфера изменен", "flush_interval", d)
+			return "flush-interval = " + d.String(), nil
+		case "buffer-size":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return "", fmt.Errorf("ожидается целое число: %q", value)
+			}
+			if err := rc.buffer.SetSize(n); err != nil {
+				return "", err
+			}
+			stageLog("buffer").Info("Размер буфера изменен", "buffer_size", n)
+			return "buffer-size = " + value, nil
+		case "":
+			return "", errors.New("не задан параметр (доступны: flush-interval, buffer-size)")
+		}
+		return "", fmt.Errorf("неизвестный параметр %q (доступны: flush-interval, buffer-size)", name)
+	})
+}
+
+// yesNo - "да" или "нет".
+func yesNo(b bool) string {
+	if b {
+		return "да"
+	}
+	return "нет"
+}
+
+// sourceGate - приостановка источника: пока источник приостановлен, значения
+// не передаются в пайплайн, а источник ожидает (обратное давление).
+type sourceGate struct {
+	mu      sync.Mutex
+	resumed chan struct{} // Закрыт, пока источник не приостановлен
+}
+
+// newSourceGate - открытый (не приостановленный) источник.
+func newSourceGate() *sourceGate {
+	ch := make(chan struct{})
+	close(ch)
+	return &sourceGate{resumed: ch}
+}
+
+// pause - приостановка источника (false - уже приостановлен).
+func (g *sourceGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.resumed:
+		g.resumed = make(chan struct{})
+		return true
+	default:
+		return false
+	}
+}
+
+// resume - возобновление источника (false - не был приостановлен).
+func (g *sourceGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.resumed:
+		return false
+	default:
+		close(g.resumed)
+		return true
+	}
+}
+
+// paused - источник приостановлен.
+func (g *sourceGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.resumed:
+		return false
+	default:
+		return true
+	}
+}
+
+// wait - ожидание возобновления источника. Возвращает false при отмене ctx.
+// Для nil-получателя источник никогда не приостанавливается.
+func (g *sourceGate) wait(ctx context.Context) bool {
+	if g == nil {
+		return true
+	}
+	g.mu.Lock()
+	ch := g.resumed
+	g.mu.Unlock()
+	select {
+	case <-ch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// replQueue - количество строк данных, принимаемых из консоли, пока
+// источник приостановлен: после заполнения чтение консоли ожидает
+// возобновления (команда resume управляющего сокета).
+const replQueue = 1024
+
+// replReader - ввод консоли с управляющими командами: строки, начинающиеся
+// с ":", выполняются как команды (":flush", ":set flush-interval 2s") до
+// разбора чисел, остальные строки читаются через Read.
+type replReader struct {
+	lines chan []byte
+	cur   []byte
+	err   error // Ошибка чтения (доступна после закрытия lines)
+}
+
+// newREPLReader - чтение r с выполнением команд из cmds. Ответы выводятся в stderr.
+func newREPLReader(r io.Reader, cmds *commandSet) *replReader {
+	rr := &replReader{lines: make(chan []byte, replQueue)}
+	go rr.scan(r, cmds)
+	return rr
+}
+
+// scan - чтение строк r до конца ввода или ошибки.
+func (r *replReader) scan(src io.Reader, cmds *commandSet) {
+	defer close(r.lines)
+	br := bufio.NewReader(src)
+	for {
+		line, err := br.ReadBytes('\n')
+		if cmd, ok := strings.CutPrefix(strings.TrimSpace(string(line)), ":"); ok {
+			reply, err := cmds.exec(cmd)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Ошибка команды: %v\n", err)
+			} else {
+				fmt.Fprintln(os.Stderr, reply)
+			}
+		} else if len(line) > 0 {
+			r.lines <- line
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				r.err = err
+			}
+			return
+		}
+	}
+}
+
+// Read - чтение строк данных.
+func (r *replReader) Read(p []byte) (int, error) {
+	for len(r.cur) == 0 {
+		line, ok := <-r.lines
+		if !ok {
+			if r.err != nil {
+				return 0, r.err
+			}
+			return 0, io.EOF
+		}
+		r.cur = line
+	}
+	n := copy(p, r.cur)
+	r.cur = r.cur[n:]
+	return n, nil
+}
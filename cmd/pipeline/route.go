@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// routeBranch - ветвь стадии route в конфигурации.
+type routeBranch struct {
+	Name   string      `json:"name"`   // Имя ветви (пусто - ее номер)
+	When   string      `json:"when"`   // Выражение над x (пусто - ветвь по умолчанию)
+	Stages []stageSpec `json:"stages"` // Стадии ветви
+	Output string      `json:"output"` // Файл вывода ветви (пусто - объединение с выходом route)
+	Format string      `json:"format"` // Формат файла вывода: text или jsonl
+}
+
+// Стадия route создает стадии ветвей через реестр, поэтому регистрируется
+// после его инициализации.
+func init() {
+	stageRegistry["route"] = stageDef{
+		doc:    "разветвление по условиям when ветвей branches",
+		params: []string{"branches"},
+		// Файлы ветвей закрываются при завершении стадии
+		restart: pipeline.RestartNever,
+		build:   buildRoute,
+	}
+}
+
+// parseRouteBranches - разбор параметра branches: списка ветвей в том же
+// виде, что и в файле конфигурации.
+func parseRouteBranches(p stageParams) ([]routeBranch, error) {
+	v, ok := p["branches"]
+	if !ok {
+		return nil, fmt.Errorf("необходимо задать параметр branches")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("параметр branches: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var branches []routeBranch
+	if err := dec.Decode(&branches); err != nil {
+		return nil, fmt.Errorf("параметр branches: ожидается список ветвей (name, when, stages, output, format): %w", err)
+	}
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("параметр branches: не задано ни одной ветви")
+	}
+	return branches, nil
+}
+
+// buildRoute - стадия route: значения направляются в первую ветвь, выражение
+// when которой истинно, иначе - в ветвь без when. Ветви с output выводят
+// значения в свой файл, выходы остальных объединяются в выход стадии.
+func buildRoute(p stageParams, _ stageEnv) (pipeline.Stage[envelope], error) {
+	specs, err := parseRouteBranches(p)
+	if err != nil {
+		return nil, err
+	}
+	var files []*os.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	branches := make([]pipeline.Branch[envelope], 0, len(specs))
+	for i, spec := range specs {
+		spec.Name = cmp.Or(spec.Name, strconv.Itoa(i+1))
+		b, f, err := buildBranch(spec)
+		if err != nil {
+			closeFiles()
+			return nil, fmt.Errorf("ветвь %s: %w", spec.Name, err)
+		}
+		if f != nil {
+			files = append(files, f)
+		}
+		branches = append(branches, b)
+	}
+	route := pipeline.Route(branches...)
+	return func(ctx context.Context, in <-chan envelope, out chan<- envelope) {
+		defer closeFiles()
+		route(ctx, in, out)
+	}, nil
+}
+
+// buildBranch - ветвь route по описанию и ее открытый файл вывода (nil - без файла).
+func buildBranch(spec routeBranch) (pipeline.Branch[envelope], *os.File, error) {
+	b := pipeline.Branch[envelope]{Name: spec.Name}
+	if spec.When != "" {
+		pred, err := pipeline.CompileExpr(spec.When)
+		if err != nil {
+			return b, nil, fmt.Errorf("выражение when %q: %w", spec.When, err)
+		}
+		b.When = func(it envelope) bool { return pred(it.Value) }
+	}
+	stages, _, err := buildStages(spec.Stages, buildOptions{})
+	if err != nil {
+		return b, nil, err
+	}
+	b.Stages = stages
+	if spec.Output == "" {
+		if spec.Format != "" {
+			return b, nil, fmt.Errorf("формат format задается только вместе с output")
+		}
+		return b, nil, nil
+	}
+	sink, f, err := openOutputFile(spec.Output, spec.Format)
+	if err != nil {
+		return b, nil, err
+	}
+	b.Sink = sink
+	return b, f, nil
+}
+
+// openOutputFile - приемник, дописывающий значения в файл path в формате
+// format (text или jsonl, пусто - text), и открытый файл.
+func openOutputFile(path, format string) (pipeline.Sink[envelope], *os.File, error) {
+	if format != "" && format != formatText && format != formatJSONL {
+		return nil, nil, fmt.Errorf("неизвестный формат %q (ожидается %s или %s)", format, formatText, formatJSONL)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("файл вывода: %w", err)
+	}
+	var sink pipeline.Sink[pipeline.Num] = pipeline.NewWriterSink[pipeline.Num](f, "%s\n")
+	if format == formatJSONL {
+		sink = newJSONLSink(f, nil)
+	}
+	return itemsSink{sink}, f, nil
+}
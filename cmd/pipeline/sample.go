@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Режимы стадии sample.
+const (
+	sampleEvery     = "every"     // Каждое n-е значение
+	sampleRandom    = "random"    // Случайная доля значений
+	sampleReservoir = "reservoir" // Случайные size значений каждого окна interval
+)
+
+// parseSampleSpec - параметры стадии sample из описания флага -sample:
+// "10%" - случайные 10% значений, "1/N" - каждое N-е значение,
+// "K/длительность" (например, 100/1s) - K случайных значений каждого окна.
+func parseSampleSpec(spec string) (stageParams, error) {
+	if p, ok := strings.CutSuffix(spec, "%"); ok {
+		percent, err := strconv.ParseFloat(p, 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("выборка %q: ожидается процент от 0 до 100", spec)
+		}
+		return stageParams{"mode": sampleRandom, "percent": percent}, nil
+	}
+	k, window, ok := strings.Cut(spec, "/")
+	size, err := strconv.Atoi(k)
+	if !ok || err != nil || size <= 0 {
+		return nil, fmt.Errorf("выборка %q: ожидается 10%%, 1/N или K/длительность", spec)
+	}
+	if n, err := strconv.Atoi(window); err == nil {
+		if size != 1 || n <= 0 {
+			return nil, fmt.Errorf("выборка %q: каждое N-е значение задается как 1/N с N > 0", spec)
+		}
+		return stageParams{"mode": sampleEvery, "n": n}, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("выборка %q: ожидается положительная длительность окна", spec)
+	}
+	return stageParams{"mode": sampleReservoir, "size": size, "interval": d.String()}, nil
+}
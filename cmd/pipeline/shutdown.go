@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+)
+
+// errInterrupted - немедленное завершение по повторному сигналу.
+var errInterrupted = errors.New("повторное прерывание")
+
+// shutdown - поэтапное завершение по сигналу: остановка источника, дообработка
+// принятых значений стадиями и ограничение времени их доставки.
+type shutdown struct {
+	requested atomic.Bool // Получен сигнал завершения
+	expired   atomic.Bool // Истекло время доставки: значения не выводятся
+	sigs      chan os.Signal
+	stop      func()
+}
+
+// handleSignals - обработка shutdownSignals (SIGINT и SIGTERM). Первый сигнал вызывает stopSource,
+// по истечении timeout - stopSink; повторный сигнал отменяет пайплайн через cancel.
+func handleSignals(timeout time.Duration, stopSource, stopSink func(), cancel context.CancelCauseFunc) *shutdown {
+	sigs := make(chan os.Signal, 1)
+	s := &shutdown{sigs: sigs}
+	signal.Notify(sigs, shutdownSignals...)
+	done := make(chan struct{})
+	s.stop = func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+	go func() {
+		var deadline *time.Timer
+		defer func() {
+			if deadline != nil {
+				deadline.Stop()
+			}
+		}()
+		for {
+			select {
+			case <-sigs:
+				if s.requested.Swap(true) {
+					slog.Warn("Повторное прерывание: немедленное завершение")
+					cancel(errInterrupted)
+					return
+				}
+				slog.Info("Завершение: источник остановлен, обработка оставшихся значений", "drain_timeout", timeout)
+				stopSource()
+				deadline = time.AfterFunc(timeout, func() {
+					s.expired.Store(true)
+					stopSink()
+					slog.Warn("Истекло время дообработки, оставшиеся значения не выводятся")
+				})
+			case <-done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// interrupt - завершение, как по сигналу (например, клавишей q панели -tui).
+func (s *shutdown) interrupt() {
+	select {
+	case s.sigs <- os.Interrupt:
+	default: // Предыдущий запрос еще не обработан
+	}
+}
+
+// Stop - прекращение обработки сигналов.
+func (s *shutdown) Stop() {
+	s.stop()
+}
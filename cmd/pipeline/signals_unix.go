@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// shutdownSignals - сигналы поэтапного завершения.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// reloadSignals - сигналы перечитывания файла -config.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
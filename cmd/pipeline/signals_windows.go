@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// shutdownSignals - сигналы поэтапного завершения: Ctrl+C и Ctrl+Break
+// (os.Interrupt), закрытие окна консоли, выход из системы и ее выключение
+// (SIGTERM).
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// reloadSignals - сигналы перечитывания файла -config: в Windows SIGHUP
+// не доставляется, поэтому файл не перечитывается.
+var reloadSignals []os.Signal
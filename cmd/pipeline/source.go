@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// gzipMagic - сигнатура gzip-потока.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// openInput - открытие входного файла с прозрачной распаковкой gzip.
+func openInput(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(len(gzipMagic)); string(magic) == string(gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{zr, f}, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{br, f}, nil
+}
+
+// inputFiles - список входных файлов: файл input или все обычные файлы
+// каталога inputDir в лексикографическом порядке.
+func inputFiles(input, inputDir string) ([]string, error) {
+	if inputDir == "" {
+		return []string{input}, nil
+	}
+	entries, err := os.ReadDir(inputDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			files = append(files, filepath.Join(inputDir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fileSource - источник чисел из последовательности файлов.
+type fileSource struct {
+	files []string
+	path  string
+	cur   io.ReadCloser
+	src   pipeline.Source[pipeline.Num]
+	cfg   config   // Формат входных данных
+	rej   *rejects // Вывод некорректных строк
+	skip  uint64   // Значения, пропускаемые при продолжении с контрольной точки
+}
+
+// Next - очередное число; при исчерпании файла открывается следующий.
+func (s *fileSource) Next() (pipeline.Num, error) {
+	for {
+		if s.src == nil {
+			if len(s.files) == 0 {
+				return pipeline.Num{}, io.EOF
+			}
+			s.path, s.files = s.files[0], s.files[1:]
+			r, err := openInput(s.path)
+			if err != nil {
+				return pipeline.Num{}, err
+			}
+			s.cur = r
+			report := s.rej.report(s.path)
+			s.src = newInputSource(r, s.cfg, func(lineNo int, line string, err error) {
+				if s.skip == 0 { // Строки до контрольной точки уже обработаны
+					report(lineNo, line, err)
+				}
+			})
+		}
+		num, err := s.src.Next()
+		if err == nil {
+			if s.skip > 0 {
+				s.skip--
+				continue
+			}
+			s.rej.rec.value(num)
+			return num, nil
+		}
+		s.cur.Close()
+		s.src = nil
+		if !errors.Is(err, io.EOF) {
+			return pipeline.Num{}, fmt.Errorf("%s: %w", s.path, err)
+		}
+	}
+}
+
+// Close - закрытие текущего файла.
+func (s *fileSource) Close() error {
+	if s.src == nil {
+		return nil
+	}
+	s.src = nil
+	return s.cur.Close()
+}
+
+// relay - передача значений из feed в input до закрытия feed или отмены ctx.
+// Пока источник приостановлен через gate, значения не передаются.
+// Поступление значений отмечается в st. По завершении input закрывается
+// вместе с журналом сеанса rec (может быть nil).
+func relay(ctx context.Context, feed <-chan envelope, input chan<- envelope, gate *sourceGate, st *runStats, rec *recorder) {
+	defer close(input)
+	defer rec.Close()
+	for {
+		select {
+		case v, ok := <-feed:
+			if !ok {
+				return
+			}
+			st.received()
+			if !gate.wait(ctx) {
+				return
+			}
+			select {
+			case input <- v:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rejects - вывод некорректных входных строк: в отдельный файл или в журнал.
+type rejects struct {
+	mu  sync.Mutex
+	w   io.WriteCloser // nil - вывод в журнал
+	dl  *deadLetter    // Файл недоставленных значений (nil - не используется)
+	st  *runStats      // Учет некорректных строк (nil - не ведется)
+	rec *recorder      // Журнал сеанса (nil - не ведется)
+}
+
+// openRejects - открытие вывода некорректных строк в файл path (пусто - журнал).
+// При заданном dl строки дополнительно записываются в файл недоставленных значений,
+// при заданном rec - в журнал сеанса.
+func openRejects(path string, dl *deadLetter, st *runStats, rec *recorder) (*rejects, error) {
+	if path == "" {
+		return &rejects{dl: dl, st: st, rec: rec}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rejects{w: f, dl: dl, st: st, rec: rec}, nil
+}
+
+// report - обработчик некорректных строк источника origin (файл, адрес клиента).
+func (r *rejects) report(origin string) func(lineNo int, line string, err error) {
+	return func(lineNo int, line string, err error) {
+		r.st.invalidInput()
+		r.rec.invalid(line)
+		if r.dl != nil {
+			r.dl.write(deadLetterRecord{
+				Stage:  "source",
+				Value:  line,
+				Reason: fmt.Sprintf("%s:%d: %v", origin, lineNo, err),
+			})
+		}
+		if r.w == nil {
+			stageLog("source").Warn("Некорректный ввод", "origin", origin, "line_no", lineNo, "line", line, "err", err)
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		fmt.Fprintf(r.w, "%s:%d: %v: %s\n", origin, lineNo, err, line)
+	}
+}
+
+// Close - закрытие файла некорректных строк.
+func (r *rejects) Close() error {
+	if r.w == nil {
+		return nil
+	}
+	return r.w.Close()
+}
+
+// sourceName - идентификатор источника данных конфигурации cfg
+// в метаданных значений.
+func sourceName(cfg config) string {
+	switch {
+	case cfg.replayPath != "":
+		return "replay:" + cfg.replayPath
+	case cfg.listen != "":
+		return "listen:" + cfg.listen
+	case cfg.ingest != "":
+		return "http:" + cfg.ingest
+	case cfg.kafkaTopic != "":
+		return "kafka:" + cfg.kafkaTopic
+	case cfg.sourceURL != "":
+		// Адрес может содержать пароль, поэтому в идентификатор входит только ключ
+		if t, err := parseRedisURL(cfg.sourceURL); err == nil {
+			return "redis:" + t.key
+		}
+		return "redis"
+	case cfg.mqttTopic != "":
+		return "mqtt:" + cfg.mqttTopic
+	case cfg.input != "":
+		return "file:" + cfg.input
+	case cfg.inputDir != "":
+		return "dir:" + cfg.inputDir
+	}
+	return "stdin"
+}
+
+// startSource - запуск источника данных согласно конфигурации: журнал сеанса,
+// сеть, HTTP, Kafka, Redis, MQTT, файлы или консоль, а с -merge - нескольких
+// источников, значения которых объединяются поочередно с весами.
+// Значения передаются в input с именем источника; по завершении источников
+// input закрывается, ошибка чтения передается в errc. Некорректные строки
+// выводятся в файл cfg.errorsPath или журнал, при -dead-letter-rejects - также
+// в файл недоставленных значений dl, и учитываются в статистике rc; при -record
+// входные данные записываются в журнал сеанса. Источник приостанавливается через rc,
+// ввод консоли может содержать команды rc (см. replReader).
+// Первые cp.skip() значений файлового источника пропускаются (продолжение
+// с контрольной точки); источники Kafka и Redis подтверждают сообщения
+// в контрольных точках cp.
+func startSource(ctx context.Context, cfg config, cp *checkpointer, dl *deadLetter, rc *runControl, input chan<- envelope, errc chan<- error) error {
+	if !cfg.deadLetterRejects {
+		dl = nil
+	}
+	rec, err := openRecorder(cfg.recordPath)
+	if err != nil {
+		return err
+	}
+	rej, err := openRejects(cfg.errorsPath, dl, rc.stats, rec)
+	if err != nil {
+		rec.Close()
+		return err
+	}
+
+	configs, weights := []config{cfg}, []int{1}
+	if cfg.merge != "" {
+		sources, _ := parseMerge(cfg.merge) // Проверено в validate
+		configs, weights = configs[:0], weights[:0]
+		for _, m := range sources {
+			configs = append(configs, cfg.only(m.kind))
+			weights = append(weights, m.weight)
+		}
+	}
+	var wg sync.WaitGroup
+	var feeds []<-chan envelope
+	var names []string
+	for _, sc := range configs {
+		values := make(chan pipeline.Num)
+		wg.Add(1)
+		if err := runSource(ctx, sc, cp, rc, rej, rec, values, errc, wg.Done); err != nil {
+			rej.Close()
+			rec.Close()
+			return err
+		}
+		name := sourceName(sc)
+		names = append(names, name)
+		feeds = append(feeds, tagSource(ctx, values, name))
+	}
+	// Некорректные строки выводятся, пока работает хотя бы один источник
+	go func() {
+		wg.Wait()
+		rej.Close()
+	}()
+	feed := feeds[0]
+	if len(feeds) > 1 {
+		feed = pipeline.MergeWeighted(ctx, feeds, weights)
+	}
+
+	// Чтение источника может блокироваться (например, stdin), поэтому вход
+	// пайплайна закрывается ретранслятором сразу после отмены ctx.
+	// Ретранслятор также приостанавливает источник
+	rc.source.name = strings.Join(names, ",")
+	go func() {
+		relay(ctx, feed, input, rc.gate, rc.stats, rec)
+		rc.source.closed.Store(true)
+	}()
+	return nil
+}
+
+// tagSource - значения in с источником source. Момент поступления и номер
+// присваиваются после объединения источников (см. startPipeline).
+func tagSource(ctx context.Context, in <-chan pipeline.Num, source string) <-chan envelope {
+	out := make(chan envelope)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- envelope{Value: v, Source: source}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// runSource - запуск одного источника конфигурации cfg с передачей значений
+// в input. По завершении источника input закрывается и вызывается done.
+func runSource(ctx context.Context, cfg config, cp *checkpointer, rc *runControl, rej *rejects, rec *recorder, input chan<- pipeline.Num, errc chan<- error, done func()) error {
+	switch {
+	case cfg.replayPath != "":
+		// Источник данных: журнал сеанса -record
+		speed, _ := parseSpeed(cfg.replaySpeed) // Проверено в validate
+		stageLog("source").Info("Программа запущена. Воспроизведение журнала сеанса", "path", cfg.replayPath, "speed", cfg.replaySpeed)
+		go func() {
+			defer close(input)
+			defer done()
+			if err := replayRecords(ctx, cfg.replayPath, speed, cfg, rej, input); err != nil {
+				errc <- err
+			}
+		}()
+
+	case cfg.listen != "":
+		// Источник данных: числа от сетевых клиентов
+		ln, err := net.Listen(splitAddr(cfg.listen))
+		if err != nil {
+			return err
+		}
+		stageLog("source").Info("Программа запущена. Прием чисел по сети", "addr", ln.Addr().String())
+		go func() {
+			defer close(input)
+			defer done()
+			if err := listenInts(ctx, ln, cfg, rej, input); err != nil {
+				errc <- err
+			}
+		}()
+
+	case cfg.ingest != "":
+		// Источник данных: числа в POST-запросах /ingest
+		h := &ingestHandler{ctx: ctx, cfg: cfg, rej: rej, input: input}
+		mux := http.NewServeMux()
+		mux.Handle("/ingest", h)
+		addr, err := startHTTP(ctx, cfg.ingest, mux)
+		if err != nil {
+			return err
+		}
+		stageLog("source").Info("Программа запущена. Прием чисел по HTTP", "url", "http://"+addr.String()+"/ingest")
+		go func() {
+			<-ctx.Done()
+			h.stop()
+			close(input)
+			done()
+		}()
+
+	case cfg.kafkaTopic != "":
+		// Источник данных: сообщения топика Kafka
+		src := newKafkaSource(cfg, rej)
+		cp.store = src.commit
+		rc.source.probe = kafkaProbe(kafkaBrokers(cfg.kafkaBrokers))
+		stageLog("source").Info("Программа запущена. Чтение топика Kafka", "topic", cfg.kafkaTopic, "group", cfg.kafkaGroup)
+		go func() {
+			defer close(input)
+			defer done()
+			defer src.Close()
+			if err := src.run(ctx, input); err != nil {
+				errc <- err
+			}
+		}()
+
+	case cfg.sourceURL != "":
+		// Источник данных: поток или список Redis
+		t, err := parseRedisURL(cfg.sourceURL)
+		if err != nil {
+			return err
+		}
+		src := newRedisSource(t, cfg, rej)
+		cp.store = src.commit
+		rc.source.probe = func(ctx context.Context) error { return src.c.Ping(ctx).Err() }
+		stageLog("source").Info("Программа запущена. Чтение Redis", "addr", t.opts.Addr, "key", t.key, "type", t.kind)
+		go func() {
+			defer close(input)
+			defer done()
+			defer src.Close()
+			src.run(ctx, input)
+		}()
+
+	case cfg.mqttTopic != "":
+		// Источник данных: сообщения топика MQTT
+		src := newMQTTSource(cfg, rej)
+		rc.source.probe = src.probe
+		stageLog("source").Info("Программа запущена. Подписка на топик MQTT", "broker", cfg.mqttBroker, "topic", cfg.mqttTopic, "qos", cfg.mqttQoS)
+		go func() {
+			defer close(input)
+			defer done()
+			defer src.Close()
+			src.run(ctx, input)
+		}()
+
+	case cfg.input != "" || cfg.inputDir != "":
+		// Источник данных: чтение чисел из файлов
+		files, err := inputFiles(cfg.input, cfg.inputDir)
+		if err != nil {
+			return err
+		}
+		stageLog("source").Info("Программа запущена. Чтение файлов", "files", files)
+		src := &fileSource{files: files, cfg: cfg, rej: rej, skip: cp.skip()}
+		go func() {
+			defer close(input)
+			defer done()
+			defer src.Close()
+			if err := pipeline.Feed[pipeline.Num](ctx, src, input); err != nil && ctx.Err() == nil {
+				errc <- err
+			}
+		}()
+
+	default:
+		// Приглашения - только при вводе с клавиатуры: при чтении из канала
+		// (cat data | pipeline) некорректные строки выводятся как из файла
+		interactive := isTerminal(os.Stdin)
+		if interactive {
+			stageLog("source").Info("Программа запущена. Начинайте вводить числа")
+		} else {
+			stageLog("source").Info("Программа запущена. Чтение stdin")
+		}
+
+		// Источник данных: чтение чисел из консоли
+		report := rej.report("stdin")
+		if interactive && rej.w == nil && rej.dl == nil && cfg.format == formatText {
+			report = func(_ int, line string, _ error) {
+				rej.st.invalidInput()
+				rej.rec.invalid(line)
+				stageLog("source").Warn("Некорректный ввод. Введите число")
+			}
+		}
+		src := recordSource(newInputSource(newREPLReader(os.Stdin, rc.cmds), cfg, report), rec)
+		go func() {
+			defer close(input)
+			defer done()
+			pipeline.Feed[pipeline.Num](ctx, src, input)
+			stageLog("source").Info("Ввод завершен")
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// statsSamples - количество задержек, сохраняемых для расчета перцентилей.
+const statsSamples = 4096
+
+// rejectKey - стадия и причина отбрасывания значений.
+type rejectKey struct {
+	stage, reason string
+}
+
+// runStats - статистика работы пайплайна для команды stats и итогового
+// отчета (-stats, -stats-out). Методы допускают nil-получатель: статистика
+// не собирается.
+type runStats struct {
+	start   time.Time
+	seq     pipeline.Sequence // Номера значений на входе
+	started atomic.Bool       // Пайплайн запущен
+	invalid atomic.Uint64     // Некорректные входные строки
+	flushes atomic.Uint64     // Отправки партий буфера
+	input   atomic.Int64      // Момент последней входной строки (UnixNano)
+
+	mu       sync.Mutex
+	rejected map[rejectKey]uint64
+	errors   uint64
+	emitted  uint64
+	sum      time.Duration   // Суммарная задержка от входа до выхода
+	max      time.Duration   // Наибольшая задержка
+	samples  []time.Duration // Равномерная выборка задержек (reservoir sampling)
+}
+
+// newRunStats - пустая статистика работы.
+func newRunStats() *runStats {
+	s := &runStats{start: time.Now(), rejected: make(map[rejectKey]uint64)}
+	s.input.Store(s.start.UnixNano())
+	return s
+}
+
+// running - пайплайн запущен (значения учитываются).
+func (s *runStats) running() bool {
+	return s != nil && s.started.Load()
+}
+
+// invalidInput - учет некорректной входной строки.
+func (s *runStats) invalidInput() {
+	if s == nil {
+		return
+	}
+	s.invalid.Add(1)
+	s.received()
+}
+
+// received - отметка о поступлении входной строки (корректной или нет).
+func (s *runStats) received() {
+	if s == nil {
+		return
+	}
+	s.input.Store(time.Now().UnixNano())
+}
+
+// lastInput - момент последней входной строки (начало работы, если их не было).
+func (s *runStats) lastInput() time.Time {
+	return time.Unix(0, s.input.Load())
+}
+
+// countFlushes - обработчик отправки партии буфера, учитывающий отправку
+// и передающий ее next (может быть nil).
+func (s *runStats) countFlushes(next func(batch uint64, n int)) func(batch uint64, n int) {
+	return func(batch uint64, n int) {
+		s.flushes.Add(1)
+		if next != nil {
+			next(batch, n)
+		}
+	}
+}
+
+// reject - учет значения, отброшенного стадией.
+func (s *runStats) reject(r *pipeline.Rejection) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[rejectKey{r.Stage, r.Reason}]++
+}
+
+// failed - учет ошибки стадии.
+func (s *runStats) failed() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors++
+}
+
+// emit - учет выведенного значения и его задержки от входа до выхода.
+func (s *runStats) emit(it envelope) {
+	if s == nil {
+		return
+	}
+	d := it.Latency(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emitted++
+	s.sum += d
+	s.max = max(s.max, d)
+	if len(s.samples) < statsSamples {
+		s.samples = append(s.samples, d)
+	} else if i := rand.Uint64N(s.emitted); i < statsSamples {
+		s.samples[i] = d
+	}
+}
+
+// rejectStat - количество значений, отброшенных стадией по причине.
+type rejectStat struct {
+	Stage  string `json:"stage"`
+	Reason string `json:"reason"`
+	Count  uint64 `json:"count"`
+}
+
+// latencyStats - задержка от входа до выхода в миллисекундах.
+type latencyStats struct {
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P90Ms float64 `json:"p90_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// statsReport - итоговый отчет о работе пайплайна.
+type statsReport struct {
+	Read            uint64       `json:"read"`    // Значений на входе пайплайна
+	Invalid         uint64       `json:"invalid"` // Некорректных входных строк
+	Emitted         uint64       `json:"emitted"` // Выведенных значений
+	Rejected        []rejectStat `json:"rejected"`
+	Errors          uint64       `json:"errors"`  // Ошибок стадий
+	Flushes         uint64       `json:"flushes"` // Отправок партий буфера
+	Latency         latencyStats `json:"latency"`
+	DurationSeconds float64      `json:"duration_seconds"`
+}
+
+// report - снимок статистики.
+func (s *runStats) report() statsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := statsReport{
+		Read:            s.seq.Last(),
+		Invalid:         s.invalid.Load(),
+		Emitted:         s.emitted,
+		Rejected:        []rejectStat{},
+		Errors:          s.errors,
+		Flushes:         s.flushes.Load(),
+		DurationSeconds: time.Since(s.start).Seconds(),
+	}
+	for k, n := range s.rejected {
+		r.Rejected = append(r.Rejected, rejectStat{Stage: k.stage, Reason: k.reason, Count: n})
+	}
+	slices.SortFunc(r.Rejected, func(a, b rejectStat) int {
+		return cmp.Or(cmp.Compare(a.Stage, b.Stage), cmp.Compare(a.Reason, b.Reason))
+	})
+	if s.emitted > 0 {
+		samples := slices.Clone(s.samples)
+		slices.Sort(samples)
+		r.Latency = latencyStats{
+			AvgMs: ms(s.sum / time.Duration(s.emitted)),
+			P50Ms: ms(percentile(samples, 0.50)),
+			P90Ms: ms(percentile(samples, 0.90)),
+			P99Ms: ms(percentile(samples, 0.99)),
+			MaxMs: ms(s.max),
+		}
+	}
+	return r
+}
+
+// percentile - перцентиль q (0..1) отсортированной выборки.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(q * float64(len(sorted)))
+	return sorted[min(i, len(sorted)-1)]
+}
+
+// ms - длительность в миллисекундах.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// print - вывод отчета в w.
+func (r statsReport) print(w io.Writer) {
+	fmt.Fprintln(w, "Итоги работы:")
+	fmt.Fprintf(w, "  Прочитано значений: %d (некорректных строк: %d)\n", r.Read, r.Invalid)
+	fmt.Fprintf(w, "  Выведено значений: %d\n", r.Emitted)
+	var rejected uint64
+	for _, rs := range r.Rejected {
+		rejected += rs.Count
+	}
+	fmt.Fprintf(w, "  Отброшено стадиями: %d\n", rejected)
+	for _, rs := range r.Rejected {
+		fmt.Fprintf(w, "    %s: %s - %d\n", rs.Stage, rs.Reason, rs.Count)
+	}
+	fmt.Fprintf(w, "  Ошибок стадий: %d\n", r.Errors)
+	fmt.Fprintf(w, "  Отправок буфера: %d\n", r.Flushes)
+	if r.Emitted > 0 {
+		l := r.Latency
+		fmt.Fprintf(w, "  Задержка от входа до выхода, мс: средняя %.3f, p50 %.3f, p90 %.3f, p99 %.3f, макс %.3f\n",
+			l.AvgMs, l.P50Ms, l.P90Ms, l.P99Ms, l.MaxMs)
+	}
+	fmt.Fprintf(w, "  Время работы: %s\n", time.Duration(r.DurationSeconds*float64(time.Second)).Round(time.Millisecond))
+}
+
+// finish - вывод итогового отчета в stderr (-stats) и запись в JSON-файл
+// (-stats-out). Отчет формируется, только если пайплайн был запущен.
+func (s *runStats) finish(cfg config) {
+	if !s.running() {
+		return
+	}
+	r := s.report()
+	if cfg.stats {
+		r.print(os.Stderr)
+	}
+	if cfg.statsOut != "" {
+		if err := writeStats(cfg.statsOut, r); err != nil {
+			slog.Error("Ошибка записи итогов работы", "path", cfg.statsOut, "err", err)
+		}
+	}
+}
+
+// writeStats - запись отчета r в файл path в формате JSON.
+func writeStats(path string, r statsReport) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// streamClientBuffer - количество значений, ожидающих отправки одному клиенту
+// /stream. Клиент, не успевающий их принимать, отключается.
+const streamClientBuffer = 256
+
+// streamEvent - значение на выходе пайплайна с порядковым номером.
+type streamEvent struct {
+	id    uint64
+	value pipeline.Num
+}
+
+// broadcaster - рассылка обработанных значений клиентам GET /stream
+// в формате Server-Sent Events. Методы допускают nil-получатель.
+type broadcaster struct {
+	mu      sync.Mutex
+	clients map[chan streamEvent]string // Очередь клиента -> адрес
+	next    uint64
+	closed  bool
+}
+
+// newBroadcaster - создание рассылки без клиентов.
+func newBroadcaster() *broadcaster {
+	return &broadcaster{clients: make(map[chan streamEvent]string)}
+}
+
+// publish - отправка значения v всем клиентам без ожидания. Клиенты
+// с заполненной очередью отключаются, чтобы не задерживать пайплайн.
+func (b *broadcaster) publish(v pipeline.Num) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.next++
+	ev := streamEvent{id: b.next, value: v}
+	for ch, remote := range b.clients {
+		select {
+		case ch <- ev:
+		default:
+			stageLog("stream").Warn("Клиент не успевает принимать данные и отключен", "remote", remote, "buffer", streamClientBuffer)
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe - регистрация клиента; nil, если рассылка завершена.
+func (b *broadcaster) subscribe(remote string) chan streamEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	ch := make(chan streamEvent, streamClientBuffer)
+	b.clients[ch] = remote
+	return ch
+}
+
+// unsubscribe - отключение клиента ch, если он еще не отключен.
+func (b *broadcaster) unsubscribe(ch chan streamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[ch]; ok {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+// Close - завершение рассылки: потоки клиентов закрываются.
+func (b *broadcaster) Close() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.clients {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+// ServeHTTP - поток значений клиенту: событие на каждое значение,
+// поле id - порядковый номер значения на выходе.
+func (b *broadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "ожидается GET", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "потоковая передача не поддерживается", http.StatusInternalServerError)
+		return
+	}
+	ch := b.subscribe(r.RemoteAddr)
+	if ch == nil {
+		http.Error(w, "пайплайн завершен", http.StatusServiceUnavailable)
+		return
+	}
+	defer b.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	log := stageLog("stream")
+	log.Info("Клиент подключен", "remote", r.RemoteAddr)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				log.Info("Поток клиента закрыт", "remote", r.RemoteAddr)
+				return
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.id, ev.value); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			log.Info("Клиент отключился", "remote", r.RemoteAddr)
+			return
+		}
+	}
+}
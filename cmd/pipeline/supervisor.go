@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/signal"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Интервалы ожидания перед перезапуском (растут вдвое после каждого перезапуска).
+const (
+	restartMinBackoff = time.Second
+	restartMaxBackoff = time.Minute
+)
+
+// shouldRestart - требуется ли перезапуск после завершения с кодом code
+// (политики pipeline.RestartNever, RestartOnFailure и RestartAlways).
+func shouldRestart(policy string, code int) bool {
+	return policy == pipeline.RestartAlways || policy == pipeline.RestartOnFailure && code != 0
+}
+
+// pipelineConfig - конфигурация пайплайна spec: общие параметры base,
+// переопределенные флагами spec.Args, и стадии spec.Stages.
+func pipelineConfig(base config, spec pipelineSpec) (config, error) {
+	cfg := base
+	cfg.configPath, cfg.pipelines = "", nil
+	cfg.stages = spec.Stages
+	fs := flag.NewFlagSet(spec.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg.registerFlags(fs)
+	if err := fs.Parse(spec.Args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("лишние аргументы: %v", fs.Args())
+	}
+	if cfg.configPath != "" {
+		return cfg, fmt.Errorf("флаг -config недоступен в args пайплайна")
+	}
+	return cfg, cfg.validate()
+}
+
+// pipelineConfigs - проверка и построение конфигураций пайплайнов
+// base.pipelines. Пустая политика перезапуска заменяется на on-failure.
+func pipelineConfigs(base config) ([]config, error) {
+	cfgs := make([]config, len(base.pipelines))
+	stdin := ""
+	for i := range base.pipelines {
+		spec := &base.pipelines[i]
+		if spec.Restart == "" {
+			spec.Restart = pipeline.RestartOnFailure
+		}
+		if err := pipeline.ValidateRestart(spec.Restart); err != nil {
+			return nil, fmt.Errorf("пайплайн %s: %w", spec.Name, err)
+		}
+		cfg, err := pipelineConfig(base, *spec)
+		if err != nil {
+			return nil, fmt.Errorf("пайплайн %s: %w", spec.Name, err)
+		}
+		if cfg.readsStdin() {
+			if stdin != "" {
+				return nil, fmt.Errorf("консоль может быть источником только одного пайплайна (%s и %s)", stdin, spec.Name)
+			}
+			stdin = spec.Name
+		}
+		cfgs[i] = cfg
+	}
+	return cfgs, nil
+}
+
+// superviseCommand - запуск независимых пайплайнов base.pipelines и надзор
+// за ними: каждый пайплайн перезапускается согласно своей политике с растущим
+// интервалом ожидания. По SIGINT или SIGTERM пайплайны завершаются без
+// перезапуска. Возвращает наибольший код завершения пайплайнов.
+func superviseCommand(base config) int {
+	cfgs, err := pipelineConfigs(base)
+	if err != nil {
+		slog.Error("Ошибка конфигурации", "err", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	defer stop()
+	codes := make([]int, len(cfgs))
+	var wg sync.WaitGroup
+	for i, spec := range base.pipelines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = supervise(ctx, spec, cfgs[i])
+		}()
+	}
+	wg.Wait()
+	return slices.Max(codes)
+}
+
+// supervise - запуск пайплайна spec с перезапуском согласно spec.Restart до
+// отмены ctx. Возвращает код последнего завершения.
+func supervise(ctx context.Context, spec pipelineSpec, cfg config) int {
+	log := slog.With("pipeline", spec.Name)
+	backoff := restartMinBackoff
+	for restarts := 0; ; restarts++ {
+		log.Info("Пайплайн запущен", "restarts", restarts)
+		started := time.Now()
+		code := runPipeline(cfg)
+		if ctx.Err() != nil || !shouldRestart(spec.Restart, code) {
+			log.Info("Пайплайн завершен", "code", code, "restarts", restarts)
+			return code
+		}
+		// Долго проработавший пайплайн перезапускается без накопленной задержки
+		if time.Since(started) > restartMaxBackoff {
+			backoff = restartMinBackoff
+		}
+		log.Warn("Пайплайн завершен, перезапуск", "code", code, "after", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			log.Info("Пайплайн завершен", "code", code, "restarts", restarts)
+			return code
+		}
+		backoff = min(backoff*2, restartMaxBackoff)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableANSI - поддерживает ли терминал f управляющие последовательности ANSI.
+func enableANSI(*os.File) bool { return true }
+
+// openTTY - управляющий терминал процесса для чтения клавиш (в том числе
+// при stdin из канала).
+func openTTY() (*os.File, error) {
+	return os.Open("/dev/tty")
+}
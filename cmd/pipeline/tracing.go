@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Режимы трассировки.
+const (
+	traceModeItem  = "item"  // Трасса на каждое (выбранное) значение
+	traceModeBatch = "batch" // Трасса на каждую (выбранную) партию буфера
+)
+
+// Ограничения учета значений, не дошедших до приемника (отброшенных
+// фильтрами или объединенных агрегатом): записи старше traceTTL удаляются
+// при учете каждого traceSweepEvery-го значения.
+const (
+	traceTTL        = time.Minute
+	traceSweepEvery = 1024
+)
+
+// traceShutdownTimeout - наибольшее время отправки оставшихся трасс при завершении.
+const traceShutdownTimeout = 5 * time.Second
+
+// validateTracing - проверка флагов трассировки.
+func (c config) validateTracing() error {
+	if c.traceMode != traceModeItem && c.traceMode != traceModeBatch {
+		return fmt.Errorf("неизвестный режим trace-mode: %q (ожидается %s или %s)", c.traceMode, traceModeItem, traceModeBatch)
+	}
+	if c.traceSample <= 0 || c.traceSample > 1 {
+		return fmt.Errorf("trace-sample должен быть в диапазоне (0, 1]: %g", c.traceSample)
+	}
+	return nil
+}
+
+// stageTiming - прохождение значением одной стадии.
+type stageTiming struct {
+	index   int
+	name    string
+	attrs   []attribute.KeyValue // Номер и параметры стадии
+	in, out time.Time
+}
+
+// itemTrace - учитываемое значение: момент поступления и пройденные стадии.
+type itemTrace struct {
+	start  time.Time
+	stages []stageTiming
+}
+
+// batchTrace - накопленные стадии значений текущей партии на выходе.
+type batchTrace struct {
+	id     uint64
+	n      int
+	start  time.Time
+	stages []stageTiming // Начало - первый вход, конец - последний выход значений партии
+}
+
+// tracer - трассировка значений через стадии с экспортом спанов OpenTelemetry.
+// Прохождение стадий записывается по Seq значения, а спаны создаются при выводе
+// значения приемником: корневой спан от поступления до вывода, дочерние -
+// по стадиям и приемнику. Методы допускают nil-получатель.
+type tracer struct {
+	tp     *sdktrace.TracerProvider
+	tr     trace.Tracer
+	mode   string
+	sample float64
+
+	mu      sync.Mutex
+	items   map[uint64]*itemTrace
+	added   int           // Значения с прошлой очистки
+	batches *batchTracker // Партии на выходе (режим batch)
+	batch   *batchTrace   // Текущая партия (nil - нет)
+}
+
+// newTracer - трассировка с экспортом по OTLP/gRPC на cfg.otlpEndpoint.
+func newTracer(ctx context.Context, cfg config) (*tracer, error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.otlpEndpoint)}
+	if cfg.otlpInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exp, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("экспорт трасс: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "pipeline"))),
+	)
+	t := &tracer{
+		tp:     tp,
+		tr:     tp.Tracer("github.com/MosinEvgeny/Pipline"),
+		mode:   cfg.traceMode,
+		sample: cfg.traceSample,
+		items:  make(map[uint64]*itemTrace),
+	}
+	if t.mode == traceModeBatch {
+		t.batches = &batchTracker{}
+	}
+	return t, nil
+}
+
+// stage - стадия index по описанию spec с записью прохождения значений.
+// Параметры стадии становятся атрибутами ее спанов.
+func (t *tracer) stage(index int, spec stageSpec, stage pipeline.Stage[envelope]) pipeline.Stage[envelope] {
+	if t == nil {
+		return stage
+	}
+	attrs := []attribute.KeyValue{attribute.Int("pipeline.stage_index", index)}
+	for _, k := range slices.Sorted(maps.Keys(spec.Params)) {
+		attrs = append(attrs, attribute.String("pipeline.stage."+k, fmt.Sprint(spec.Params[k])))
+	}
+	return pipeline.Observe(stage,
+		func(it envelope) { t.enter(index, spec.Name, attrs, it) },
+		func(it envelope) { t.leave(index, it) })
+}
+
+// enter - вход значения в стадию. Значения учитываются с первой стадии:
+// в режиме item - с вероятностью t.sample, в режиме batch - все.
+func (t *tracer) enter(index int, name string, attrs []attribute.KeyValue, it envelope) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.items[it.Seq]
+	if e == nil {
+		if index != 1 || (t.mode == traceModeItem && rand.Float64() >= t.sample) {
+			return
+		}
+		e = &itemTrace{start: it.IngestedAt}
+		t.items[it.Seq] = e
+		if t.added++; t.added >= traceSweepEvery {
+			t.sweep(now)
+		}
+	}
+	e.stages = append(e.stages, stageTiming{index: index, name: name, attrs: attrs, in: now})
+}
+
+// leave - выход значения из стадии.
+func (t *tracer) leave(index int, it envelope) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.items[it.Seq]; e != nil && len(e.stages) > 0 {
+		if s := &e.stages[len(e.stages)-1]; s.index == index {
+			s.out = now
+		}
+	}
+}
+
+// sweep - удаление значений, не дошедших до приемника за traceTTL.
+func (t *tracer) sweep(now time.Time) {
+	t.added = 0
+	for seq, e := range t.items {
+		if now.Sub(e.start) > traceTTL {
+			delete(t.items, seq)
+		}
+	}
+}
+
+// batched - трассируются ли партии буфера.
+func (t *tracer) batched() bool {
+	return t != nil && t.batches != nil
+}
+
+// flushed - регистрация отправки партии буфера (режим batch).
+func (t *tracer) flushed(id uint64, n int) {
+	if t != nil {
+		t.batches.flushed(id, n)
+	}
+}
+
+// written - вывод значения it приемником с start по end: экспорт трассы
+// значения или, в режиме batch, учет в трассе партии.
+func (t *tracer) written(it envelope, start, end time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.items[it.Seq]
+	delete(t.items, it.Seq)
+	if t.mode == traceModeBatch {
+		t.addToBatch(it, e, start, end)
+		return
+	}
+	if e != nil {
+		t.export("item", e.start, e.stages, start, end,
+			attribute.Int64("pipeline.seq", int64(it.Seq)),
+			attribute.String("pipeline.source", it.Source),
+			attribute.String("pipeline.value", it.Value.String()))
+	}
+}
+
+// addToBatch - учет значения в трассе партии; последнее значение партии
+// экспортирует ее трассу.
+func (t *tracer) addToBatch(it envelope, e *itemTrace, start, end time.Time) {
+	id, last, ok := t.batches.next()
+	if !ok {
+		return
+	}
+	b := t.batch
+	if b == nil || b.id != id {
+		b = &batchTrace{id: id, start: it.IngestedAt}
+		t.batch = b
+	}
+	b.n++
+	if it.IngestedAt.Before(b.start) {
+		b.start = it.IngestedAt
+	}
+	if e != nil {
+		for _, s := range e.stages {
+			b.merge(s)
+		}
+	}
+	b.merge(stageTiming{name: "sink", in: start, out: end})
+	if !last {
+		return
+	}
+	t.batch = nil
+	if rand.Float64() >= t.sample {
+		return
+	}
+	var stages, sink []stageTiming
+	for _, s := range b.stages {
+		if s.name == "sink" && s.index == 0 {
+			sink = append(sink, s)
+		} else {
+			stages = append(stages, s)
+		}
+	}
+	t.export("batch", b.start, stages, sink[0].in, sink[0].out,
+		attribute.Int64("pipeline.batch", int64(b.id)),
+		attribute.Int("pipeline.batch_size", b.n))
+}
+
+// merge - расширение интервала стадии s партии до интервала значения.
+func (b *batchTrace) merge(s stageTiming) {
+	for i := range b.stages {
+		cur := &b.stages[i]
+		if cur.index != s.index || cur.name != s.name {
+			continue
+		}
+		if s.in.Before(cur.in) {
+			cur.in = s.in
+		}
+		if s.out.After(cur.out) {
+			cur.out = s.out
+		}
+		return
+	}
+	b.stages = append(b.stages, s)
+}
+
+// export - создание трассы: корневой спан name от start до конца вывода,
+// дочерние спаны стадий и приемника (с sinkStart по sinkEnd).
+func (t *tracer) export(name string, start time.Time, stages []stageTiming, sinkStart, sinkEnd time.Time, attrs ...attribute.KeyValue) {
+	ctx, root := t.tr.Start(context.Background(), name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	for _, s := range stages {
+		out := s.out
+		if out.IsZero() {
+			out = sinkStart
+		}
+		_, span := t.tr.Start(ctx, s.name, trace.WithTimestamp(s.in), trace.WithAttributes(s.attrs...))
+		span.End(trace.WithTimestamp(out))
+	}
+	_, span := t.tr.Start(ctx, "sink", trace.WithTimestamp(sinkStart))
+	span.End(trace.WithTimestamp(sinkEnd))
+	root.End(trace.WithTimestamp(sinkEnd))
+}
+
+// Close - отправка оставшихся трасс и остановка экспорта.
+func (t *tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), traceShutdownTimeout)
+	defer cancel()
+	return t.tp.Shutdown(ctx)
+}
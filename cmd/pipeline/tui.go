@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/MosinEvgeny/Pipline/pipeline"
+)
+
+// Параметры панели -tui.
+const (
+	tuiRefresh  = 500 * time.Millisecond // Интервал перерисовки
+	tuiValues   = 10                     // Последние выведенные значения на панели
+	tuiRejects  = 5                      // Причины отбрасывания на панели
+	tuiLogLines = 5                      // Последние сообщения журнала на панели
+	tuiLogKeep  = 100                    // Сообщения журнала, выводимые в stderr после выхода
+	tuiGauge    = 30                     // Ширина шкалы заполнения буфера
+)
+
+// Управляющие последовательности панели.
+const (
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l" // Альтернативный экран, курсор скрыт
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l" // Возврат к основному экрану
+	ansiHome       = "\x1b[H"               // Курсор в начало экрана
+	ansiClearLine  = "\x1b[K"               // Очистка до конца строки
+	ansiClearBelow = "\x1b[J"               // Очистка до конца экрана
+)
+
+// dashboard - панель -tui: пропускная способность стадий, заполнение
+// буфера, отброшенные и последние выведенные значения, журнал и клавиши
+// управления. Панель заменяет вывод в консоль (приемник значений) и журнал
+// в stderr на время работы.
+type dashboard struct {
+	cfg    config
+	p      runningPipeline
+	rc     *runControl
+	sd     *shutdown
+	start  time.Time
+	tty    *os.File    // Ввод клавиш (nil - недоступен)
+	state  *term.State // Режим терминала до панели (nil - не менялся)
+	logger *slog.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	values []pipeline.Num // Последние выведенные значения, новые - в конце
+	logs   []string       // Последние сообщения журнала
+	status string         // Ответ на последнюю клавишу
+
+	prev   map[int]stageCounts // Счетчики стадий при прошлой перерисовке
+	prevAt time.Time
+}
+
+// stageCounts - принятые и переданные стадией значения.
+type stageCounts struct {
+	received, passed uint64
+}
+
+// startDashboard - запуск панели для пайплайна p. Требуется терминал
+// в stdout; клавиши читаются из управляющего терминала, поэтому консоль
+// с клавиатуры не может быть источником данных.
+func startDashboard(cfg config, p runningPipeline, rc *runControl, sd *shutdown) (*dashboard, error) {
+	if !isTerminal(os.Stdout) || !enableANSI(os.Stdout) {
+		return nil, fmt.Errorf("для -tui stdout должен быть терминалом с поддержкой ANSI")
+	}
+	if cfg.readsStdin() && isTerminal(os.Stdin) {
+		return nil, fmt.Errorf("с -tui данные нельзя вводить с клавиатуры: задайте источник или передайте данные через канал")
+	}
+	d := &dashboard{cfg: cfg, p: p, rc: rc, sd: sd, start: time.Now(), logger: slog.Default(), done: make(chan struct{})}
+	if tty, err := openTTY(); err == nil {
+		if d.state, err = term.MakeRaw(int(tty.Fd())); err != nil {
+			tty.Close()
+		} else {
+			d.tty = tty
+		}
+	}
+	level := cfg.logLevel
+	if cfg.quiet && level == "info" {
+		level = "warn"
+	}
+	logger, err := newLogger(d, level, logFormatText) // Уровень проверен при разборе конфигурации
+	if err != nil {
+		d.restore()
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	fmt.Print(ansiAltScreen)
+
+	d.wg.Add(1)
+	go d.refresh()
+	if d.tty != nil {
+		go d.keys() // Чтение клавиш прерывается закрытием tty
+	}
+	return d, nil
+}
+
+// Write - запись сообщения журнала (обработчик slog пишет запись целиком).
+func (d *dashboard) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.logs = append(d.logs, strings.TrimRight(string(b), "\n"))
+	if len(d.logs) > tuiLogKeep {
+		d.logs = d.logs[len(d.logs)-tuiLogKeep:]
+	}
+	return len(b), nil
+}
+
+// dashboardSink - приемник обработанных значений панели, заменяющий вывод
+// в консоль.
+type dashboardSink struct {
+	d *dashboard
+}
+
+// Write - добавление n в последние значения.
+func (s dashboardSink) Write(n pipeline.Num) error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.values = append(s.d.values, n)
+	if len(s.d.values) > tuiValues {
+		s.d.values = s.d.values[len(s.d.values)-tuiValues:]
+	}
+	return nil
+}
+
+// Flush - ничего не делает: значения выводятся при перерисовке.
+func (dashboardSink) Flush() error { return nil }
+
+// keys - обработка клавиш: p - приостановка и возобновление источника,
+// f - отправка буфера, q или Ctrl+C - завершение (повторно - немедленное).
+func (d *dashboard) keys() {
+	buf := make([]byte, 16)
+	for {
+		n, err := d.tty.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range buf[:n] {
+			var reply string
+			var err error
+			switch key {
+			case 'p', 'P':
+				if d.rc.gate.paused() {
+					reply, err = d.rc.cmds.exec("resume")
+				} else {
+					reply, err = d.rc.cmds.exec("pause")
+				}
+			case 'f', 'F':
+				reply, err = d.rc.cmds.exec("flush")
+			case 'q', 'Q', 3: // 3 - Ctrl+C: в режиме raw сигнал не посылается
+				d.sd.interrupt()
+				reply = "завершение: обработка оставшихся значений"
+			default:
+				continue
+			}
+			if err != nil {
+				reply = err.Error()
+			}
+			d.mu.Lock()
+			d.status = reply
+			d.mu.Unlock()
+		}
+	}
+}
+
+// refresh - перерисовка панели до Close.
+func (d *dashboard) refresh() {
+	defer d.wg.Done()
+	t := time.NewTicker(tuiRefresh)
+	defer t.Stop()
+	for {
+		d.draw()
+		select {
+		case <-t.C:
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// draw - вывод панели по размеру терминала.
+func (d *dashboard) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	lines := d.lines()
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	for i, line := range lines {
+		b.WriteString(truncate(line, width))
+		b.WriteString(ansiClearLine)
+		if i < len(lines)-1 {
+			b.WriteString("\r\n") // В режиме raw перевод строки не возвращает каретку
+		}
+	}
+	b.WriteString(ansiClearBelow)
+	fmt.Print(b.String())
+}
+
+// lines - строки панели.
+func (d *dashboard) lines() []string {
+	now := time.Now()
+	r := d.rc.stats.report()
+	state := tr("работает")
+	if d.rc.gate.paused() {
+		state = tr("приостановлен")
+	}
+	lines := []string{
+		fmt.Sprintf(tr("Пайплайн: источник %s, %s, время работы %s"), sourceName(d.cfg), state, now.Sub(d.start).Round(time.Second)),
+		fmt.Sprintf(tr("Прочитано %d, некорректных %d, выведено %d, ошибок стадий %d, отправок буфера %d"), r.Read, r.Invalid, r.Emitted, r.Errors, r.Flushes),
+		"",
+		fmt.Sprintf("%-3s %-18s %12s %12s %10s %10s %9s", "#", tr("Стадия"), tr("Принято"), tr("Передано"), tr("Вход/с"), tr("Выход/с"), tr("Очередь")),
+	}
+
+	stages := d.p.metrics.stageList()
+	elapsed := now.Sub(d.prevAt).Seconds()
+	counts := make(map[int]stageCounts, len(stages))
+	var buffer *stageMetric
+	for _, sm := range stages {
+		c := stageCounts{received: sm.stage.Received.Load(), passed: sm.stage.Passed.Load()}
+		counts[sm.index] = c
+		var in, out float64
+		// После перезапуска цепочки счетчики начинаются заново
+		if prev, ok := d.prev[sm.index]; ok && elapsed > 0 && c.received >= prev.received && c.passed >= prev.passed {
+			in = float64(c.received-prev.received) / elapsed
+			out = float64(c.passed-prev.passed) / elapsed
+		}
+		queue := "-"
+		if sm.queue != nil {
+			queue = fmt.Sprintf("%d/%d", sm.queue.Depth(), sm.queue.Cap)
+		}
+		name := sm.name
+		if sm.failed.Load() {
+			name += " (!)"
+		}
+		lines = append(lines, fmt.Sprintf("%-3d %-18s %12d %12d %10.0f %10.0f %9s", sm.index, name, c.received, c.passed, in, out, queue))
+		if sm.buffer != nil {
+			buffer = sm
+		}
+	}
+	d.prev, d.prevAt = counts, now
+
+	lines = append(lines, "")
+	if buffer != nil {
+		size := d.rc.buffer.Settings().Size
+		if size <= 0 {
+			size = d.cfg.bufferSize
+		}
+		fill := int(buffer.buffer.Occupancy.Load())
+		lines = append(lines, fmt.Sprintf(tr("Буфер %s %d/%d, потеряно при переполнении %d"), gauge(fill, size), fill, size, buffer.buffer.Dropped.Load()))
+	}
+
+	var rejected uint64
+	for _, rs := range r.Rejected {
+		rejected += rs.Count
+	}
+	lines = append(lines, fmt.Sprintf(tr("Отброшено стадиями: %d"), rejected))
+	for i, rs := range r.Rejected {
+		if i == tuiRejects {
+			lines = append(lines, "  ...")
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s - %d", rs.Stage, rs.Reason, rs.Count))
+	}
+
+	d.mu.Lock()
+	values := make([]string, len(d.values))
+	for i, n := range d.values {
+		values[len(values)-1-i] = n.String() // Новые - первыми
+	}
+	logs := d.logs[max(0, len(d.logs)-tuiLogLines):]
+	status := d.status
+	d.mu.Unlock()
+
+	lines = append(lines, "", tr("Последние значения: ")+strings.Join(values, " "), "", tr("Журнал:"))
+	for _, l := range logs {
+		lines = append(lines, "  "+l)
+	}
+	lines = append(lines, "")
+	if d.tty != nil {
+		lines = append(lines, tr("p - пауза/продолжение, f - отправка буфера, q - выход"))
+	} else {
+		lines = append(lines, tr("Клавиши недоступны: нет терминала; выход - Ctrl+C"))
+	}
+	if status != "" {
+		lines = append(lines, tr(status))
+	}
+	return lines
+}
+
+// gauge - шкала заполнения n из size.
+func gauge(n, size int) string {
+	filled := 0
+	if size > 0 {
+		filled = min(tuiGauge, n*tuiGauge/size)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", tuiGauge-filled) + "]"
+}
+
+// truncate - строка s не длиннее width символов.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:max(0, width)])
+}
+
+// restore - возврат терминала в исходный режим.
+func (d *dashboard) restore() {
+	if d.tty != nil {
+		term.Restore(int(d.tty.Fd()), d.state)
+		d.tty.Close()
+	}
+}
+
+// Close - остановка панели: последняя перерисовка, возврат терминала и
+// журнала и вывод накопленных сообщений журнала в stderr.
+func (d *dashboard) Close() error {
+	close(d.done)
+	d.wg.Wait()
+	fmt.Print(ansiMainScreen)
+	d.restore()
+	slog.SetDefault(d.logger)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, l := range d.logs {
+		fmt.Fprintln(os.Stderr, l)
+	}
+	return nil
+}
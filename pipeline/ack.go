@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Acks - подтверждение доставки партий стадии буферизации (см.
+// BufferOptions.Acks). Отправленная партия остается в полете, пока приемник
+// не подтвердит ее вызовом Ack; после Nack партия отправляется повторно
+// с прежним номером при следующей отправке буфера, перед его содержимым.
+// Так значения партии, вывод которой прервался ошибкой, не теряются (доставка
+// не менее одного раза), а приемник, отбрасывающий повторы по ключу
+// идемпотентности (например, номеру партии и позиции в ней), получает каждое
+// значение ровно один раз. Партии, не подтвержденные до перезапуска стадии,
+// отправляются повторно новым запуском; одновременно Acks используется
+// одной стадией.
+type Acks[T any] struct {
+	mu       sync.Mutex
+	inflight map[uint64][]T // Неподтвержденные партии по номерам
+	failed   []uint64       // Партии для повторной отправки в порядке Nack
+	last     uint64         // Номер последней отправленной партии
+
+	changed chan struct{} // Уведомление о подтверждении или ошибке
+	retried atomic.Uint64
+}
+
+// NewAcks - создание подтверждения доставки партий.
+func NewAcks[T any]() *Acks[T] {
+	return &Acks[T]{inflight: make(map[uint64][]T), changed: make(chan struct{}, 1)}
+}
+
+// Ack - подтверждение доставки партии batch. Неизвестные и уже
+// подтвержденные партии не учитываются.
+func (a *Acks[T]) Ack(batch uint64) {
+	a.mu.Lock()
+	delete(a.inflight, batch)
+	if i := slices.Index(a.failed, batch); i >= 0 {
+		a.failed = slices.Delete(a.failed, i, i+1)
+	}
+	a.mu.Unlock()
+	notify(a.changed)
+}
+
+// Nack - ошибка доставки партии batch: партия будет отправлена повторно.
+func (a *Acks[T]) Nack(batch uint64) {
+	a.mu.Lock()
+	if _, ok := a.inflight[batch]; ok && !slices.Contains(a.failed, batch) {
+		a.failed = append(a.failed, batch)
+	}
+	a.mu.Unlock()
+	notify(a.changed)
+}
+
+// InFlight - количество неподтвержденных партий. Допускает nil-получатель.
+func (a *Acks[T]) InFlight() int {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.inflight)
+}
+
+// Retried - количество повторных отправок партий.
+func (a *Acks[T]) Retried() uint64 {
+	return a.retried.Load()
+}
+
+// start - начало работы стадии: номер последней отправленной партии, от
+// которого продолжается нумерация. Партии предыдущего запуска отправляются
+// повторно.
+func (a *Acks[T]) start() uint64 {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range slices.Sorted(maps.Keys(a.inflight)) {
+		if !slices.Contains(a.failed, id) {
+			a.failed = append(a.failed, id)
+		}
+	}
+	return a.last
+}
+
+// sent - регистрация отправки партии batch со значениями data.
+func (a *Acks[T]) sent(batch uint64, data []T) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inflight[batch] = slices.Clone(data)
+	a.last = max(a.last, batch)
+}
+
+// retries - извлечение номеров партий для повторной отправки. Партии,
+// повторная отправка которых снова завершится ошибкой, отправляются
+// при следующем вызове.
+func (a *Acks[T]) retries() []uint64 {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ids := a.failed
+	a.failed = nil
+	return ids
+}
+
+// resend - значения партии batch для повторной отправки (ok = false - партия
+// уже подтверждена).
+func (a *Acks[T]) resend(batch uint64) (data []T, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	data, ok = a.inflight[batch]
+	if ok {
+		a.retried.Add(1)
+	}
+	return data, ok
+}
+
+// take - извлечение значений всех неподтвержденных партий в порядке номеров
+// (например, для сохранения в файле сброса).
+func (a *Acks[T]) take() []T {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var data []T
+	for _, id := range slices.Sorted(maps.Keys(a.inflight)) {
+		data = append(data, a.inflight[id]...)
+	}
+	clear(a.inflight)
+	a.failed = nil
+	return data
+}
+
+// changes - канал уведомлений о подтверждениях (nil без подтверждения).
+func (a *Acks[T]) changes() <-chan struct{} {
+	if a == nil {
+		return nil
+	}
+	return a.changed
+}
@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Aggregate - функция агрегации значений окна (окно не пустое).
+type Aggregate[T Number] func(values []T) T
+
+// Функции агрегации.
+const (
+	AggSum   = "sum"
+	AggAvg   = "avg" // Для целых типов - с округлением к нулю
+	AggMin   = "min"
+	AggMax   = "max"
+	AggCount = "count"
+)
+
+// ParseAggregate - функция агрегации по имени: sum, avg, min, max или count.
+func ParseAggregate[T Number](name string) (Aggregate[T], error) {
+	switch name {
+	case AggSum:
+		return sumOf[T], nil
+	case AggAvg:
+		return func(values []T) T { return sumOf(values) / T(len(values)) }, nil
+	case AggMin:
+		return slices.Min[[]T], nil
+	case AggMax:
+		return slices.Max[[]T], nil
+	case AggCount:
+		return func(values []T) T { return T(len(values)) }, nil
+	}
+	return nil, fmt.Errorf("неизвестная функция агрегации: %q (ожидается sum, avg, min, max или count)", name)
+}
+
+// NumAggregate - функция агрегации значений Num (окно не пустое).
+type NumAggregate func(values []Num) Num
+
+// ParseNumAggregate - функция агрегации Num по имени, как ParseAggregate.
+// Среднее целых округляется к нулю, среднее с числами с плавающей точкой -
+// число с плавающей точкой; переполнения нет.
+func ParseNumAggregate(name string) (NumAggregate, error) {
+	switch name {
+	case AggSum:
+		return sumNums, nil
+	case AggAvg:
+		return func(values []Num) Num { return sumNums(values).Quo(IntNum(int64(len(values)))) }, nil
+	case AggMin:
+		return func(values []Num) Num { return slices.MinFunc(values, Num.Cmp) }, nil
+	case AggMax:
+		return func(values []Num) Num { return slices.MaxFunc(values, Num.Cmp) }, nil
+	case AggCount:
+		return func(values []Num) Num { return IntNum(int64(len(values))) }, nil
+	}
+	return nil, fmt.Errorf("неизвестная функция агрегации: %q (ожидается sum, avg, min, max или count)", name)
+}
+
+// sumNums - сумма значений Num.
+func sumNums(values []Num) Num {
+	var sum Num
+	for _, v := range values {
+		sum = sum.Add(v)
+	}
+	return sum
+}
+
+// sumOf - сумма значений.
+func sumOf[T Number](values []T) T {
+	var sum T
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// NewCountWindow - стадия агрегации окна из size значений.
+// Скользящее окно (sliding) отправляет агрегат последних size значений после
+// каждого значения, начиная с size-го; неперекрывающееся - после каждых size
+// значений, неполное окно отправляется при закрытии входа.
+func NewCountWindow[T Number](size int, sliding bool, agg Aggregate[T]) Stage[T] {
+	return NewCountWindowBy(size, sliding, agg)
+}
+
+// NewCountWindowBy - NewCountWindow для произвольных значений: окно сводится
+// к одному значению функцией agg (например, ItemAggregate).
+func NewCountWindowBy[T any](size int, sliding bool, agg func(window []T) T) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		window := make([]T, 0, size)
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					if !sliding && len(window) > 0 {
+						send(ctx, out, agg(window))
+					}
+					return
+				}
+				if sliding && len(window) == size {
+					window = append(window[:0], window[1:]...)
+				}
+				window = append(window, n)
+				if len(window) < size {
+					continue
+				}
+				if !send(ctx, out, agg(window)) {
+					return
+				}
+				if !sliding {
+					window = window[:0]
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// timedValue - значение с моментом получения.
+type timedValue[T any] struct {
+	at time.Time
+	v  T
+}
+
+// NewTimeWindow - стадия агрегации значений за интервал d.
+// Скользящее окно (sliding) после каждого значения отправляет агрегат значений,
+// полученных за последние d; неперекрывающееся - агрегат каждого интервала d
+// (пустые интервалы пропускаются, последний отправляется при закрытии входа).
+func NewTimeWindow[T Number](d time.Duration, sliding bool, agg Aggregate[T], clock Clock) Stage[T] {
+	return NewTimeWindowBy(d, sliding, agg, clock)
+}
+
+// NewTimeWindowBy - NewTimeWindow для произвольных значений: окно сводится
+// к одному значению функцией agg (например, ItemAggregate).
+func NewTimeWindowBy[T any](d time.Duration, sliding bool, agg func(window []T) T, clock Clock) Stage[T] {
+	if sliding {
+		return slidingTimeWindow(d, agg, clock)
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		ticker := clock.NewTicker(d)
+		defer ticker.Stop()
+
+		var window []T
+		emit := func() bool {
+			if len(window) == 0 {
+				return true // Пустое окно
+			}
+			v := agg(window)
+			window = window[:0]
+			return send(ctx, out, v)
+		}
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					emit()
+					return
+				}
+				window = append(window, n)
+			case <-ticker.C():
+				if !emit() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// slidingTimeWindow - скользящее окно длительности d.
+func slidingTimeWindow[T any](d time.Duration, agg func(window []T) T, clock Clock) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		var (
+			window []timedValue[T]
+			values []T
+		)
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					return
+				}
+				now := clock.Now()
+				window = append(window, timedValue[T]{at: now, v: n})
+				// Отбрасывание значений, вышедших за окно
+				drop := 0
+				for drop < len(window) && now.Sub(window[drop].at) >= d {
+					drop++
+				}
+				window = append(window[:0], window[drop:]...)
+
+				values = values[:0]
+				for _, tv := range window {
+					values = append(values, tv.v)
+				}
+				if !send(ctx, out, agg(values)) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
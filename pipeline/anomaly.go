@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Параметры поиска аномалий по умолчанию.
+const (
+	DefaultAnomalyWindow = 100 // Размер скользящего окна
+	DefaultAnomalySigmas = 3.0 // Порог в стандартных отклонениях
+)
+
+// Anomaly - отклонение значения от скользящего окна предыдущих значений.
+type Anomaly struct {
+	Mean   float64 `json:"mean"`   // Среднее окна
+	StdDev float64 `json:"stddev"` // Стандартное отклонение окна
+	Score  float64 `json:"score"`  // Отклонение значения от среднего в стандартных отклонениях (со знаком)
+}
+
+// AnomalyOptions - параметры стадии поиска аномалий.
+type AnomalyOptions[T any] struct {
+	Window int                    // Размер скользящего окна (0 - DefaultAnomalyWindow)
+	Sigmas float64                // Порог отклонения в стандартных отклонениях (0 - DefaultAnomalySigmas)
+	Mark   func(v T, a Anomaly) T // Пометка аномального значения (nil - значение не меняется)
+	Alert  Sink[T]                // Приемник аномальных значений (nil - они остаются в потоке)
+}
+
+// Validate - проверка параметров поиска аномалий.
+func (o AnomalyOptions[T]) Validate() error {
+	switch {
+	case o.Window < 0:
+		return fmt.Errorf("размер окна не может быть отрицательным: %d", o.Window)
+	case o.Window == 1:
+		return fmt.Errorf("размер окна должен быть не меньше 2")
+	case o.Sigmas < 0 || math.IsNaN(o.Sigmas) || math.IsInf(o.Sigmas, 0):
+		return fmt.Errorf("порог должен быть положительным числом: %g", o.Sigmas)
+	}
+	return nil
+}
+
+// DetectAnomalies - стадия поиска аномалий: значение, отклоняющееся от
+// среднего последних Window значений больше чем на Sigmas стандартных
+// отклонений, помечается Mark и при заданном Alert передается в него
+// вместо выхода стадии.
+func DetectAnomalies[T Integer | Float](opts AnomalyOptions[T]) Stage[T] {
+	return DetectAnomaliesBy(func(v T) float64 { return float64(v) }, opts)
+}
+
+// DetectAnomaliesBy - DetectAnomalies по числовому значению value (например,
+// для Item). Поиск начинается после заполнения окна; окно без разброса
+// (все значения равны) и значения NaN и бесконечности не помечаются, последние
+// также не входят в окно. Аномальные значения входят в окно, поэтому после
+// устойчивого сдвига уровня значения перестают помечаться. Ошибка Alert
+// передается в ReportError, и дальнейшие аномальные значения отбрасываются.
+func DetectAnomaliesBy[T any](value func(T) float64, opts AnomalyOptions[T]) Stage[T] {
+	if opts.Window <= 0 {
+		opts.Window = DefaultAnomalyWindow
+	}
+	if opts.Sigmas <= 0 {
+		opts.Sigmas = DefaultAnomalySigmas
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		alert, failed := opts.Alert, false
+		if alert != nil {
+			defer func() {
+				if err := alert.Flush(); err != nil && !failed {
+					ReportError(ctx, fmt.Errorf("приемник аномалий: %w", err))
+				}
+			}()
+		}
+		w := rollingWindow{values: make([]float64, opts.Window)}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				x := value(v)
+				if math.IsNaN(x) || math.IsInf(x, 0) {
+					if !send(ctx, out, v) {
+						return
+					}
+					continue
+				}
+				a, anomalous := w.check(x, opts.Sigmas)
+				w.add(x)
+				if !anomalous {
+					if !send(ctx, out, v) {
+						return
+					}
+					continue
+				}
+				if opts.Mark != nil {
+					v = opts.Mark(v, a)
+				}
+				if alert == nil {
+					if !send(ctx, out, v) {
+						return
+					}
+					continue
+				}
+				if failed {
+					continue
+				}
+				if err := alert.Write(v); err != nil && !errors.Is(err, ctx.Err()) {
+					failed = true
+					ReportError(ctx, fmt.Errorf("приемник аномалий: %w", err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// MarkAnomaly - пометка Item найденным отклонением (AnomalyOptions.Mark для Item).
+func MarkAnomaly[T any](it Item[T], a Anomaly) Item[T] {
+	it.Anomaly = &a
+	return it
+}
+
+// rollingWindow - скользящее окно последних значений.
+type rollingWindow struct {
+	values []float64
+	next   int // Позиция следующего значения
+	full   bool
+}
+
+// add - добавление x с вытеснением самого старого значения.
+func (w *rollingWindow) add(x float64) {
+	w.values[w.next] = x
+	w.next++
+	if w.next == len(w.values) {
+		w.next, w.full = 0, true
+	}
+}
+
+// check - отклонение x от заполненного окна и превышает ли оно sigmas.
+// Среднее и отклонение вычисляются заново: окно невелико, а накопление
+// сумм теряет точность на больших значениях.
+func (w *rollingWindow) check(x, sigmas float64) (Anomaly, bool) {
+	if !w.full {
+		return Anomaly{}, false
+	}
+	var sum float64
+	for _, v := range w.values {
+		sum += v
+	}
+	mean := sum / float64(len(w.values))
+	var sq float64
+	for _, v := range w.values {
+		sq += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(sq / float64(len(w.values)))
+	if std == 0 {
+		return Anomaly{}, false
+	}
+	a := Anomaly{Mean: mean, StdDev: std, Score: (x - mean) / std}
+	return a, math.Abs(a.Score) > sigmas
+}
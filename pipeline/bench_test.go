@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// benchChain - прогон b.N значений (чередование положительных и
+// отрицательных) через стадии stages с отчетом о пропускной способности.
+func benchChain(b *testing.B, stages ...Stage[int]) {
+	b.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan int)
+	out := ChainCap(ctx, in, 64, stages...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	go func() {
+		defer close(in)
+		for i := range b.N {
+			v := i
+			if i%2 == 1 {
+				v = -i
+			}
+			in <- v
+		}
+	}()
+	for range out {
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "values/s")
+}
+
+// benchBuffer - буфер, отправляющий партию при заполнении.
+func benchBuffer() Stage[int] {
+	return NewBufferWith(BufferOptions[int]{Size: 1024, FlushInterval: time.Hour, Overflow: OverflowBlock})
+}
+
+func BenchmarkChainPassthrough(b *testing.B) {
+	benchChain(b)
+}
+
+func BenchmarkFilterNegative(b *testing.B) {
+	benchChain(b, FilterNegative[int])
+}
+
+func BenchmarkFilterNotDivisibleBy3(b *testing.B) {
+	benchChain(b, FilterNotDivisibleBy3[int])
+}
+
+func BenchmarkMapStage(b *testing.B) {
+	benchChain(b, MapStage(func(v int) int { return v * 2 }))
+}
+
+func BenchmarkParallel(b *testing.B) {
+	benchChain(b, Parallel(MapStage(func(v int) int { return v * 2 }), 4))
+}
+
+func BenchmarkDedupConsecutive(b *testing.B) {
+	benchChain(b, DedupConsecutive[int]())
+}
+
+func BenchmarkSampleEvery(b *testing.B) {
+	benchChain(b, SampleEvery[int](10))
+}
+
+func BenchmarkCountWindow(b *testing.B) {
+	sum, err := ParseAggregate[int]("sum")
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchChain(b, NewCountWindow(100, false, sum))
+}
+
+func BenchmarkStableGate(b *testing.B) {
+	benchChain(b, NewStableGate[int](time.Millisecond, RealClock{}))
+}
+
+func BenchmarkWindowMode(b *testing.B) {
+	benchChain(b, NewWindowMode[int](time.Millisecond, RealClock{}))
+}
+
+func BenchmarkBuffer(b *testing.B) {
+	benchChain(b, benchBuffer())
+}
+
+// BenchmarkPipeline - полный пайплайн по умолчанию: фильтры, преобразование
+// и буфер.
+func BenchmarkPipeline(b *testing.B) {
+	benchChain(b,
+		FilterNegative[int],
+		FilterNotDivisibleBy3[int],
+		MapStage(func(v int) int { return v * 2 }),
+		benchBuffer(),
+	)
+}
@@ -0,0 +1,217 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errorsBuffer - емкость канала ошибок стадий построителя.
+const errorsBuffer = 16
+
+// Ошибки сборки пайплайна.
+var (
+	ErrNoSource = errors.New("не задан источник данных")
+	ErrNoSink   = errors.New("не задан приемник данных")
+)
+
+// Builder - построитель пайплайна от источника до приемника. Каналы и горутины
+// создаются в Run, ошибки источника, стадий и приемника объединяются.
+//
+//	err := pipeline.NewBuilder[int]().
+//		Source(pipeline.NewReaderSource(os.Stdin, nil)).
+//		Filter(func(n int) bool { return n >= 0 }).
+//		Buffer(5, 5*time.Second).
+//		Sink(pipeline.NewWriterSink[int](os.Stdout, "%d\n")).
+//		Run(ctx)
+type Builder[T any] struct {
+	src      Source[T]
+	sink     Sink[T]
+	stages   []Stage[T]
+	names    []string // Имена стадий для промежуточных обработчиков
+	mws      []Middleware[T]
+	chanCap  int
+	failFast bool
+	err      error // Первая ошибка сборки
+}
+
+// NewBuilder - создание построителя пайплайна значений типа T.
+func NewBuilder[T any]() *Builder[T] {
+	return &Builder[T]{}
+}
+
+// Source - задание источника данных.
+func (b *Builder[T]) Source(src Source[T]) *Builder[T] {
+	b.src = src
+	return b
+}
+
+// Stage - добавление произвольной стадии (с именем stage).
+func (b *Builder[T]) Stage(stage Stage[T]) *Builder[T] {
+	return b.NamedStage("stage", stage)
+}
+
+// NamedStage - добавление произвольной стадии с именем name, под которым
+// ее видят промежуточные обработчики.
+func (b *Builder[T]) NamedStage(name string, stage Stage[T]) *Builder[T] {
+	b.stages = append(b.stages, stage)
+	b.names = append(b.names, name)
+	return b
+}
+
+// Filter - добавление стадии, пропускающей значения, для которых pred истинно.
+func (b *Builder[T]) Filter(pred Predicate[T]) *Builder[T] {
+	return b.NamedStage("filter", FilterStage(pred))
+}
+
+// Map - добавление стадии преобразования значений функцией fn.
+func (b *Builder[T]) Map(fn Mapper[T]) *Builder[T] {
+	return b.NamedStage("map", MapStage(fn))
+}
+
+// Buffer - добавление стадии буферизации (см. NewBuffer).
+func (b *Builder[T]) Buffer(size int, flushInterval time.Duration) *Builder[T] {
+	if size <= 0 || flushInterval <= 0 {
+		b.fail(fmt.Errorf("буфер: размер и интервал должны быть положительными: %d, %s", size, flushInterval))
+		return b
+	}
+	return b.NamedStage("buffer", NewBuffer[T](size, flushInterval))
+}
+
+// Route - добавление маршрутизатора значений по ветвям (см. Route).
+func (b *Builder[T]) Route(branches ...Branch[T]) *Builder[T] {
+	return b.NamedStage("route", Route(branches...))
+}
+
+// Use - добавление промежуточных обработчиков, которыми при запуске
+// оборачивается каждая стадия, в том числе добавленная после Use.
+// Первый обработчик - внешний (см. Apply).
+//
+//	b.Use(pipeline.WithRecover[int](pipeline.SuperviseOptions{}), pipeline.WithLogging[int](slog.Default()))
+func (b *Builder[T]) Use(mws ...Middleware[T]) *Builder[T] {
+	b.mws = append(b.mws, mws...)
+	return b
+}
+
+// ChanCap - задание емкости каналов между стадиями (0 - небуферизованные).
+func (b *Builder[T]) ChanCap(n int) *Builder[T] {
+	if n < 0 {
+		b.fail(fmt.Errorf("емкость каналов не может быть отрицательной: %d", n))
+		return b
+	}
+	b.chanCap = n
+	return b
+}
+
+// FailFast - остановка пайплайна при первой ошибке стадии.
+func (b *Builder[T]) FailFast() *Builder[T] {
+	b.failFast = true
+	return b
+}
+
+// Sink - задание приемника данных.
+func (b *Builder[T]) Sink(sink Sink[T]) *Builder[T] {
+	b.sink = sink
+	return b
+}
+
+// fail - запоминание первой ошибки сборки.
+func (b *Builder[T]) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Run - запуск пайплайна до исчерпания источника, ошибки или отмены ctx.
+// Возвращает объединение ошибок сборки, источника, стадий (ReportError) и приемника.
+func (b *Builder[T]) Run(ctx context.Context) error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.src == nil {
+		return ErrNoSource
+	}
+	if b.sink == nil {
+		return ErrNoSink
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Сбор ошибок стадий; поздние ошибки после отмены ctx отбрасываются
+	var (
+		mu        sync.Mutex
+		stageErrs []error
+	)
+	errs := make(chan error, errorsBuffer)
+	collected := make(chan struct{})
+	done := ctx.Done() // ctx ниже заменяется контекстом с каналом ошибок
+	go func() {
+		defer close(collected)
+		for {
+			select {
+			case err := <-errs:
+				mu.Lock()
+				stageErrs = append(stageErrs, err)
+				mu.Unlock()
+				if b.failFast {
+					cancel()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	ctx = WithErrors(ctx, errs)
+
+	in := make(chan T, b.chanCap)
+	feedErr := make(chan error, 1)
+	go func() {
+		defer close(in)
+		feedErr <- Feed(ctx, b.src, in)
+	}()
+	stages := b.stages
+	if len(b.mws) > 0 {
+		stages = make([]Stage[T], len(b.stages))
+		for i, stage := range b.stages {
+			stages[i] = Apply(StageInfo{Index: i + 1, Name: b.names[i]}, stage, b.mws...)
+		}
+	}
+	sinkErr := Drain(ctx, ChainCap(ctx, in, b.chanCap, stages...), b.sink)
+
+	// Источник мог не успеть завершиться (например, блокирующее чтение stdin)
+	var srcErr error
+	select {
+	case srcErr = <-feedErr:
+	default:
+	}
+	cancel()
+	// Ошибки, переданные до отмены, но еще не прочитанные, не теряются
+	<-collected
+	for drained := false; !drained; {
+		select {
+		case err := <-errs:
+			stageErrs = append(stageErrs, err)
+		default:
+			drained = true
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var all []error
+	for _, err := range []error{srcErr, sinkErr} {
+		// Отмена по FailFast - не ошибка источника или приемника
+		if err == nil || (parent.Err() == nil && errors.Is(err, context.Canceled)) {
+			continue
+		}
+		if len(all) > 0 && errors.Is(err, parent.Err()) {
+			continue // Одна и та же отмена родительского контекста
+		}
+		all = append(all, err)
+	}
+	return errors.Join(append(all, stageErrs...)...)
+}
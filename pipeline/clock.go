@@ -0,0 +1,46 @@
+package pipeline
+
+import "time"
+
+// Clock - источник времени для стадий, зависящих от таймеров.
+// Позволяет подменить реальное время (например, в тестах).
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer - абстракция над time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker - абстракция над time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// RealClock - реализация Clock поверх пакета time.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+func (RealClock) NewTimer(d time.Duration) Timer { return &realTimer{time.NewTimer(d)} }
+
+func (RealClock) NewTicker(d time.Duration) Ticker { return &realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t *realTimer) C() <-chan time.Time        { return t.t.C }
+func (t *realTimer) Stop() bool                 { return t.t.Stop() }
+func (t *realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t *realTicker) C() <-chan time.Time   { return t.t.C }
+func (t *realTicker) Stop()                 { t.t.Stop() }
+func (t *realTicker) Reset(d time.Duration) { t.t.Reset(d) }
@@ -0,0 +1,26 @@
+package pipeline
+
+// Signed - знаковые целые типы.
+type Signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// Unsigned - беззнаковые целые типы.
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Integer - целые типы.
+type Integer interface {
+	Signed | Unsigned
+}
+
+// Float - типы с плавающей точкой.
+type Float interface {
+	~float32 | ~float64
+}
+
+// Number - числовые типы.
+type Number interface {
+	Integer | Float
+}
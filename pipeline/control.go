@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BufferSettings - параметры стадии буферизации, изменяемые во время работы.
+type BufferSettings struct {
+	Size          int           // Размер кольцевого буфера
+	FlushInterval time.Duration // Интервал отправки накопленных данных
+}
+
+// BufferControl - управление стадией буферизации во время работы
+// (см. BufferOptions.Control): немедленная отправка накопленных значений,
+// изменение размера буфера и интервала отправки, просмотр содержимого
+// (BufferSnapshot). Настройки сохраняются между запусками стадии (например,
+// при перезапуске ReloadableChain); одновременно BufferControl управляет
+// одной стадией.
+type BufferControl struct {
+	mu       sync.Mutex
+	settings BufferSettings // Нулевые поля - из BufferOptions
+
+	flush     chan struct{}      // Запрос немедленной отправки
+	changed   chan struct{}      // Уведомление об изменении настроек
+	snapshots chan snapshotQuery // Запросы содержимого буфера
+}
+
+// snapshotQuery - запрос содержимого буфера: не более n значений, ответ - []T стадии.
+type snapshotQuery struct {
+	n     int
+	reply chan any
+}
+
+// NewBufferControl - создание управления стадией буферизации.
+func NewBufferControl() *BufferControl {
+	return &BufferControl{
+		flush:     make(chan struct{}, 1),
+		changed:   make(chan struct{}, 1),
+		snapshots: make(chan snapshotQuery),
+	}
+}
+
+// Flush - запрос немедленной отправки накопленных значений. Не ожидает
+// отправки; повторные запросы до ее выполнения объединяются.
+func (c *BufferControl) Flush() {
+	notify(c.flush)
+}
+
+// Apply - одновременное изменение настроек s; нулевые поля не меняются.
+// При ошибке проверки не меняется ни одна настройка. Отсчет нового интервала
+// начинается с момента изменения. При уменьшении размера значения, не
+// помещающиеся в новый буфер, сначала отправляются; BatchSize больше нового
+// размера действует как отправка при заполнении.
+func (c *BufferControl) Apply(s BufferSettings) error {
+	if s.Size < 0 {
+		return fmt.Errorf("размер буфера должен быть положительным: %d", s.Size)
+	}
+	if s.FlushInterval < 0 {
+		return fmt.Errorf("интервал отправки должен быть положительным: %s", s.FlushInterval)
+	}
+	c.mu.Lock()
+	if s.Size > 0 {
+		c.settings.Size = s.Size
+	}
+	if s.FlushInterval > 0 {
+		c.settings.FlushInterval = s.FlushInterval
+	}
+	c.mu.Unlock()
+	notify(c.changed)
+	return nil
+}
+
+// SetFlushInterval - изменение интервала отправки.
+func (c *BufferControl) SetFlushInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("интервал отправки должен быть положительным: %s", d)
+	}
+	return c.Apply(BufferSettings{FlushInterval: d})
+}
+
+// SetSize - изменение размера буфера.
+func (c *BufferControl) SetSize(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("размер буфера должен быть положительным: %d", n)
+	}
+	return c.Apply(BufferSettings{Size: n})
+}
+
+// Settings - текущие настройки (нулевые поля - стадия еще не запущена
+// и настройка не задана).
+func (c *BufferControl) Settings() BufferSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
+// start - применение настроек при запуске стадии: заданные ранее настройки
+// заменяют параметры def, незаданные принимают значения def.
+func (c *BufferControl) start(def BufferSettings) BufferSettings {
+	if c == nil {
+		return def
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.settings.Size == 0 {
+		c.settings.Size = def.Size
+	}
+	if c.settings.FlushInterval == 0 {
+		c.settings.FlushInterval = def.FlushInterval
+	}
+	return c.settings
+}
+
+// flushRequests - канал запросов отправки (nil без управления).
+func (c *BufferControl) flushRequests() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.flush
+}
+
+// BufferSnapshot - копия не более n значений буфера стадии, управляемой c,
+// от старых к новым (например, для диагностики); значения не удаляются.
+// Стадия отвечает между обработкой значений, ожидание ответа прерывается
+// при отмене ctx. T - тип значений стадии; без управления (nil c) буфер пуст.
+func BufferSnapshot[T any](ctx context.Context, c *BufferControl, n int) ([]T, error) {
+	if c == nil {
+		return nil, nil
+	}
+	q := snapshotQuery{n: n, reply: make(chan any, 1)}
+	select {
+	case c.snapshots <- q:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case v := <-q.reply:
+		data, ok := v.([]T)
+		if !ok {
+			return nil, fmt.Errorf("тип значений буфера %T не совпадает с []%T", v, *new(T))
+		}
+		return data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// snapshotQueries - канал запросов содержимого буфера (nil без управления).
+func (c *BufferControl) snapshotQueries() <-chan snapshotQuery {
+	if c == nil {
+		return nil
+	}
+	return c.snapshots
+}
+
+// changes - канал уведомлений об изменении настроек (nil без управления).
+func (c *BufferControl) changes() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.changed
+}
+
+// notify - неблокирующая отправка уведомления в канал емкостью 1.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
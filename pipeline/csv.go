@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVSource - источник чисел (см. ParseNum) из столбца CSV.
+type CSVSource struct {
+	r          *csv.Reader
+	column     int
+	skipHeader bool
+	onInvalid  func(lineNo int, line string, err error)
+}
+
+// NewCSVSource - создание источника чисел из столбца column (с 1) CSV-данных r.
+// При skipHeader первая запись пропускается. Записи без числа в столбце
+// пропускаются и передаются в onInvalid (может быть nil).
+func NewCSVSource(r io.Reader, column int, skipHeader bool, onInvalid func(lineNo int, line string, err error)) *CSVSource {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // Количество столбцов проверяется по column
+	cr.ReuseRecord = true
+	return &CSVSource{r: cr, column: column, skipHeader: skipHeader, onInvalid: onInvalid}
+}
+
+// Next - очередное число источника.
+func (s *CSVSource) Next() (Num, error) {
+	for {
+		record, err := s.r.Read()
+		if errors.Is(err, io.EOF) {
+			return Num{}, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			s.invalid(parseErr.StartLine, "", parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return Num{}, err
+		}
+		if s.skipHeader {
+			s.skipHeader = false
+			continue
+		}
+		lineNo, _ := s.r.FieldPos(0)
+		if s.column > len(record) {
+			s.invalid(lineNo, strings.Join(record, ","), fmt.Errorf("нет столбца %d (столбцов: %d)", s.column, len(record)))
+			continue
+		}
+		n, err := ParseNumLine(record[s.column-1])
+		if err != nil {
+			s.invalid(lineNo, strings.Join(record, ","), fmt.Errorf("столбец %d: ожидается число: %q", s.column, record[s.column-1]))
+			continue
+		}
+		return n, nil
+	}
+}
+
+// invalid - передача некорректной записи в onInvalid.
+func (s *CSVSource) invalid(lineNo int, line string, err error) {
+	if s.onInvalid != nil {
+		s.onInvalid(lineNo, line, err)
+	}
+}
@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSlowItem - обработка значения не завершилась за отведенное время
+// (см. WithDeadline).
+var ErrSlowItem = errors.New("превышено время обработки значения")
+
+// maxLateCalls - наибольшее количество незавершенных (в том числе
+// просроченных) вызовов функции WithDeadline.
+const maxLateCalls = 16
+
+// deadlineResult - результат вызова функции WithDeadline.
+type deadlineResult[T any] struct {
+	v     T
+	pass  bool
+	err   error
+	panic *PanicError
+}
+
+// WithDeadline - fn с ограничением времени обработки одного значения d
+// (d <= 0 - без ограничения). Значение, не обработанное за d, отбрасывается
+// с ошибкой *StageError стадии stage, обертывающей ErrSlowItem, а результат
+// fn, полученный позже, теряется. Вызов fn не прерывается и завершается
+// в отдельной горутине; незавершенных вызовов не более maxLateCalls - пока
+// они не завершатся, следующие значения сразу отбрасываются с той же
+// ошибкой, и стадия не блокируется (в том числе при отмене контекста).
+// Паника fn повторяется в вызывающей горутине (см. Supervise), паника
+// просроченного вызова теряется.
+func WithDeadline[T any](stage string, fn ItemFunc[T], d time.Duration, clock Clock) ItemFunc[T] {
+	if d <= 0 {
+		return fn
+	}
+	calls := make(chan struct{}, maxLateCalls)
+	return func(v T) (T, bool, error) {
+		var zero T
+		select {
+		case calls <- struct{}{}: // Освобождается по завершении вызова
+		default:
+			return zero, false, NewStageError(stage, v, fmt.Errorf("%w: %d незавершенных вызовов", ErrSlowItem, maxLateCalls))
+		}
+		result := make(chan deadlineResult[T], 1)
+		go func() {
+			defer func() { <-calls }()
+			defer func() {
+				if r := recover(); r != nil {
+					result <- deadlineResult[T]{panic: asPanicError(r)}
+				}
+			}()
+			out, pass, err := fn(v)
+			result <- deadlineResult[T]{v: out, pass: pass, err: err}
+		}()
+		timer := clock.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case r := <-result:
+			if r.panic != nil {
+				panic(r.panic)
+			}
+			return r.v, r.pass, r.err
+		case <-timer.C():
+			return zero, false, NewStageError(stage, v, fmt.Errorf("%w: %s", ErrSlowItem, d))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithDeadlinePassesFastCall(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	fn := WithDeadline("double", func(v int) (int, bool, error) { return v * 2, true, nil }, time.Second, clock)
+	v, pass, err := fn(21)
+	if err != nil || !pass || v != 42 {
+		t.Fatalf("fn(21) = %d, %v, %v", v, pass, err)
+	}
+}
+
+func TestWithDeadlineSlowCall(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	release := make(chan struct{})
+	defer close(release)
+	fn := WithDeadline("slow", func(v int) (int, bool, error) {
+		<-release
+		return v, true, nil
+	}, time.Second, clock)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := fn(1)
+		errc <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	err := <-errc
+	var se *StageError
+	if !errors.Is(err, ErrSlowItem) || !errors.As(err, &se) || se.Stage != "slow" {
+		t.Fatalf("ошибка %v, ожидалась StageError стадии slow с ErrSlowItem", err)
+	}
+}
+
+func TestWithDeadlineLateCallsDoNotBlock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	release := make(chan struct{})
+	fn := WithDeadline("stuck", func(v int) (int, bool, error) {
+		<-release
+		return v, true, nil
+	}, time.Second, clock)
+
+	// Зависшие вызовы занимают все места
+	for i := range maxLateCalls {
+		errc := make(chan error, 1)
+		go func() {
+			_, _, err := fn(i)
+			errc <- err
+		}()
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		if err := <-errc; !errors.Is(err, ErrSlowItem) {
+			t.Fatalf("вызов %d: ошибка %v, ожидалась ErrSlowItem", i, err)
+		}
+	}
+
+	// Следующее значение отбрасывается сразу, без ожидания мест
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := fn(100)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrSlowItem) {
+			t.Fatalf("ошибка %v, ожидалась ErrSlowItem", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("вызов заблокирован зависшими вызовами")
+	}
+
+	// После завершения зависших вызовов значения снова обрабатываются
+	close(release)
+	deadline := time.Now().Add(testTimeout)
+	for {
+		v, pass, err := fn(7)
+		if err == nil {
+			if !pass || v != 7 {
+				t.Fatalf("fn(7) = %d, %v", v, pass)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("места не освободились: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// DefaultDedupSize - количество запоминаемых значений Dedup по умолчанию.
+const DefaultDedupSize = 10000
+
+// identity - ключ значения для стадий ...By, совпадающий с самим значением.
+func identity[T any](v T) T { return v }
+
+// DedupConsecutive - стадия, отбрасывающая значение, равное предыдущему.
+// Отброшенные значения передаются в Reject.
+func DedupConsecutive[T comparable]() Stage[T] {
+	return DedupConsecutiveBy(identity[T])
+}
+
+// DedupConsecutiveBy - DedupConsecutive со сравнением значений по ключу key
+// (например, ItemValue). В Reject передается ключ.
+func DedupConsecutiveBy[T any, K comparable](key func(T) K) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		audit := rejecting(ctx)
+		var (
+			prev K
+			seen bool
+		)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				k := key(v)
+				if seen && k == prev {
+					if audit {
+						Reject(ctx, NewRejection("dedup", k, "повтор предыдущего значения"))
+					}
+					continue
+				}
+				prev, seen = k, true
+				if !send(ctx, out, v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// dedupEntry - ключ запомненного значения и время его отправки.
+type dedupEntry[K any] struct {
+	k    K
+	sent time.Time
+}
+
+// Dedup - стадия, отбрасывающая повторы среди последних size различных значений
+// (size <= 0 - DefaultDedupSize). Память ограничена: при переполнении забывается
+// значение, которое дольше всего не встречалось (LRU). При ttl > 0 повтором
+// считается только значение, отправленное менее ttl назад.
+// Отброшенные значения передаются в Reject.
+func Dedup[T comparable](size int, ttl time.Duration, clock Clock) Stage[T] {
+	return DedupBy(identity[T], size, ttl, clock)
+}
+
+// DedupBy - Dedup со сравнением значений по ключу key (например, ItemValue).
+// В Reject передается ключ.
+func DedupBy[T any, K comparable](key func(T) K, size int, ttl time.Duration, clock Clock) Stage[T] {
+	if size <= 0 {
+		size = DefaultDedupSize
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		audit := rejecting(ctx)
+		order := list.New() // От недавно встреченных к давним
+		index := make(map[K]*list.Element, size)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				now := clock.Now()
+				k := key(v)
+				if e, ok := index[k]; ok {
+					order.MoveToFront(e)
+					entry := e.Value.(*dedupEntry[K])
+					if ttl <= 0 || now.Sub(entry.sent) < ttl {
+						if audit {
+							Reject(ctx, NewRejection("dedup", k, "повтор значения"))
+						}
+						continue
+					}
+					entry.sent = now
+				} else {
+					index[k] = order.PushFront(&dedupEntry[K]{k: k, sent: now})
+					if order.Len() > size {
+						oldest := order.Back()
+						order.Remove(oldest)
+						delete(index, oldest.Value.(*dedupEntry[K]).k)
+					}
+				}
+				if !send(ctx, out, v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// StageError - ошибка обработки значения стадией.
+type StageError struct {
+	Stage string // Имя стадии
+	Value any    // Значение, вызвавшее ошибку
+	Err   error
+}
+
+// NewStageError - ошибка err стадии stage при обработке значения v.
+func NewStageError(stage string, v any, err error) *StageError {
+	return &StageError{Stage: stage, Value: v, Err: err}
+}
+
+// Error - текст ошибки с именем стадии и значением.
+func (e *StageError) Error() string {
+	return fmt.Sprintf("стадия %s, значение %v: %v", e.Stage, e.Value, e.Err)
+}
+
+// Unwrap - исходная ошибка.
+func (e *StageError) Unwrap() error { return e.Err }
+
+// errorsKey - ключ канала ошибок в контексте.
+type errorsKey struct{}
+
+// WithErrors - контекст, в котором ошибки стадий (ReportError) передаются в errs.
+// Канал должен читаться до завершения стадий, иначе они блокируются до отмены ctx.
+func WithErrors(ctx context.Context, errs chan<- error) context.Context {
+	return context.WithValue(ctx, errorsKey{}, errs)
+}
+
+// ReportError - передача ошибки стадии в канал ошибок контекста.
+// Без канала (см. WithErrors) ошибка отбрасывается.
+func ReportError(ctx context.Context, err error) {
+	errs, ok := ctx.Value(errorsKey{}).(chan<- error)
+	if !ok {
+		return
+	}
+	send(ctx, errs, err)
+}
+
+// Rejection - значение, отброшенное стадией (например, не прошедшее фильтр).
+// Возвращается из ItemFunc вместо ошибки, чтобы сообщить причину отбрасывания.
+type Rejection struct {
+	Stage  string // Имя стадии
+	Value  any    // Отброшенное значение
+	Reason string // Причина
+}
+
+// NewRejection - значение v, отброшенное стадией stage по причине reason.
+func NewRejection(stage string, v any, reason string) *Rejection {
+	return &Rejection{Stage: stage, Value: v, Reason: reason}
+}
+
+// Error - описание отброшенного значения.
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("стадия %s отбросила значение %v: %s", r.Stage, r.Value, r.Reason)
+}
+
+// rejectsKey - ключ канала отброшенных значений в контексте.
+type rejectsKey struct{}
+
+// WithRejects - контекст, в котором отброшенные значения (Reject) передаются в rejects.
+// Канал должен читаться до завершения стадий, иначе они блокируются до отмены ctx.
+func WithRejects(ctx context.Context, rejects chan<- *Rejection) context.Context {
+	return context.WithValue(ctx, rejectsKey{}, rejects)
+}
+
+// Reject - передача отброшенного значения в канал контекста.
+// Без канала (см. WithRejects) сведения отбрасываются.
+func Reject(ctx context.Context, r *Rejection) {
+	rejects, ok := ctx.Value(rejectsKey{}).(chan<- *Rejection)
+	if !ok {
+		return
+	}
+	send(ctx, rejects, r)
+}
+
+// rejecting - в контексте задан канал отброшенных значений.
+func rejecting(ctx context.Context) bool {
+	_, ok := ctx.Value(rejectsKey{}).(chan<- *Rejection)
+	return ok
+}
+
+// reportItemError - передача ошибки ItemFunc: отброшенное значение
+// в канал Reject, остальные ошибки - в ReportError.
+func reportItemError(ctx context.Context, err error) {
+	var r *Rejection
+	if errors.As(err, &r) {
+		Reject(ctx, r)
+		return
+	}
+	ReportError(ctx, err)
+}
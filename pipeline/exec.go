@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// DefaultExecInFlight - количество значений, переданных внешнему процессу
+// и ожидающих ответа, по умолчанию.
+const DefaultExecInFlight = 64
+
+// maxExecLine - наибольшая длина строки ответа внешнего процесса.
+const maxExecLine = 1 << 20
+
+// ExecOptions - параметры стадии внешнего процесса.
+type ExecOptions struct {
+	Name     string    // Имя стадии в ошибках и отброшенных значениях
+	Command  string    // Исполняемый файл (ищется в PATH)
+	Args     []string  // Аргументы
+	Dir      string    // Рабочий каталог (пусто - текущий)
+	Env      []string  // Окружение вида KEY=VALUE (nil - окружение программы)
+	InFlight int       // Наибольшее количество значений без ответа (0 - DefaultExecInFlight)
+	Stderr   io.Writer // Вывод ошибок процесса (nil - отбрасывается)
+}
+
+// execRequest - запрос к внешнему процессу: одно значение с метаданными.
+type execRequest[T any] struct {
+	Value  T      `json:"value"`
+	Seq    uint64 `json:"seq,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+// execResponse - ответ внешнего процесса на один запрос.
+type execResponse[T any] struct {
+	Value  *T     `json:"value"`
+	Drop   bool   `json:"drop"`
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+}
+
+// ErrExecProtocol - некорректный ответ внешнего процесса.
+var ErrExecProtocol = errors.New("некорректный ответ внешнего процесса")
+
+// ExecStage - стадия, обрабатывающая значения внешним процессом opts.Command.
+//
+// Процесс запускается при запуске стадии и получает на stdin по JSON-объекту
+// в строке: {"value": 5, "seq": 1, "source": "stdin"}. На каждую строку он
+// отвечает в stdout одной строкой в том же порядке:
+//
+//	{"value": 10}                        - передать значение дальше (с заменой)
+//	{"drop": true, "reason": "причина"}  - отбросить значение (Reject)
+//	{"error": "текст"}                   - ошибка обработки значения (ReportError)
+//
+// Метаданные значения сохраняются. Процесс может получать новые значения, не
+// ответив на предыдущие (не более opts.InFlight). После закрытия входа stdin
+// процесса закрывается, и стадия ожидает оставшиеся ответы и завершение
+// процесса. Досрочное завершение процесса передается в ReportError, и стадия
+// завершается до закрытия входа, как при панике: значения без ответа
+// теряются, а для перезапуска процесса или отбрасывания оставшихся значений
+// стадию оборачивают в Supervise (RestartAlways или RestartNever).
+// При отмене ctx процесс завершается.
+func ExecStage[T any](opts ExecOptions) Stage[Item[T]] {
+	if opts.InFlight <= 0 {
+		opts.InFlight = DefaultExecInFlight
+	}
+	return func(ctx context.Context, in <-chan Item[T], out chan<- Item[T]) {
+		defer close(out)
+		fail := func(v any, err error) {
+			ReportError(ctx, NewStageError(opts.Name, v, err))
+		}
+
+		cmd := exec.CommandContext(ctx, opts.Command, opts.Args...)
+		cmd.Dir, cmd.Env, cmd.Stderr = opts.Dir, opts.Env, opts.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			fail(nil, err)
+			discard(ctx, in)
+			return
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			fail(nil, err)
+			discard(ctx, in)
+			return
+		}
+		if err := cmd.Start(); err != nil {
+			fail(nil, fmt.Errorf("запуск %s: %w", opts.Command, err))
+			discard(ctx, in)
+			return
+		}
+
+		// Значения, переданные процессу, в порядке ожидания ответов
+		pending := make(chan Item[T], opts.InFlight)
+		stop := make(chan struct{})
+		go func() {
+			defer close(pending)
+			defer stdin.Close()
+			enc := json.NewEncoder(stdin)
+			for {
+				select {
+				case it, ok := <-in:
+					if !ok {
+						return
+					}
+					select {
+					case pending <- it:
+					case <-stop:
+						return
+					}
+					if err := enc.Encode(execRequest[T]{Value: it.Value, Seq: it.Seq, Source: it.Source}); err != nil {
+						return // Процесс завершился: ошибку сообщает чтение ответов
+					}
+				case <-stop:
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		failed := false
+		sc := bufio.NewScanner(stdout)
+		sc.Buffer(make([]byte, 0, 64*1024), maxExecLine)
+		for it := range pending {
+			if !sc.Scan() {
+				switch err := sc.Err(); {
+				case ctx.Err() != nil:
+				case err != nil:
+					fail(it.Value, fmt.Errorf("ответ процесса %s: %w", opts.Command, err))
+				default:
+					fail(it.Value, fmt.Errorf("процесс %s завершился досрочно: %w", opts.Command, io.ErrUnexpectedEOF))
+				}
+				failed = true
+				break
+			}
+			var resp execResponse[T]
+			switch err := json.Unmarshal(sc.Bytes(), &resp); {
+			case err != nil:
+				fail(it.Value, fmt.Errorf("%w: %v", ErrExecProtocol, err))
+			case resp.Error != "":
+				fail(it.Value, errors.New(resp.Error))
+			case resp.Drop:
+				Reject(ctx, NewRejection(opts.Name, it.Value, resp.Reason))
+			case resp.Value == nil:
+				fail(it.Value, fmt.Errorf("%w: нет поля value, drop или error", ErrExecProtocol))
+			default:
+				it.Value = *resp.Value
+				if !send(ctx, out, it) {
+					failed = true
+				}
+			}
+			if failed {
+				break
+			}
+		}
+		if failed {
+			// Значения без ответа теряются; процесс завершается, не дожидаясь его
+			close(stop)
+			cmd.Process.Kill()
+			for range pending {
+			}
+			cmd.Wait()
+			return
+		}
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			fail(nil, fmt.Errorf("процесс %s: %w", opts.Command, err))
+		}
+	}
+}
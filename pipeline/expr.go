@@ -0,0 +1,352 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDivisionByZero - деление на ноль при вычислении выражения.
+var ErrDivisionByZero = errors.New("деление на ноль")
+
+// ExprError - ошибка разбора выражения с позицией (с 1, в байтах).
+type ExprError struct {
+	Pos int
+	Msg string
+}
+
+// Error - текст ошибки с позицией.
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("позиция %d: %s", e.Pos, e.Msg)
+}
+
+// CompileExpr - компиляция выражения над переменной x в предикат фильтра.
+//
+// Поддерживаются числовые литералы (целые любой величины и с плавающей
+// точкой: 42, 1.5, 2e10), скобки, арифметика (+ - * / %, унарный минус),
+// сравнения (== != < <= > >=) и логические операции (&& || !), например
+// "x >= 0 && x % 3 == 0". Арифметика выполняется по правилам Num: целые -
+// без переполнения, с делением нацело, число с плавающей точкой в операции
+// дает число с плавающей точкой. Результат выражения должен быть логическим.
+// При делении на ноль значение не пропускается (см. CompileExprChecked).
+func CompileExpr(src string) (Predicate[Num], error) {
+	check, err := CompileExprChecked(src)
+	if err != nil {
+		return nil, err
+	}
+	return func(x Num) bool {
+		pass, err := check(x)
+		return err == nil && pass
+	}, nil
+}
+
+// CompileExprChecked - компиляция выражения, как CompileExpr, с возвратом
+// ошибки вычисления (ErrDivisionByZero).
+func CompileExprChecked(src string) (func(x Num) (bool, error), error) {
+	p := &exprParser{src: src}
+	p.next()
+	node, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf(p.tok.pos, "неожиданный символ %q", p.tok.text)
+	}
+	if !node.bool {
+		return nil, p.errorf(1, "выражение должно быть логическим (например, x > 0)")
+	}
+	return func(x Num) (bool, error) {
+		v, ok := node.eval(x)
+		if !ok {
+			return false, ErrDivisionByZero
+		}
+		return v.Sign() != 0, nil
+	}, nil
+}
+
+// exprNode - скомпилированный узел выражения. Логические значения - 0 или 1,
+// ok равно false при неопределенном результате (деление на ноль).
+type exprNode struct {
+	bool bool // Узел логического типа
+	eval func(x Num) (v Num, ok bool)
+}
+
+// Виды лексем выражения.
+const (
+	tokEOF = iota
+	tokNum
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+// exprToken - лексема выражения.
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+	num  Num
+}
+
+// exprParser - разбор выражения методом приоритетов операций.
+type exprParser struct {
+	src string
+	off int
+	tok exprToken
+	err error // Ошибка лексического анализа
+}
+
+// errorf - ошибка разбора в позиции pos.
+func (p *exprParser) errorf(pos int, format string, args ...any) error {
+	return &ExprError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next - чтение следующей лексемы.
+func (p *exprParser) next() {
+	for p.off < len(p.src) && (p.src[p.off] == ' ' || p.src[p.off] == '\t') {
+		p.off++
+	}
+	start := p.off
+	p.tok = exprToken{pos: start + 1}
+	if p.off >= len(p.src) {
+		p.tok.kind = tokEOF
+		return
+	}
+	c := p.src[p.off]
+	switch {
+	case isDigit(c):
+		p.digits()
+		if p.off+1 < len(p.src) && p.src[p.off] == '.' && isDigit(p.src[p.off+1]) {
+			p.off++
+			p.digits()
+		}
+		// Порядок: e10, e+10, e-10
+		if p.off < len(p.src) && (p.src[p.off] == 'e' || p.src[p.off] == 'E') {
+			exp := p.off + 1
+			if exp < len(p.src) && (p.src[exp] == '+' || p.src[exp] == '-') {
+				exp++
+			}
+			if exp < len(p.src) && isDigit(p.src[exp]) {
+				p.off = exp
+				p.digits()
+			}
+		}
+		p.tok.kind, p.tok.text = tokNum, p.src[start:p.off]
+		n, err := ParseNum(p.tok.text)
+		if err != nil && p.err == nil {
+			p.err = p.errorf(p.tok.pos, "некорректное число %q", p.tok.text)
+		}
+		p.tok.num = n
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_':
+		for p.off < len(p.src) {
+			c := p.src[p.off]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= '0' && c <= '9') {
+				break
+			}
+			p.off++
+		}
+		p.tok.kind, p.tok.text = tokIdent, p.src[start:p.off]
+	case c == '(':
+		p.off++
+		p.tok.kind, p.tok.text = tokLParen, "("
+	case c == ')':
+		p.off++
+		p.tok.kind, p.tok.text = tokRParen, ")"
+	default:
+		for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "!"} {
+			if len(p.src)-p.off >= len(op) && p.src[p.off:p.off+len(op)] == op {
+				p.off += len(op)
+				p.tok.kind, p.tok.text = tokOp, op
+				return
+			}
+		}
+		p.off++
+		p.tok.kind, p.tok.text = tokOp, string(c) // Ошибка сообщается при разборе
+	}
+}
+
+// digits - пропуск последовательности цифр.
+func (p *exprParser) digits() {
+	for p.off < len(p.src) && isDigit(p.src[p.off]) {
+		p.off++
+	}
+}
+
+// isDigit - c является десятичной цифрой.
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// binaryPrec - приоритеты бинарных операций (больше - связывает сильнее).
+var binaryPrec = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// parse - разбор выражения с операциями приоритета выше minPrec.
+func (p *exprParser) parse(minPrec int) (exprNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return exprNode{}, err
+	}
+	for {
+		if p.err != nil {
+			return exprNode{}, p.err
+		}
+		op := p.tok
+		prec, ok := binaryPrec[op.text]
+		if op.kind != tokOp || !ok || prec <= minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parse(prec)
+		if err != nil {
+			return exprNode{}, err
+		}
+		if left, err = p.binary(op, left, right); err != nil {
+			return exprNode{}, err
+		}
+	}
+}
+
+// unary - разбор унарной операции или операнда.
+func (p *exprParser) unary() (exprNode, error) {
+	if p.err != nil {
+		return exprNode{}, p.err
+	}
+	tok := p.tok
+	switch {
+	case tok.kind == tokOp && (tok.text == "-" || tok.text == "!"):
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return exprNode{}, err
+		}
+		if tok.text == "-" {
+			if operand.bool {
+				return exprNode{}, p.errorf(tok.pos, "унарный минус применим только к числам")
+			}
+			return exprNode{eval: func(x Num) (Num, bool) {
+				v, ok := operand.eval(x)
+				return v.Neg(), ok
+			}}, nil
+		}
+		if !operand.bool {
+			return exprNode{}, p.errorf(tok.pos, "операция ! применима только к логическим значениям")
+		}
+		return exprNode{bool: true, eval: func(x Num) (Num, bool) {
+			v, ok := operand.eval(x)
+			return boolNum(v.Sign() == 0), ok
+		}}, nil
+	case tok.kind == tokNum:
+		p.next()
+		return exprNode{eval: func(Num) (Num, bool) { return tok.num, true }}, nil
+	case tok.kind == tokIdent:
+		if tok.text != "x" {
+			return exprNode{}, p.errorf(tok.pos, "неизвестная переменная %q (доступна только x)", tok.text)
+		}
+		p.next()
+		return exprNode{eval: func(x Num) (Num, bool) { return x, true }}, nil
+	case tok.kind == tokLParen:
+		p.next()
+		node, err := p.parse(0)
+		if err != nil {
+			return exprNode{}, err
+		}
+		if p.tok.kind != tokRParen {
+			return exprNode{}, p.errorf(p.tok.pos, "ожидается )")
+		}
+		p.next()
+		return node, nil
+	case tok.kind == tokEOF:
+		return exprNode{}, p.errorf(tok.pos, "неожиданный конец выражения")
+	}
+	return exprNode{}, p.errorf(tok.pos, "неожиданный символ %q", tok.text)
+}
+
+// binary - построение узла бинарной операции op с проверкой типов операндов.
+func (p *exprParser) binary(op exprToken, left, right exprNode) (exprNode, error) {
+	l, r := left.eval, right.eval
+	switch op.text {
+	case "&&", "||":
+		if !left.bool || !right.bool {
+			return exprNode{}, p.errorf(op.pos, "операция %s применима только к логическим значениям", op.text)
+		}
+		and := op.text == "&&"
+		return exprNode{bool: true, eval: func(x Num) (Num, bool) {
+			a, ok := l(x)
+			if !ok {
+				return Num{}, false
+			}
+			if (a.Sign() != 0) != and {
+				return a, true // Сокращенное вычисление
+			}
+			return r(x)
+		}}, nil
+	case "==", "!=":
+		if left.bool != right.bool {
+			return exprNode{}, p.errorf(op.pos, "операция %s: операнды разных типов", op.text)
+		}
+		eq := op.text == "=="
+		return exprNode{bool: true, eval: func(x Num) (Num, bool) {
+			a, okA := l(x)
+			b, okB := r(x)
+			return boolNum((a.Cmp(b) == 0) == eq), okA && okB
+		}}, nil
+	}
+
+	if left.bool || right.bool {
+		return exprNode{}, p.errorf(op.pos, "операция %s применима только к числам", op.text)
+	}
+	var f func(a, b Num) (Num, bool)
+	result := exprNode{}
+	switch op.text {
+	case "<":
+		f, result.bool = func(a, b Num) (Num, bool) { return boolNum(a.Cmp(b) < 0), true }, true
+	case "<=":
+		f, result.bool = func(a, b Num) (Num, bool) { return boolNum(a.Cmp(b) <= 0), true }, true
+	case ">":
+		f, result.bool = func(a, b Num) (Num, bool) { return boolNum(a.Cmp(b) > 0), true }, true
+	case ">=":
+		f, result.bool = func(a, b Num) (Num, bool) { return boolNum(a.Cmp(b) >= 0), true }, true
+	case "+":
+		f = func(a, b Num) (Num, bool) { return a.Add(b), true }
+	case "-":
+		f = func(a, b Num) (Num, bool) { return a.Sub(b), true }
+	case "*":
+		f = func(a, b Num) (Num, bool) { return a.Mul(b), true }
+	case "/":
+		f = func(a, b Num) (Num, bool) {
+			if b.Sign() == 0 {
+				return Num{}, false
+			}
+			return a.Quo(b), true
+		}
+	case "%":
+		f = func(a, b Num) (Num, bool) {
+			if b.Sign() == 0 {
+				return Num{}, false
+			}
+			return a.Rem(b), true
+		}
+	}
+	result.eval = func(x Num) (Num, bool) {
+		a, okA := l(x)
+		b, okB := r(x)
+		if !okA || !okB {
+			return Num{}, false
+		}
+		return f(a, b)
+	}
+	return result, nil
+}
+
+// boolNum - логическое значение как 0 или 1.
+func boolNum(b bool) Num {
+	if b {
+		return IntNum(1)
+	}
+	return IntNum(0)
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Extractor - выделение записи числа из входной строки (например, строки
+// журнала) перед разбором.
+type Extractor func(line string) (string, error)
+
+// RegexpExtractor - Extractor по регулярному выражению expr: число - группа
+// с именем value ((?P<value>...)), без нее - первая группа, без групп - все
+// совпадение. Строка без совпадения - ошибка.
+func RegexpExtractor(expr string) (Extractor, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	group := 0
+	if i := re.SubexpIndex("value"); i > 0 {
+		group = i
+	} else if re.NumSubexp() > 0 {
+		group = 1
+	}
+	return func(line string) (string, error) {
+		m := re.FindStringSubmatchIndex(line)
+		switch {
+		case m == nil:
+			return "", fmt.Errorf("строка не соответствует выражению %s", re)
+		case m[2*group] < 0:
+			return "", fmt.Errorf("группа %d выражения %s не совпала", group, re)
+		}
+		return line[m[2*group]:m[2*group+1]], nil
+	}, nil
+}
+
+// FieldExtractor - Extractor поля field (с 1) строки с разделителем sep.
+// Разделитель " " - пробельные символы, подряд идущие считаются одним.
+func FieldExtractor(sep string, field int) Extractor {
+	return func(line string) (string, error) {
+		var fields []string
+		if sep == " " {
+			fields = strings.Fields(line)
+		} else {
+			fields = strings.Split(line, sep)
+		}
+		if field < 1 || field > len(fields) {
+			return "", fmt.Errorf("нет поля %d: в строке полей %d", field, len(fields))
+		}
+		return fields[field-1], nil
+	}
+}
+
+// ChainExtractors - последовательное выделение: каждый Extractor получает
+// результат предыдущего.
+func ChainExtractors(extract ...Extractor) Extractor {
+	return func(line string) (string, error) {
+		for _, e := range extract {
+			var err error
+			if line, err = e(line); err != nil {
+				return "", err
+			}
+		}
+		return line, nil
+	}
+}
+
+// ParseExtracted - разбор числа (см. ParseNumLine), выделенного из строки
+// функцией extract.
+func ParseExtracted(extract Extractor) func(line string) (Num, error) {
+	return func(line string) (Num, error) {
+		s, err := extract(line)
+		if err != nil {
+			return Num{}, err
+		}
+		return ParseNumLine(s)
+	}
+}
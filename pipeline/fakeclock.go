@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// FakeClock - управляемый источник времени для тестов: время стоит на месте,
+// пока его не сдвинут Advance или Set. Таймеры и тикеры срабатывают при сдвиге,
+// если наступил их срок; как и у пакета time, непрочитанное срабатывание
+// не накапливается (канал емкости 1), а после Stop и Reset не доставляется
+// (поведение time.Timer начиная с Go 1.23).
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond // Сигнал об изменении набора активных таймеров
+	now     time.Time
+	timers  []*fakeTimer
+}
+
+// NewFakeClock - создание источника времени, показывающего start.
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now - текущее время источника.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer - таймер, срабатывающий через d времени источника.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	return c.add(d, 0)
+}
+
+// NewTicker - тикер с периодом d времени источника.
+// Неположительный период приводит к панике, как у time.NewTicker.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("неположительный период тикера")
+	}
+	return fakeTicker{c.add(d, d)}
+}
+
+// add - регистрация таймера со сроком через d и периодом period (0 - однократный).
+func (c *FakeClock) add(d, period time.Duration) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), period: period}
+	c.start(t, d)
+	return t
+}
+
+// start - запуск таймера t со сроком через d с отбрасыванием непрочитанного
+// срабатывания (вызывается под блокировкой). Возвращает true, если таймер
+// был активен или его срабатывание не было прочитано.
+func (c *FakeClock) start(t *fakeTimer, d time.Duration) bool {
+	active := t.active
+	stale := t.drain()
+	t.when, t.active = c.now.Add(d), true
+	if !active {
+		c.timers = append(c.timers, t)
+	}
+	c.changed.Broadcast()
+	c.fire()
+	return active || stale
+}
+
+// stop - остановка таймера t (вызывается под блокировкой).
+func (c *FakeClock) stop(t *fakeTimer) bool {
+	if !t.active {
+		return false
+	}
+	t.active = false
+	c.timers = slices.DeleteFunc(c.timers, func(x *fakeTimer) bool { return x == t })
+	c.changed.Broadcast()
+	return true
+}
+
+// Advance - сдвиг времени на d со срабатыванием наступивших таймеров.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Set - установка времени t (не раньше текущего) со срабатыванием
+// наступивших таймеров.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+	c.fire()
+}
+
+// fire - срабатывание таймеров со сроком не позже текущего времени
+// в порядке сроков (вызывается под блокировкой).
+func (c *FakeClock) fire() {
+	due := slices.DeleteFunc(slices.Clone(c.timers), func(t *fakeTimer) bool { return t.when.After(c.now) })
+	slices.SortStableFunc(due, func(a, b *fakeTimer) int { return a.when.Compare(b.when) })
+	for _, t := range due {
+		select {
+		case t.c <- t.when:
+		default: // Предыдущее срабатывание не прочитано
+		}
+		if t.period == 0 {
+			c.stop(t)
+			continue
+		}
+		// Пропущенные периоды не накапливаются
+		for !t.when.After(c.now) {
+			t.when = t.when.Add(t.period)
+		}
+	}
+}
+
+// BlockUntil - ожидание, пока не будет активно не менее n таймеров и тикеров.
+// Позволяет сдвигать время только после того, как стадия создала свои таймеры.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// Timers - количество активных таймеров и тикеров.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// fakeTimer - таймер или тикер FakeClock.
+type fakeTimer struct {
+	clock  *FakeClock
+	c      chan time.Time
+	when   time.Time     // Срок срабатывания
+	period time.Duration // Период тикера (0 - таймер)
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stale := t.drain()
+	return t.clock.stop(t) || stale
+}
+
+// drain - отбрасывание непрочитанного срабатывания; true - оно было
+// (вызывается под блокировкой часов).
+func (t *fakeTimer) drain() bool {
+	select {
+	case <-t.c:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.period != 0 {
+		t.period = d
+	}
+	return t.clock.start(t, d)
+}
+
+// fakeTicker - тикер FakeClock: Reset без результата, как у time.Ticker.
+type fakeTicker struct{ *fakeTimer }
+
+func (t fakeTicker) Stop() { t.fakeTimer.Stop() }
+
+func (t fakeTicker) Reset(d time.Duration) { t.fakeTimer.Reset(d) }
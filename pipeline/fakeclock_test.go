@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+// fired - срабатывание таймера, уже находящееся в канале c.
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	timer := c.NewTimer(time.Second)
+	c.Advance(999 * time.Millisecond)
+	if fired(timer.C()) {
+		t.Fatal("таймер сработал до срока")
+	}
+	c.Advance(time.Millisecond)
+	if !fired(timer.C()) {
+		t.Fatal("таймер не сработал в срок")
+	}
+	if c.Timers() != 0 {
+		t.Fatalf("активных таймеров после срабатывания: %d, ожидалось 0", c.Timers())
+	}
+}
+
+func TestFakeClockResetDropsStale(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	timer := c.NewTimer(time.Second)
+	c.Advance(time.Second) // Срабатывание не прочитано
+	if !timer.Reset(time.Second) {
+		t.Fatal("Reset непрочитанного таймера вернул false")
+	}
+	if fired(timer.C()) {
+		t.Fatal("после Reset доставлено прежнее срабатывание")
+	}
+	c.Advance(time.Second)
+	if !fired(timer.C()) {
+		t.Fatal("перезапущенный таймер не сработал")
+	}
+}
+
+func TestFakeClockStopDropsStale(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	timer := c.NewTimer(time.Second)
+	c.Advance(time.Second)
+	if !timer.Stop() {
+		t.Fatal("Stop непрочитанного таймера вернул false")
+	}
+	if fired(timer.C()) {
+		t.Fatal("после Stop доставлено срабатывание")
+	}
+	if timer.Stop() {
+		t.Fatal("повторный Stop вернул true")
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+	for i := range 3 {
+		c.Advance(time.Second)
+		if !fired(ticker.C()) {
+			t.Fatalf("тикер не сработал на периоде %d", i+1)
+		}
+	}
+	c.Advance(5 * time.Second) // Пропущенные периоды не накапливаются
+	if !fired(ticker.C()) || fired(ticker.C()) {
+		t.Fatal("после пропуска периодов ожидалось одно срабатывание")
+	}
+	ticker.Reset(2 * time.Second)
+	c.Advance(time.Second)
+	if fired(ticker.C()) {
+		t.Fatal("тикер сработал до нового периода")
+	}
+	c.Advance(time.Second)
+	if !fired(ticker.C()) {
+		t.Fatal("тикер не сработал по новому периоду")
+	}
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		c.BlockUntil(2)
+		close(done)
+	}()
+	c.NewTimer(time.Second)
+	select {
+	case <-done:
+		t.Fatal("BlockUntil вернулся при одном таймере")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.NewTicker(time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("BlockUntil не вернулся при двух таймерах")
+	}
+}
@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Predicate - условие фильтра: значение пропускается, если результат true.
+type Predicate[T any] func(v T) bool
+
+// FilterStage - стадия пайплайна: пропуск только значений, удовлетворяющих pred.
+func FilterStage[T any](pred Predicate[T]) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		RunFilter(ctx, in, out, pred)
+	}
+}
+
+// RunFilter - передача из in в out значений, для которых keep возвращает true.
+// Завершается, как стадия, при закрытии in или отмене ctx, закрывая out.
+// Условие keep отделено от работы с каналами: его можно проверять и
+// подменять без запуска пайплайна.
+func RunFilter[T any](ctx context.Context, in <-chan T, out chan<- T, keep Predicate[T]) {
+	runFilter(ctx, in, out, keep, nil)
+}
+
+// runFilter - RunFilter с передачей отброшенных значений в Reject: сведения
+// об отброшенном значении создает reject (nil - не передаются).
+func runFilter[T any](ctx context.Context, in <-chan T, out chan<- T, keep Predicate[T], reject func(v T) *Rejection) {
+	defer close(out)
+	audit := reject != nil && rejecting(ctx)
+	for {
+		select {
+		case n, ok := <-in:
+			if !ok {
+				return
+			}
+			if !keep(n) {
+				if audit {
+					Reject(ctx, reject(n))
+				}
+				continue
+			}
+			if !send(ctx, out, n) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// KeepNonNegative - условие фильтра отрицательных чисел: пропускаются n >= 0.
+func KeepNonNegative[T Signed | Float](n T) bool {
+	return n >= 0
+}
+
+// KeepDivisibleBy3 - условие фильтра div3: пропускаются числа, кратные 3,
+// кроме 0. Отбрасываются, наоборот, не кратные 3 - отсюда название стадии
+// FilterNotDivisibleBy3.
+func KeepDivisibleBy3[T Integer](n T) bool {
+	return n != 0 && n%3 == 0
+}
+
+// KeepNonNegativeNum - KeepNonNegative для Num (фильтр negative).
+func KeepNonNegativeNum(n Num) bool {
+	return n.Sign() >= 0
+}
+
+// KeepDivisibleBy3Num - KeepDivisibleBy3 для Num (фильтр div3): числа
+// с дробной частью не пропускаются.
+func KeepDivisibleBy3Num(n Num) bool {
+	return n.Sign() != 0 && divisible(n, 3)
+}
+
+// predicateFactory - создание предиката по аргументу из описания вида имя:аргумент.
+type predicateFactory func(arg string) (Predicate[Num], error)
+
+// filters - реестр именованных фильтров чисел. Фильтры делимости (div3, even,
+// odd) не пропускают числа с дробной частью.
+var (
+	filtersMu sync.RWMutex
+	filters   = map[string]predicateFactory{
+		"negative": noArg(KeepNonNegativeNum),
+		"div3":     noArg(KeepDivisibleBy3Num),
+		"even":     noArg(func(n Num) bool { return divisible(n, 2) }),
+		"odd":      noArg(func(n Num) bool { return integral(n) && !divisible(n, 2) }),
+		"range":    rangePredicate,
+	}
+)
+
+// integral - n целое или число с плавающей точкой без дробной части.
+func integral(n Num) bool {
+	return n.IsInt() || n.Rem(IntNum(1)).Sign() == 0
+}
+
+// divisible - n без дробной части и делится на d без остатка.
+func divisible(n Num, d int64) bool {
+	return integral(n) && n.Rem(IntNum(d)).Sign() == 0
+}
+
+// noArg - фабрика предиката без аргумента.
+func noArg(pred Predicate[Num]) predicateFactory {
+	return func(arg string) (Predicate[Num], error) {
+		if arg != "" {
+			return nil, fmt.Errorf("фильтр не принимает аргумент: %q", arg)
+		}
+		return pred, nil
+	}
+}
+
+// rangePredicate - пропуск значений из отрезка [min, max], аргумент вида min-max.
+func rangePredicate(arg string) (Predicate[Num], error) {
+	// Поиск разделителя после первого символа: границы могут быть отрицательными
+	for i := 1; i < len(arg); i++ {
+		if arg[i] != '-' || arg[i-1] == 'e' || arg[i-1] == 'E' {
+			continue
+		}
+		lo, errLo := ParseNum(arg[:i])
+		hi, errHi := ParseNum(arg[i+1:])
+		if errLo != nil || errHi != nil {
+			continue
+		}
+		if lo.Cmp(hi) > 0 {
+			return nil, fmt.Errorf("нижняя граница больше верхней: %s > %s", lo, hi)
+		}
+		return func(n Num) bool { return n.Cmp(lo) >= 0 && n.Cmp(hi) <= 0 }, nil
+	}
+	return nil, fmt.Errorf("ожидается отрезок вида min-max, получено %q", arg)
+}
+
+// Filter - регистрация предиката pred как фильтра с именем name.
+// Повторная регистрация имени приводит к панике.
+func Filter(name string, pred func(Num) bool) {
+	if name == "" || strings.Contains(name, ":") {
+		panic(fmt.Sprintf("pipeline: некорректное имя фильтра %q", name))
+	}
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+	if _, ok := filters[name]; ok {
+		panic(fmt.Sprintf("pipeline: фильтр %q уже зарегистрирован", name))
+	}
+	filters[name] = noArg(pred)
+}
+
+// FilterNames - отсортированный список зарегистрированных фильтров.
+func FilterNames() []string {
+	filtersMu.RLock()
+	defer filtersMu.RUnlock()
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFilter - предикат по описанию вида имя или имя:аргумент (например, range:0-100).
+func ParseFilter(spec string) (Predicate[Num], error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	filtersMu.RLock()
+	factory, ok := filters[name]
+	filtersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("неизвестный фильтр %q (доступны: %s)", name, strings.Join(FilterNames(), ", "))
+	}
+	pred, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("фильтр %s: %w", name, err)
+	}
+	return pred, nil
+}
@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+	"testing/quick"
+)
+
+func TestKeepNonNegativeQuick(t *testing.T) {
+	ints := func(n int64) bool { return KeepNonNegative(n) == (n >= 0) && KeepNonNegativeNum(IntNum(n)) == (n >= 0) }
+	floats := func(f float64) bool {
+		return KeepNonNegative(f) == (f >= 0) && KeepNonNegativeNum(FloatNum(f)) == (f >= 0)
+	}
+	for name, prop := range map[string]any{"int": ints, "float": floats} {
+		if err := quick.Check(prop, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestKeepDivisibleBy3Quick(t *testing.T) {
+	ref := func(n int64) bool { return n != 0 && n%3 == 0 }
+	prop := func(n int64, small int8) bool {
+		for _, v := range []int64{n, int64(small)} {
+			if KeepDivisibleBy3(v) != ref(v) || KeepDivisibleBy3Num(IntNum(v)) != ref(v) {
+				return false
+			}
+			// Большие целые вне int64 с тем же остатком
+			b := new(big.Int).Mul(big.NewInt(v), new(big.Int).Lsh(big.NewInt(3), 70))
+			b.Add(b, big.NewInt(v))
+			want := b.Sign() != 0 && new(big.Int).Rem(b, big.NewInt(3)).Sign() == 0
+			if KeepDivisibleBy3Num(BigNum(b)) != want {
+				return false
+			}
+		}
+		// Числа с дробной частью не пропускаются, целые во float - как целые
+		return !KeepDivisibleBy3Num(FloatNum(float64(small)+0.5)) &&
+			KeepDivisibleBy3Num(FloatNum(float64(small))) == ref(int64(small))
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFilterQuick(t *testing.T) {
+	refs := map[string]func(n int64) bool{
+		"negative": func(n int64) bool { return n >= 0 },
+		"div3":     func(n int64) bool { return n != 0 && n%3 == 0 },
+		"even":     func(n int64) bool { return n%2 == 0 },
+		"odd":      func(n int64) bool { return n%2 != 0 },
+	}
+	for spec, ref := range refs {
+		pred, err := ParseFilter(spec)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", spec, err)
+		}
+		if err := quick.Check(func(n int64) bool { return pred(IntNum(n)) == ref(n) }, nil); err != nil {
+			t.Errorf("%s: %v", spec, err)
+		}
+	}
+	rangeProp := func(a, b int32, n int64) bool {
+		lo, hi := min(int64(a), int64(b)), max(int64(a), int64(b))
+		pred, err := ParseFilter(fmt.Sprintf("range:%d-%d", lo, hi))
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		// Проверяются и значения вблизи границ
+		for _, v := range []int64{n, lo, hi, lo - 1, hi + 1} {
+			if pred(IntNum(v)) != (v >= lo && v <= hi) {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(rangeProp, nil); err != nil {
+		t.Errorf("range: %v", err)
+	}
+}
+
+// TestRunFilterQuick - RunFilter пропускает ровно значения, удовлетворяющие
+// условию, в исходном порядке.
+func TestRunFilterQuick(t *testing.T) {
+	prop := func(values []int64) bool {
+		in := make(chan int64)
+		out := make(chan int64)
+		go RunFilter(context.Background(), in, out, KeepNonNegative[int64])
+		go func() {
+			defer close(in)
+			for _, v := range values {
+				in <- v
+			}
+		}()
+		var got []int64
+		for v := range out {
+			got = append(got, v)
+		}
+		want := slices.DeleteFunc(slices.Clone(values), func(v int64) bool { return v < 0 })
+		return slices.Equal(got, want)
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func FuzzParseFilter(f *testing.F) {
+	for _, s := range []string{"negative", "div3", "even", "odd", "range:0-100", "range:-5--1", "range:1e2-1e3", "range:5-1", "negative:1", "unknown", ""} {
+		f.Add(s, int64(7))
+	}
+	f.Fuzz(func(t *testing.T, spec string, n int64) {
+		pred, err := ParseFilter(spec)
+		if err != nil {
+			if pred != nil {
+				t.Fatalf("ParseFilter(%q): предикат вместе с ошибкой %v", spec, err)
+			}
+			return
+		}
+		for _, v := range []Num{IntNum(n), FloatNum(float64(n) / 7), BigNum(new(big.Int).Lsh(big.NewInt(n), 80))} {
+			pred(v)
+		}
+	})
+}
+
+func TestCompileExprQuick(t *testing.T) {
+	for src, ref := range map[string]Predicate[Num]{
+		"x >= 0":                   KeepNonNegativeNum,
+		"x != 0 && x % 3 == 0":     KeepDivisibleBy3Num,
+		"!(x < 0)":                 KeepNonNegativeNum,
+		"x % 2 == 0 || x % 2 != 0": func(Num) bool { return true },
+	} {
+		pred, err := CompileExpr(src)
+		if err != nil {
+			t.Fatalf("CompileExpr(%q): %v", src, err)
+		}
+		if err := quick.Check(func(n int64) bool { return pred(IntNum(n)) == ref(IntNum(n)) }, nil); err != nil {
+			t.Errorf("%s: %v", src, err)
+		}
+	}
+	// Отрезок совпадает с фильтром range
+	prop := func(a, b int32, n int64) bool {
+		lo, hi := min(a, b), max(a, b)
+		pred, err := CompileExpr(fmt.Sprintf("x >= %d && x <= %d", lo, hi))
+		return err == nil && pred(IntNum(n)) == (n >= int64(lo) && n <= int64(hi))
+	}
+	if err := quick.Check(prop, nil); err != nil {
+		t.Errorf("отрезок: %v", err)
+	}
+}
+
+func FuzzCompileExpr(f *testing.F) {
+	for _, s := range []string{"x > 0", "x >= 0 && x % 3 == 0", "!(x == 1) || x / 0 > 1", "(x + 1) * 2 < 1e300", "x", "1 +", "x >= 12345678901234567890", "((x)"} {
+		f.Add(s, int64(3))
+	}
+	f.Fuzz(func(t *testing.T, src string, n int64) {
+		check, err := CompileExprChecked(src)
+		pred, predErr := CompileExpr(src)
+		if (err == nil) != (predErr == nil) {
+			t.Fatalf("CompileExpr(%q) и CompileExprChecked расходятся: %v, %v", src, predErr, err)
+		}
+		if err != nil {
+			return
+		}
+		for _, x := range []Num{IntNum(n), FloatNum(float64(n) / 3)} {
+			pass, evalErr := check(x)
+			if pred(x) != (pass && evalErr == nil) {
+				t.Fatalf("%q при x = %s: предикат расходится с проверкой (%v, %v)", src, x, pass, evalErr)
+			}
+		}
+	})
+}
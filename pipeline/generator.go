@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Режимы генерации значений.
+const (
+	GenModeSeq    = "seq"    // Последовательные числа 0, 1, 2, ...
+	GenModeRandom = "random" // Случайные числа из диапазона [-genRandomRange, genRandomRange]
+)
+
+// genRandomRange - граница диапазона случайных значений.
+const genRandomRange = 1000
+
+// GeneratorSource - синтетический источник целых чисел для нагрузочного тестирования.
+type GeneratorSource struct {
+	Rate     int           // Значений в секунду (0 - без ограничения)
+	Count    int           // Количество значений (0 - без ограничения)
+	Duration time.Duration // Длительность генерации (0 - без ограничения)
+	Mode     string        // Режим генерации: seq или random
+}
+
+// Validate - проверка параметров генератора.
+func (g GeneratorSource) Validate() error {
+	if g.Rate < 0 {
+		return fmt.Errorf("gen-rate не может быть отрицательным: %d", g.Rate)
+	}
+	if g.Count < 0 {
+		return fmt.Errorf("gen-count не может быть отрицательным: %d", g.Count)
+	}
+	if g.Duration < 0 {
+		return fmt.Errorf("gen-duration не может быть отрицательным: %s", g.Duration)
+	}
+	if g.Count == 0 && g.Duration == 0 {
+		return fmt.Errorf("необходимо задать gen-count или gen-duration")
+	}
+	if g.Mode != GenModeSeq && g.Mode != GenModeRandom {
+		return fmt.Errorf("неизвестный режим генерации: %q (ожидается seq или random)", g.Mode)
+	}
+	return nil
+}
+
+// Run - генерация значений в out до исчерпания Count, истечения Duration
+// или отмены ctx. Возвращает количество отправленных значений.
+func (g GeneratorSource) Run(ctx context.Context, out chan<- Num) int {
+	start := time.Now()
+	var interval time.Duration
+	if g.Rate > 0 {
+		interval = time.Second / time.Duration(g.Rate)
+	}
+
+	sent := 0
+	for g.Count == 0 || sent < g.Count {
+		if g.Duration > 0 && time.Since(start) >= g.Duration {
+			break
+		}
+		// Выдерживание заданной частоты генерации
+		if interval > 0 {
+			if wait := time.Until(start.Add(time.Duration(sent) * interval)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return sent
+				}
+			}
+		}
+
+		val := sent
+		if g.Mode == GenModeRandom {
+			val = rand.IntN(2*genRandomRange+1) - genRandomRange
+		}
+		if !send(ctx, out, IntNum(int64(val))) {
+			return sent
+		}
+		sent++
+	}
+	return sent
+}
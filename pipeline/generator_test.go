@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGeneratorCount(t *testing.T) {
+	for _, mode := range []string{GenModeSeq, GenModeRandom} {
+		g := GeneratorSource{Count: 100, Mode: mode}
+		if err := g.Validate(); err != nil {
+			t.Fatalf("%s: Validate: %v", mode, err)
+		}
+		ctx := testContext(t)
+		in := make(chan Num)
+		sent := make(chan int, 1)
+		go func() {
+			defer close(in)
+			sent <- g.Run(ctx, in)
+		}()
+		var got []Num
+		for n := range Chain(ctx, in, ItemStage(func(n Num) (Num, bool, error) { return n, true, nil })) {
+			got = append(got, n)
+		}
+		if len(got) != 100 || <-sent != 100 {
+			t.Fatalf("%s: в пайплайн поступило %d значений, ожидалось 100", mode, len(got))
+		}
+		if mode == GenModeSeq {
+			for i, n := range got {
+				if n.String() != IntNum(int64(i)).String() {
+					t.Fatalf("значение %d: %s, ожидалось %d", i, n, i)
+				}
+			}
+		}
+	}
+}
+
+func TestGeneratorCancelAtLowRate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := GeneratorSource{Rate: 1, Count: 10, Mode: GenModeSeq}
+	out := make(chan Num, 10)
+	done := make(chan int, 1)
+	go func() { done <- g.Run(ctx, out) }()
+	<-out // Первое значение отправляется сразу, следующее - через 1s
+	cancel()
+	select {
+	case sent := <-done:
+		if sent != 1 {
+			t.Fatalf("отправлено %d значений, ожидалось 1", sent)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("генератор не остановился при отмене ctx")
+	}
+}
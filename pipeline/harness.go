@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Harness - стенд для проверки стадий: значения передаются программно через
+// Send, результат накапливается в памяти. Вместе с FakeClock позволяет
+// детерминированно проверять стадии, зависящие от времени:
+//
+//	clock := pipeline.NewFakeClock(time.Unix(0, 0))
+//	h := pipeline.NewHarness(ctx, pipeline.NewBufferWith(pipeline.BufferOptions[int]{
+//		Size: 10, FlushInterval: time.Second, Clock: clock,
+//	}))
+//	h.Send(1, 2)
+//	clock.Advance(time.Second) // Буфер отправляет 1 и 2
+//	out, ok := h.WaitOutput(2, time.Second)
+type Harness[T any] struct {
+	ctx  context.Context
+	in   chan T
+	sink SliceSink[T]
+	done chan error
+	once sync.Once
+	err  error
+}
+
+// NewHarness - запуск стадий stages; стенд работает до Close или отмены ctx.
+func NewHarness[T any](ctx context.Context, stages ...Stage[T]) *Harness[T] {
+	h := &Harness[T]{ctx: ctx, in: make(chan T), done: make(chan error, 1)}
+	out := Chain(ctx, h.in, stages...)
+	go func() {
+		h.done <- Drain(ctx, out, &h.sink)
+	}()
+	return h
+}
+
+// Send - передача значений первой стадии. Возвращает управление, когда
+// стадия приняла все значения; false - ctx отменен раньше.
+func (h *Harness[T]) Send(values ...T) bool {
+	for _, v := range values {
+		select {
+		case h.in <- v:
+		case <-h.ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// Close - закрытие входа и ожидание завершения стадий. Возвращает ошибку
+// приемника или ctx.Err() при отмене. Повторный вызов возвращает тот же результат.
+func (h *Harness[T]) Close() error {
+	h.once.Do(func() {
+		close(h.in)
+		h.err = <-h.done
+	})
+	return h.err
+}
+
+// Output - копия значений, полученных на выходе к моменту вызова.
+func (h *Harness[T]) Output() []T {
+	return h.sink.Values()
+}
+
+// WaitOutput - ожидание не менее n значений на выходе в течение timeout
+// реального времени; false - значений не дождались (возвращаются имеющиеся).
+func (h *Harness[T]) WaitOutput(n int, timeout time.Duration) ([]T, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		out := h.sink.Values()
+		if len(out) >= n {
+			return out, true
+		}
+		if time.Now().After(deadline) {
+			return out, false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testTimeout - ожидание результата стадий в тестах (реальное время).
+const testTimeout = 2 * time.Second
+
+// testContext - контекст теста, отменяемый по его завершении.
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestHarnessPassthrough(t *testing.T) {
+	h := NewHarness(testContext(t), ItemStage(func(v int) (int, bool, error) { return v * 2, v != 2, nil }))
+	if !h.Send(1, 2, 3) {
+		t.Fatal("Send прерван")
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if got := h.Output(); !slices.Equal(got, []int{2, 6}) {
+		t.Fatalf("выход %v, ожидалось [2 6]", got)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("повторный Close: %v", err)
+	}
+}
+
+func TestHarnessBufferFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := NewHarness(testContext(t), NewBufferWith(BufferOptions[int]{
+		Size: 10, FlushInterval: time.Second, Clock: clock,
+	}))
+	defer h.Close()
+	clock.BlockUntil(1) // Тикер буфера создан
+	h.Send(1, 2)
+	clock.Advance(999 * time.Millisecond)
+	if out, ok := h.WaitOutput(1, 20*time.Millisecond); ok {
+		t.Fatalf("буфер отправлен до интервала: %v", out)
+	}
+	clock.Advance(time.Millisecond)
+	out, ok := h.WaitOutput(2, testTimeout)
+	if !ok || !slices.Equal(out, []int{1, 2}) {
+		t.Fatalf("выход %v, ожидалось [1 2]", out)
+	}
+}
+
+// TestHarnessBufferEndOfInput - закрытие входа - конец потока: буфер
+// отправляет остаток сразу, без ожидания интервала отправки.
+func TestHarnessBufferEndOfInput(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := NewHarness(testContext(t), NewBufferWith(BufferOptions[int]{
+		Size: 10, FlushInterval: time.Hour, Clock: clock,
+	}))
+	clock.BlockUntil(1)
+	h.Send(1, 2, 3)
+	if out, ok := h.WaitOutput(1, 20*time.Millisecond); ok {
+		t.Fatalf("буфер отправлен до интервала: %v", out)
+	}
+	// Время не продвигается: остаток отправляется по закрытию входа
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Output(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("выход %v, ожидалось [1 2 3]", got)
+	}
+}
+
+func TestHarnessCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := NewHarness(ctx, func(ctx context.Context, in <-chan int, out chan<- int) {
+		defer close(out)
+		<-ctx.Done() // Стадия не читает вход
+	})
+	cancel()
+	if h.Send(1) {
+		t.Fatal("Send после отмены вернул true")
+	}
+	if err := h.Close(); err == nil {
+		t.Fatal("Close после отмены без ошибки")
+	}
+}
@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"container/heap"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Item - значение с метаданными: момент поступления в пайплайн, порядковый
+// номер и идентификатор источника. Стадии над Item меняют Value и сохраняют
+// метаданные, что позволяет измерять задержку от входа до выхода и
+// восстанавливать порядок после параллельной обработки.
+type Item[T any] struct {
+	Value      T
+	IngestedAt time.Time // Момент поступления в пайплайн
+	Seq        uint64    // Порядковый номер (с 1), монотонно возрастает
+	Source     string    // Идентификатор источника
+	Anomaly    *Anomaly  // Отклонение, найденное DetectAnomaliesBy с MarkAnomaly (nil - нет)
+}
+
+// Latency - время от поступления значения в пайплайн до now.
+func (it Item[T]) Latency(now time.Time) time.Duration {
+	return now.Sub(it.IngestedAt)
+}
+
+// ItemValue - значение Item (ключ для стадий ...By).
+func ItemValue[T any](it Item[T]) T {
+	return it.Value
+}
+
+// Sequence - генератор порядковых номеров, общий для нескольких источников.
+type Sequence struct {
+	n atomic.Uint64
+}
+
+// Next - следующий номер (с 1).
+func (s *Sequence) Next() uint64 {
+	return s.n.Add(1)
+}
+
+// Last - последний выданный номер (количество выданных номеров).
+func (s *Sequence) Last() uint64 {
+	return s.n.Load()
+}
+
+// Wrap - обертывание значений in в Item с источником source и номерами seq
+// (nil - собственная нумерация). Выход закрывается при закрытии in или отмене ctx.
+func Wrap[T any](ctx context.Context, in <-chan T, source string, seq *Sequence, clock Clock) <-chan Item[T] {
+	if seq == nil {
+		seq = &Sequence{}
+	}
+	out := make(chan Item[T])
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				it := Item[T]{Value: v, IngestedAt: clock.Now(), Seq: seq.Next(), Source: source}
+				if !send(ctx, out, it) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Stamp - присвоение значениям in момента поступления и номеров seq (nil -
+// собственная нумерация) с сохранением источника, например после объединения
+// нескольких источников (Merge). Выход закрывается при закрытии in или отмене ctx.
+func Stamp[T any](ctx context.Context, in <-chan Item[T], seq *Sequence, clock Clock) <-chan Item[T] {
+	if seq == nil {
+		seq = &Sequence{}
+	}
+	out := make(chan Item[T])
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case it, ok := <-in:
+				if !ok {
+					return
+				}
+				it.IngestedAt, it.Seq = clock.Now(), seq.Next()
+				if !send(ctx, out, it) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Unwrap - значения Item из in без метаданных.
+func Unwrap[T any](ctx context.Context, in <-chan Item[T]) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case it, ok := <-in:
+				if !ok {
+					return
+				}
+				if !send(ctx, out, it.Value) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// LiftItem - обработка Item функцией fn над значением с сохранением метаданных.
+func LiftItem[T any](fn ItemFunc[T]) ItemFunc[Item[T]] {
+	return func(it Item[T]) (Item[T], bool, error) {
+		v, pass, err := fn(it.Value)
+		it.Value = v
+		return it, pass, err
+	}
+}
+
+// ItemAggregate - агрегирование окна Item функцией agg. Результат получает
+// метаданные первого (самого раннего) значения окна, поэтому его задержка
+// отсчитывается от поступления самого старого учтенного значения.
+// Функция agg - Aggregate или NumAggregate.
+func ItemAggregate[T any](agg func(values []T) T) func(window []Item[T]) Item[T] {
+	return func(window []Item[T]) Item[T] {
+		values := make([]T, len(window))
+		for i, it := range window {
+			values[i] = it.Value
+		}
+		it := window[0]
+		it.Value = agg(values)
+		return it
+	}
+}
+
+// itemHeap - очередь Item по возрастанию Seq.
+type itemHeap[T any] []Item[T]
+
+func (h itemHeap[T]) Len() int           { return len(h) }
+func (h itemHeap[T]) Less(i, j int) bool { return h[i].Seq < h[j].Seq }
+func (h itemHeap[T]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *itemHeap[T]) Push(x any)        { *h = append(*h, x.(Item[T])) }
+func (h *itemHeap[T]) Pop() any {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
+
+// ReorderItems - стадия, восстанавливающая порядок Seq после параллельной
+// обработки: удерживает до window значений и отправляет значение с наименьшим
+// номером. Порядок восстанавливается, если значение опережено не более чем
+// window другими; отброшенные значения не ожидаются. При закрытии входа
+// удерживаемые значения отправляются по порядку.
+func ReorderItems[T any](window int) Stage[Item[T]] {
+	if window < 1 {
+		window = 1
+	}
+	return func(ctx context.Context, in <-chan Item[T], out chan<- Item[T]) {
+		defer close(out)
+		h := make(itemHeap[T], 0, window+1)
+		for {
+			select {
+			case it, ok := <-in:
+				if !ok {
+					for h.Len() > 0 {
+						if !send(ctx, out, heap.Pop(&h).(Item[T])) {
+							return
+						}
+					}
+					return
+				}
+				heap.Push(&h, it)
+				if h.Len() > window && !send(ctx, out, heap.Pop(&h).(Item[T])) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
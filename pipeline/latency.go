@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLatencyBuckets - верхние границы корзин гистограммы интервалов.
+var defaultLatencyBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// histogram - гистограмма длительностей по корзинам bounds.
+type histogram struct {
+	bounds []time.Duration
+
+	mu     sync.Mutex
+	counts []uint64 // Последняя корзина - длительности больше всех границ
+	total  uint64
+	sum    time.Duration
+}
+
+// newHistogram - пустая гистограмма с корзинами по умолчанию.
+func newHistogram() histogram {
+	return histogram{
+		bounds: defaultLatencyBuckets,
+		counts: make([]uint64, len(defaultLatencyBuckets)+1),
+	}
+}
+
+// add - учет длительности d. Вызывается под mu.
+func (h *histogram) add(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.total++
+	h.sum += d
+}
+
+// snapshot - текущее состояние гистограммы.
+func (h *histogram) snapshot() LatencyHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return LatencyHistogram{
+		Bounds: append([]time.Duration(nil), h.bounds...),
+		Counts: append([]uint64(nil), h.counts...),
+		Count:  h.total,
+		Sum:    h.sum,
+	}
+}
+
+// LatencyRecorder - стадия, пропускающая значения без изменений и
+// записывающая интервалы между соседними значениями в гистограмму.
+type LatencyRecorder[T any] struct {
+	clock Clock
+	hist  histogram
+	last  time.Time // Под hist.mu
+	seen  bool
+}
+
+// NewLatencyRecorder - создание стадии записи интервалов между значениями.
+func NewLatencyRecorder[T any](clock Clock) *LatencyRecorder[T] {
+	return &LatencyRecorder[T]{clock: clock, hist: newHistogram()}
+}
+
+// Run - стадия пайплайна (совместима с Stage).
+func (r *LatencyRecorder[T]) Run(ctx context.Context, in <-chan T, out chan<- T) {
+	defer close(out)
+	for {
+		select {
+		case n, ok := <-in:
+			if !ok {
+				return
+			}
+			r.observe(r.clock.Now())
+			if !send(ctx, out, n) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// observe - учет прихода значения в момент now.
+func (r *LatencyRecorder[T]) observe(now time.Time) {
+	r.hist.mu.Lock()
+	defer r.hist.mu.Unlock()
+
+	if r.seen {
+		r.hist.add(now.Sub(r.last))
+	}
+	r.last, r.seen = now, true
+}
+
+// Snapshot - текущее состояние гистограммы.
+func (r *LatencyRecorder[T]) Snapshot() LatencyHistogram {
+	return r.hist.snapshot()
+}
+
+// ItemLatencyRecorder - стадия, пропускающая Item без изменений и
+// записывающая их задержку от поступления в пайплайн (Item.IngestedAt).
+type ItemLatencyRecorder[T any] struct {
+	clock Clock
+	hist  histogram
+}
+
+// NewItemLatencyRecorder - создание стадии записи сквозной задержки.
+func NewItemLatencyRecorder[T any](clock Clock) *ItemLatencyRecorder[T] {
+	return &ItemLatencyRecorder[T]{clock: clock, hist: newHistogram()}
+}
+
+// Run - стадия пайплайна (совместима с Stage).
+func (r *ItemLatencyRecorder[T]) Run(ctx context.Context, in <-chan Item[T], out chan<- Item[T]) {
+	defer close(out)
+	for {
+		select {
+		case it, ok := <-in:
+			if !ok {
+				return
+			}
+			d := it.Latency(r.clock.Now())
+			r.hist.mu.Lock()
+			r.hist.add(d)
+			r.hist.mu.Unlock()
+			if !send(ctx, out, it) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Snapshot - текущее состояние гистограммы задержек.
+func (r *ItemLatencyRecorder[T]) Snapshot() LatencyHistogram {
+	s := r.hist.snapshot()
+	s.Label = "Значений"
+	return s
+}
+
+// LatencyHistogram - снимок гистограммы интервалов.
+// Counts[i] - количество интервалов в (Bounds[i-1], Bounds[i]],
+// последний элемент Counts - интервалы больше Bounds[len(Bounds)-1].
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+	Label  string // Название наблюдений в String (пусто - "Интервалов")
+}
+
+// String - текстовое представление гистограммы.
+func (h LatencyHistogram) String() string {
+	var sb strings.Builder
+	label := h.Label
+	if label == "" {
+		label = "Интервалов"
+	}
+	fmt.Fprintf(&sb, "%s: %d", label, h.Count)
+	if h.Count > 0 {
+		fmt.Fprintf(&sb, ", средний: %s", h.Sum/time.Duration(h.Count))
+	}
+	for i, c := range h.Counts {
+		if i < len(h.Bounds) {
+			fmt.Fprintf(&sb, "\n  <= %-8s %d", h.Bounds[i], c)
+		} else {
+			fmt.Fprintf(&sb, "\n   > %-8s %d", h.Bounds[len(h.Bounds)-1], c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyRecorderBuckets(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	r := NewLatencyRecorder[int](clock)
+	h := NewHarness(testContext(t), r.Run)
+	defer h.Close()
+
+	gaps := []time.Duration{
+		500 * time.Microsecond, // <= 1ms
+		time.Millisecond,       // <= 1ms: граница входит в корзину
+		5 * time.Millisecond,   // <= 10ms
+		50 * time.Millisecond,  // <= 100ms
+		20 * time.Second,       // > 10s
+	}
+	h.Send(0)
+	for i, gap := range gaps {
+		// Значение на выходе - момент прихода уже записан
+		if _, ok := h.WaitOutput(i+1, testTimeout); !ok {
+			t.Fatalf("нет значения %d на выходе", i)
+		}
+		clock.Advance(gap)
+		h.Send(i + 1)
+	}
+	if _, ok := h.WaitOutput(len(gaps)+1, testTimeout); !ok {
+		t.Fatal("нет последнего значения на выходе")
+	}
+
+	s := r.Snapshot()
+	want := []uint64{0, 0, 0, 2, 1, 1, 0, 0, 1}
+	if !slices.Equal(s.Counts, want) {
+		t.Fatalf("корзины %v, ожидалось %v", s.Counts, want)
+	}
+	var sum time.Duration
+	for _, gap := range gaps {
+		sum += gap
+	}
+	if s.Count != uint64(len(gaps)) || s.Sum != sum {
+		t.Fatalf("интервалов %d, сумма %s; ожидалось %d и %s", s.Count, s.Sum, len(gaps), sum)
+	}
+	if !strings.HasPrefix(s.String(), "Интервалов: 5") {
+		t.Fatalf("String: %q", s.String())
+	}
+}
+
+func TestItemLatencyRecorder(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewFakeClock(start)
+	r := NewItemLatencyRecorder[int](clock)
+	h := NewHarness(testContext(t), r.Run)
+	defer h.Close()
+
+	clock.Advance(2 * time.Millisecond)
+	h.Send(Item[int]{Value: 1, IngestedAt: start})
+	h.Send(Item[int]{Value: 2, IngestedAt: start.Add(time.Millisecond)})
+	if _, ok := h.WaitOutput(2, testTimeout); !ok {
+		t.Fatal("нет значений на выходе")
+	}
+	s := r.Snapshot()
+	if s.Counts[3] != 1 || s.Counts[4] != 1 || s.Count != 2 {
+		t.Fatalf("корзины %v, ожидалось по одному значению в <= 1ms и <= 10ms", s.Counts)
+	}
+	if s.Label != "Значений" {
+		t.Fatalf("подпись %q", s.Label)
+	}
+}
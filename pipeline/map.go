@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mapper - преобразование значения стадией Map.
+type Mapper[T any] func(v T) T
+
+// MapStage - стадия пайплайна: применение fn к каждому значению.
+func MapStage[T any](fn Mapper[T]) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					return
+				}
+				if !send(ctx, out, fn(n)) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// mapperFactory - создание преобразования по аргументу из описания вида имя:аргумент.
+type mapperFactory func(arg string) (Mapper[Num], error)
+
+// mappers - реестр именованных преобразований чисел.
+var (
+	mappersMu sync.RWMutex
+	mappers   = map[string]mapperFactory{
+		"abs":   mapNoArg(Num.Abs),
+		"neg":   mapNoArg(Num.Neg),
+		"scale": mapNumArg(func(k Num) (Mapper[Num], error) { return func(n Num) Num { return n.Mul(k) }, nil }),
+		"add":   mapNumArg(func(k Num) (Mapper[Num], error) { return func(n Num) Num { return n.Add(k) }, nil }),
+		"mod": mapNumArg(func(m Num) (Mapper[Num], error) {
+			if m.Sign() <= 0 {
+				return nil, fmt.Errorf("модуль должен быть положительным: %s", m)
+			}
+			// Остаток всегда неотрицательный: mod:3 переводит -1 в 2
+			return func(n Num) Num {
+				r := n.Rem(m)
+				if r.Sign() < 0 {
+					r = r.Add(m)
+				}
+				return r
+			}, nil
+		}),
+	}
+)
+
+// mapNoArg - фабрика преобразования без аргумента.
+func mapNoArg(fn Mapper[Num]) mapperFactory {
+	return func(arg string) (Mapper[Num], error) {
+		if arg != "" {
+			return nil, fmt.Errorf("преобразование не принимает аргумент: %q", arg)
+		}
+		return fn, nil
+	}
+}
+
+// mapNumArg - фабрика преобразования с числовым аргументом.
+func mapNumArg(build func(k Num) (Mapper[Num], error)) mapperFactory {
+	return func(arg string) (Mapper[Num], error) {
+		k, err := ParseNum(arg)
+		if err != nil {
+			return nil, fmt.Errorf("ожидается числовой аргумент, получено %q", arg)
+		}
+		return build(k)
+	}
+}
+
+// Map - регистрация преобразования fn с именем name.
+// Повторная регистрация имени приводит к панике.
+func Map(name string, fn func(Num) Num) {
+	if name == "" || strings.Contains(name, ":") {
+		panic(fmt.Sprintf("pipeline: некорректное имя преобразования %q", name))
+	}
+	mappersMu.Lock()
+	defer mappersMu.Unlock()
+	if _, ok := mappers[name]; ok {
+		panic(fmt.Sprintf("pipeline: преобразование %q уже зарегистрировано", name))
+	}
+	mappers[name] = mapNoArg(fn)
+}
+
+// MapNames - отсортированный список зарегистрированных преобразований.
+func MapNames() []string {
+	mappersMu.RLock()
+	defer mappersMu.RUnlock()
+	names := make([]string, 0, len(mappers))
+	for name := range mappers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseMap - преобразование по описанию вида имя или имя:аргумент (например, scale:10).
+func ParseMap(spec string) (Mapper[Num], error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	mappersMu.RLock()
+	factory, ok := mappers[name]
+	mappersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("неизвестное преобразование %q (доступны: %s)", name, strings.Join(MapNames(), ", "))
+	}
+	fn, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("преобразование %s: %w", name, err)
+	}
+	return fn, nil
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+)
+
+// Merge - объединение каналов ins в один с поочередной выборкой значений
+// (см. MergeWeighted с равными весами).
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	return MergeWeighted(ctx, ins, nil)
+}
+
+// MergeWeighted - объединение каналов ins в один с взвешенной поочередной
+// выборкой: за один круг из канала i берется до weights[i] готовых значений
+// (nil или вес не больше 0 - по одному), поэтому при одновременном поступлении
+// источники получают доли выхода, пропорциональные весам, а ожидание одного
+// источника не задерживает другие. Порядок значений каждого канала сохраняется.
+// Выход закрывается после закрытия всех ins или при отмене ctx.
+func MergeWeighted[T any](ctx context.Context, ins []<-chan T, weights []int) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		active := make([]<-chan T, len(ins))
+		copy(active, ins)
+		left := len(active)
+		weight := func(i int) int {
+			if i < len(weights) && weights[i] > 0 {
+				return weights[i]
+			}
+			return 1
+		}
+		for left > 0 {
+			// Круг по готовым значениям без ожидания
+			progressed := false
+			for i, in := range active {
+				for n := weight(i); in != nil && n > 0; n-- {
+					select {
+					case v, ok := <-in:
+						if !ok {
+							active[i], in = nil, nil
+							left--
+							continue
+						}
+						if !send(ctx, out, v) {
+							return
+						}
+						progressed = true
+					default:
+						n = 0 // Значений нет, следующий канал
+					}
+				}
+			}
+			if progressed || left == 0 {
+				continue
+			}
+			// Готовых значений нет: ожидание любого канала
+			i, v, ok := waitAny(ctx, active)
+			switch {
+			case i < 0:
+				return
+			case !ok:
+				active[i] = nil
+				left--
+			case !send(ctx, out, v):
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// waitAny - ожидание значения любого из каналов ins (nil пропускаются).
+// Возвращает номер канала (-1 при отмене ctx), значение и признак ok получения.
+func waitAny[T any](ctx context.Context, ins []<-chan T) (int, T, bool) {
+	cases := make([]reflect.SelectCase, 0, len(ins)+1)
+	index := make([]int, 0, len(ins))
+	for i, in := range ins {
+		if in != nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(in)})
+			index = append(index, i)
+		}
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+	var zero T
+	chosen, v, ok := reflect.Select(cases)
+	if chosen == len(index) {
+		return -1, zero, false
+	}
+	if !ok {
+		return index[chosen], zero, false
+	}
+	t, _ := v.Interface().(T) // nil интерфейсного типа T - нулевое значение
+	return index[chosen], t, true
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// StageMetrics - счетчики стадии: принятые и переданные дальше значения.
+type StageMetrics struct {
+	Received atomic.Uint64
+	Passed   atomic.Uint64
+}
+
+// Dropped - количество значений, не переданных стадией дальше
+// (с учетом значений, находящихся в обработке).
+func (m *StageMetrics) Dropped() uint64 {
+	received, passed := m.Received.Load(), m.Passed.Load()
+	if passed > received {
+		return 0
+	}
+	return received - passed
+}
+
+// BufferMetrics - метрики стадии буферизации.
+type BufferMetrics struct {
+	Occupancy atomic.Int64  // Текущее количество элементов в буфере
+	Flushes   atomic.Uint64 // Количество непустых отправок буфера
+	Dropped   atomic.Uint64 // Значения, потерянные при переполнении
+	Spilled   atomic.Int64  // Значения в файле сброса на диск
+}
+
+// Instrument - обертка стадии stage, считающая принятые и переданные значения в m.
+func Instrument[T any](m *StageMetrics, stage Stage[T]) Stage[T] {
+	return Observe(stage, func(T) { m.Received.Add(1) }, func(T) { m.Passed.Add(1) })
+}
+
+// Observe - обертка стадии stage, вызывающая onIn для каждого значения до
+// передачи стадии и onOut - для каждого значения на ее выходе. Паника стадии
+// повторяется в горутине обертки как *PanicError, чтобы ее перехватил
+// внешний Supervise.
+func Observe[T any](stage Stage[T], onIn, onOut func(v T)) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		stageIn := make(chan T)
+		go func() {
+			defer close(stageIn)
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return
+					}
+					onIn(v)
+					if !send(ctx, stageIn, v) {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		relayStage(ctx, ctx, stage, stageIn, out, onOut)
+	}
+}
+
+// relayStage - запуск stage с контекстом stageCtx в отдельной горутине и
+// передача ее выхода в out с вызовом onOut до закрытия выхода стадии или
+// отмены ctx. Паника стадии повторяется в вызывающей горутине.
+func relayStage[T any](ctx, stageCtx context.Context, stage Stage[T], in <-chan T, out chan<- T, onOut func(v T)) {
+	stageOut := make(chan T)
+	result := make(chan *PanicError, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- asPanicError(r)
+				return
+			}
+			result <- nil
+		}()
+		stage(stageCtx, in, stageOut)
+	}()
+	for {
+		select {
+		case v, ok := <-stageOut:
+			if !ok {
+				if pe := <-result; pe != nil {
+					panic(pe)
+				}
+				return
+			}
+			onOut(v)
+			if !send(ctx, out, v) {
+				return
+			}
+		case pe := <-result:
+			// Стадия завершилась, не закрыв выход (паника до defer close)
+			if pe != nil {
+				panic(pe)
+			}
+			return
+		}
+	}
+}
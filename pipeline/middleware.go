@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// StageInfo - описание стадии, передаваемое промежуточным обработчикам.
+type StageInfo struct {
+	Index int    // Номер стадии в пайплайне (с 1)
+	Name  string // Имя стадии
+}
+
+// Middleware - промежуточный обработчик: обертка стадии stage, добавляющая
+// общую для всех стадий обработку (журнал, счетчики, перехват паники).
+type Middleware[T any] func(info StageInfo, stage Stage[T]) Stage[T]
+
+// ErrStageTimeout - стадия не завершилась за время, заданное WithTimeout.
+var ErrStageTimeout = errors.New("истекло время работы стадии")
+
+// Apply - стадия stage, обернутая промежуточными обработчиками mws.
+// Первый обработчик - внешний: он первым видит значения на входе стадии
+// и последним - на выходе.
+func Apply[T any](info StageInfo, stage Stage[T], mws ...Middleware[T]) Stage[T] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		stage = mws[i](info, stage)
+	}
+	return stage
+}
+
+// WithHooks - обработчик, вызывающий before для каждого значения до передачи
+// стадии и after - для каждого значения на ее выходе. Любой из них может быть nil.
+// Обработчики одной стадии вызываются из разных горутин.
+func WithHooks[T any](before, after func(info StageInfo, v T)) Middleware[T] {
+	return func(info StageInfo, stage Stage[T]) Stage[T] {
+		onIn, onOut := func(T) {}, func(T) {}
+		if before != nil {
+			onIn = func(v T) { before(info, v) }
+		}
+		if after != nil {
+			onOut = func(v T) { after(info, v) }
+		}
+		return Observe(stage, onIn, onOut)
+	}
+}
+
+// WithMetrics - обработчик, считающий принятые и переданные значения каждой
+// стадии. Счетчики стадии передаются в register при обертывании, до запуска.
+func WithMetrics[T any](register func(info StageInfo, m *StageMetrics)) Middleware[T] {
+	return func(info StageInfo, stage Stage[T]) Stage[T] {
+		m := &StageMetrics{}
+		register(info, m)
+		return Instrument(m, stage)
+	}
+}
+
+// WithLogging - обработчик, записывающий в log запуск стадии (уровень debug)
+// и ее завершение с количеством принятых и переданных значений (info).
+func WithLogging[T any](log *slog.Logger) Middleware[T] {
+	return func(info StageInfo, stage Stage[T]) Stage[T] {
+		log := log.With("stage", info.Name, "index", info.Index)
+		var m StageMetrics
+		stage = Instrument(&m, stage)
+		return func(ctx context.Context, in <-chan T, out chan<- T) {
+			started := time.Now()
+			log.Debug("Стадия запущена")
+			stage(ctx, in, out)
+			log.Info("Стадия завершена", "received", m.Received.Load(), "passed", m.Passed.Load(),
+				"elapsed", time.Since(started), "canceled", ctx.Err() != nil)
+		}
+	}
+}
+
+// WithRecover - обработчик, перезапускающий стадию после паники согласно
+// opts (см. Supervise).
+func WithRecover[T any](opts SuperviseOptions) Middleware[T] {
+	return func(info StageInfo, stage Stage[T]) Stage[T] {
+		return Supervise(info.Name, stage, opts)
+	}
+}
+
+// WithTimeout - обработчик, ограничивающий время работы стадии: по истечении d
+// контекст стадии отменяется, в ReportError передается ErrStageTimeout, а
+// оставшиеся значения входа отбрасываются до его закрытия, чтобы не задерживать
+// предыдущие стадии.
+func WithTimeout[T any](d time.Duration) Middleware[T] {
+	return func(info StageInfo, stage Stage[T]) Stage[T] {
+		return func(ctx context.Context, in <-chan T, out chan<- T) {
+			defer close(out)
+			stageCtx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			// Выход закрывается после передачи ошибки
+			relayStage(ctx, stageCtx, stage, in, out, func(T) {})
+			if ctx.Err() == nil && errors.Is(stageCtx.Err(), context.DeadlineExceeded) {
+				ReportError(ctx, fmt.Errorf("стадия %s: %w (%s)", info.Name, ErrStageTimeout, d))
+				discard(ctx, in)
+			}
+		}
+	}
+}
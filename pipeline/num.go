@@ -0,0 +1,335 @@
+package pipeline
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// numKind - представление значения Num.
+type numKind uint8
+
+// Представления Num.
+const (
+	numInt   numKind = iota // Целое в пределах int64
+	numBig                  // Целое вне int64 (десятичная запись)
+	numFloat                // Число с плавающей точкой
+)
+
+// Num - число пайплайна: целое произвольной величины или float64.
+//
+// Целые в пределах int64 хранятся без выделения памяти, большие - в
+// десятичной записи, поэтому у равных целых одно представление, а Num можно
+// сравнивать оператором == и использовать как ключ map. Арифметика целых
+// выполняется без переполнения (результат вне int64 становится большим
+// целым), операция с числом с плавающей точкой дает число с плавающей точкой.
+// Нулевое значение - целое 0.
+type Num struct {
+	kind numKind
+	i    int64
+	f    float64
+	big  string
+}
+
+// ErrInvalidNum - строка не является числом.
+var ErrInvalidNum = errors.New("ожидается число")
+
+// IntNum - целое n.
+func IntNum(n int64) Num { return Num{i: n} }
+
+// FloatNum - число с плавающей точкой f.
+func FloatNum(f float64) Num { return Num{kind: numFloat, f: f} }
+
+// BigNum - целое b (значение b копируется).
+func BigNum(b *big.Int) Num {
+	if b.IsInt64() {
+		return IntNum(b.Int64())
+	}
+	return Num{kind: numBig, big: b.String()}
+}
+
+// NumOf - значение v любого числового типа как Num.
+func NumOf[T Number](v T) Num {
+	var one, zero T = 1, 0
+	switch {
+	case one/2*2 == one: // Деление без остатка только у типов с плавающей точкой
+		return FloatNum(float64(v))
+	case zero-1 < zero || uint64(v) <= math.MaxInt64: // Знаковый тип или значение в пределах int64
+		return IntNum(int64(v))
+	}
+	return BigNum(new(big.Int).SetUint64(uint64(v)))
+}
+
+// ParseNum - разбор десятичной записи числа: целого любой величины
+// (например, 12345678901234567890) или с плавающей точкой (1.5, -2e10).
+// Бесконечность, NaN и разделители разрядов "_" (допустимые в ParseFloat)
+// не допускаются.
+func ParseNum(s string) (Num, error) {
+	if strings.Contains(s, "_") {
+		return Num{}, fmt.Errorf("%w: %q", ErrInvalidNum, s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return IntNum(n), nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		if b, ok := new(big.Int).SetString(s, 10); ok {
+			return BigNum(b), nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return Num{}, fmt.Errorf("%w: %q", ErrInvalidNum, s)
+	}
+	return FloatNum(f), nil
+}
+
+// ParseNumLine - разбор строки с одним числом (см. ParseNum).
+func ParseNumLine(line string) (Num, error) {
+	return ParseNum(strings.TrimSpace(line))
+}
+
+// IsInt - значение целое (в том числе большое).
+func (n Num) IsInt() bool { return n.kind != numFloat }
+
+// Int64 - значение как int64 (ok = false - не целое или вне int64).
+func (n Num) Int64() (v int64, ok bool) { return n.i, n.kind == numInt }
+
+// Float64 - значение как float64 (большие целые - с округлением).
+func (n Num) Float64() float64 {
+	switch n.kind {
+	case numFloat:
+		return n.f
+	case numBig:
+		f, _ := new(big.Float).SetInt(n.bigInt()).Float64()
+		return f
+	}
+	return float64(n.i)
+}
+
+// BigInt - целое значение как *big.Int (ok = false - не целое).
+func (n Num) BigInt() (b *big.Int, ok bool) {
+	if n.kind == numFloat {
+		return nil, false
+	}
+	return n.bigInt(), true
+}
+
+// bigInt - целое значение как новый *big.Int.
+func (n Num) bigInt() *big.Int {
+	if n.kind == numBig {
+		b, _ := new(big.Int).SetString(n.big, 10)
+		return b
+	}
+	return big.NewInt(n.i)
+}
+
+// bigFloat - точное значение как *big.Float.
+func (n Num) bigFloat() *big.Float {
+	if n.kind == numFloat {
+		return new(big.Float).SetFloat64(n.f)
+	}
+	return new(big.Float).SetInt(n.bigInt())
+}
+
+// Sign - знак значения: -1, 0 или 1.
+func (n Num) Sign() int {
+	switch n.kind {
+	case numFloat:
+		switch {
+		case n.f < 0:
+			return -1
+		case n.f > 0:
+			return 1
+		}
+		return 0
+	case numBig:
+		if n.big[0] == '-' {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case n.i < 0:
+		return -1
+	case n.i > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cmp - сравнение с m: -1, если n < m, 0, если n == m, и 1, если n > m.
+// Целые и числа с плавающей точкой сравниваются точно.
+func (n Num) Cmp(m Num) int {
+	switch {
+	case n.kind == numInt && m.kind == numInt:
+		switch {
+		case n.i < m.i:
+			return -1
+		case n.i > m.i:
+			return 1
+		}
+		return 0
+	case n.IsInt() && m.IsInt():
+		return n.bigInt().Cmp(m.bigInt())
+	case n.kind == numFloat && m.kind == numFloat, n.isNaN() || m.isNaN():
+		return cmp.Compare(n.Float64(), m.Float64())
+	}
+	return n.bigFloat().Cmp(m.bigFloat())
+}
+
+// isNaN - значение NaN (результат операций с бесконечностью).
+func (n Num) isNaN() bool { return n.kind == numFloat && math.IsNaN(n.f) }
+
+// Add - сумма n + m.
+func (n Num) Add(m Num) Num {
+	switch {
+	case n.kind == numFloat || m.kind == numFloat:
+		return FloatNum(n.Float64() + m.Float64())
+	case n.kind == numInt && m.kind == numInt:
+		if s := n.i + m.i; (s > n.i) == (m.i > 0) {
+			return IntNum(s)
+		}
+	}
+	return BigNum(new(big.Int).Add(n.bigInt(), m.bigInt()))
+}
+
+// Sub - разность n - m.
+func (n Num) Sub(m Num) Num {
+	switch {
+	case n.kind == numFloat || m.kind == numFloat:
+		return FloatNum(n.Float64() - m.Float64())
+	case n.kind == numInt && m.kind == numInt:
+		if d := n.i - m.i; (d < n.i) == (m.i > 0) {
+			return IntNum(d)
+		}
+	}
+	return BigNum(new(big.Int).Sub(n.bigInt(), m.bigInt()))
+}
+
+// Mul - произведение n * m.
+func (n Num) Mul(m Num) Num {
+	switch {
+	case n.kind == numFloat || m.kind == numFloat:
+		return FloatNum(n.Float64() * m.Float64())
+	case n.kind == numInt && m.kind == numInt:
+		if n.i == 0 || m.i == 0 {
+			return IntNum(0)
+		}
+		p := n.i * m.i
+		if p/m.i == n.i && !(n.i == -1 && m.i == math.MinInt64) && !(m.i == -1 && n.i == math.MinInt64) {
+			return IntNum(p)
+		}
+	}
+	return BigNum(new(big.Int).Mul(n.bigInt(), m.bigInt()))
+}
+
+// Quo - частное n / m: для целых - с округлением к нулю, как для int.
+// Деление целого на ноль приводит к панике.
+func (n Num) Quo(m Num) Num {
+	switch {
+	case n.kind == numFloat || m.kind == numFloat:
+		return FloatNum(n.Float64() / m.Float64())
+	case n.kind == numInt && m.kind == numInt && !(n.i == math.MinInt64 && m.i == -1):
+		return IntNum(n.i / m.i)
+	}
+	return BigNum(new(big.Int).Quo(n.bigInt(), m.bigInt()))
+}
+
+// Rem - остаток n % m со знаком делимого, как для int (для чисел с
+// плавающей точкой - math.Mod). Деление целого на ноль приводит к панике.
+func (n Num) Rem(m Num) Num {
+	switch {
+	case n.kind == numFloat || m.kind == numFloat:
+		return FloatNum(math.Mod(n.Float64(), m.Float64()))
+	case n.kind == numInt && m.kind == numInt:
+		return IntNum(n.i % m.i)
+	}
+	return BigNum(new(big.Int).Rem(n.bigInt(), m.bigInt()))
+}
+
+// Neg - значение с противоположным знаком.
+func (n Num) Neg() Num {
+	switch {
+	case n.kind == numFloat:
+		return FloatNum(-n.f)
+	case n.kind == numInt && n.i != math.MinInt64:
+		return IntNum(-n.i)
+	}
+	return BigNum(new(big.Int).Neg(n.bigInt()))
+}
+
+// Abs - абсолютная величина.
+func (n Num) Abs() Num {
+	if n.Sign() < 0 {
+		return n.Neg()
+	}
+	return n
+}
+
+// String - десятичная запись без потери точности: целые выводятся
+// полностью, числа с плавающей точкой - кратчайшей записью, при разборе
+// которой получается то же число, и всегда с точкой или порядком (2.0, 1e+21).
+func (n Num) String() string {
+	switch n.kind {
+	case numFloat:
+		s := strconv.FormatFloat(n.f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	case numBig:
+		return n.big
+	}
+	return strconv.FormatInt(n.i, 10)
+}
+
+// MarshalText - десятичная запись (см. String).
+func (n Num) MarshalText() ([]byte, error) {
+	if n.kind == numFloat && (math.IsInf(n.f, 0) || math.IsNaN(n.f)) {
+		return nil, fmt.Errorf("число %v не представимо в JSON", n.f)
+	}
+	return []byte(n.String()), nil
+}
+
+// UnmarshalText - разбор десятичной записи (см. ParseNum).
+func (n *Num) UnmarshalText(text []byte) error {
+	v, err := ParseNum(string(text))
+	if err != nil {
+		return err
+	}
+	*n = v
+	return nil
+}
+
+// MarshalBinary - десятичная запись (для gob, см. MarshalText).
+func (n Num) MarshalBinary() ([]byte, error) {
+	return n.MarshalText()
+}
+
+// UnmarshalBinary - разбор записи MarshalBinary.
+func (n *Num) UnmarshalBinary(data []byte) error {
+	return n.UnmarshalText(data)
+}
+
+// MarshalJSON - запись числом JSON без потери точности.
+func (n Num) MarshalJSON() ([]byte, error) {
+	return n.MarshalText()
+}
+
+// UnmarshalJSON - разбор числа JSON или строки с числом
+// (большие целые часто передаются строкой).
+func (n *Num) UnmarshalJSON(data []byte) error {
+	if s := string(data); strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidNum, s)
+		}
+		data = []byte(unquoted)
+	}
+	return n.UnmarshalText(data)
+}
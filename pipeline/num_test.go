@@ -0,0 +1,34 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNum(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"42", "42"},
+		{"-7", "-7"},
+		{"12345678901234567890", "12345678901234567890"},
+		{"1.5", "1.5"},
+		{"-2e10", "-2e+10"},
+	} {
+		n, err := ParseNum(tc.in)
+		if err != nil {
+			t.Fatalf("ParseNum(%q): %v", tc.in, err)
+		}
+		if got := n.String(); got != tc.want {
+			t.Errorf("ParseNum(%q) = %s, ожидалось %s", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseNumRejects(t *testing.T) {
+	for _, in := range []string{"", "abc", "1_000", "1_000.5", "1e1_0", "Inf", "-inf", "NaN", "1e400"} {
+		if n, err := ParseNum(in); !errors.Is(err, ErrInvalidNum) {
+			t.Errorf("ParseNum(%q) = %v, %v; ожидалась ErrInvalidNum", in, n, err)
+		}
+	}
+}
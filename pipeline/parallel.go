@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// ItemFunc - обработка одного значения: результат и признак его передачи дальше.
+// При ошибке значение отбрасывается, ошибка передается в ReportError
+// (*Rejection - в Reject).
+type ItemFunc[T any] func(v T) (T, bool, error)
+
+// ItemStage - стадия пайплайна, применяющая fn к каждому значению.
+func ItemStage[T any](fn ItemFunc[T]) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					return
+				}
+				v, pass, err := fn(n)
+				if err != nil {
+					reportItemError(ctx, err)
+					continue
+				}
+				if pass && !send(ctx, out, v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Parallel - запуск n экземпляров стадии stage над общим входом с объединением
+// их выходов. Порядок значений не сохраняется; стадия не должна хранить
+// состояние между значениями (фильтры, преобразования).
+func Parallel[T any](stage Stage[T], n int) Stage[T] {
+	if n <= 1 {
+		return stage
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		var wg sync.WaitGroup
+		for range n {
+			workerOut := make(chan T)
+			go stage(ctx, in, workerOut)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for v := range workerOut {
+					if !send(ctx, out, v) {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// sequenced - значение с порядковым номером на входе.
+type sequenced[T any] struct {
+	seq  uint64
+	v    T
+	pass bool
+	err  error
+}
+
+// ParallelOrdered - обработка значений функцией fn в n горутинах с сохранением
+// порядка входа. Значения нумеруются, результаты выдаются по возрастанию номеров;
+// одновременно в обработке находится не более 2n значений.
+func ParallelOrdered[T any](fn ItemFunc[T], n int) Stage[T] {
+	if n <= 1 {
+		return ItemStage(fn)
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		jobs := make(chan sequenced[T])
+		results := make(chan sequenced[T], n)
+		window := make(chan struct{}, 2*n) // Ограничение числа значений в обработке
+
+		go func() {
+			defer close(jobs)
+			var seq uint64
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return
+					}
+					select {
+					case window <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+					if !send(ctx, jobs, sequenced[T]{seq: seq, v: v}) {
+						return
+					}
+					seq++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range jobs {
+					job.v, job.pass, job.err = fn(job.v)
+					if !send(ctx, results, job) {
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		// Выдача результатов по порядку номеров
+		pending := make(map[uint64]sequenced[T])
+		var next uint64
+		for r := range results {
+			pending[r.seq] = r
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				<-window
+				if r.err != nil {
+					reportItemError(ctx, r.err)
+					continue
+				}
+				if r.pass && !send(ctx, out, r.v) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package pipeline - обобщенный пайплайн обработки данных: цепочка стадий,
+// соединенных каналами, и кольцевой буфер с периодической отправкой данных.
+//
+// Пример использования:
+//
+//	p := pipeline.New(
+//		pipeline.FilterNegative[int],
+//		pipeline.FilterNotDivisibleBy3[int],
+//		pipeline.NewBuffer[int](pipeline.DefaultBufferSize, pipeline.DefaultFlushInterval),
+//	)
+//	out := p.Run(ctx, input)
+//
+// Завершение работы: закрытие входного канала передается по цепочке - каждая
+// стадия дорабатывает принятые значения и закрывает свой выход, поэтому цикл
+// for range по выходу пайплайна завершается после исчерпания источника.
+// Отмена ctx останавливает стадии без отправки оставшихся данных.
+package pipeline
+
+import "context"
+
+// Pipeline - последовательная цепочка стадий.
+type Pipeline[T any] struct {
+	stages  []Stage[T]
+	chanCap int
+}
+
+// New - создание пайплайна из стадий, выполняемых в указанном порядке.
+func New[T any](stages ...Stage[T]) *Pipeline[T] {
+	return &Pipeline[T]{stages: append([]Stage[T](nil), stages...)}
+}
+
+// WithChanCap - задание емкости каналов между стадиями (0 - небуферизованные).
+func (p *Pipeline[T]) WithChanCap(n int) *Pipeline[T] {
+	p.chanCap = n
+	return p
+}
+
+// Run - запуск стадий пайплайна над источником in.
+// Каждая стадия выполняется в отдельной горутине. Возвращает выход последней стадии.
+func (p *Pipeline[T]) Run(ctx context.Context, in <-chan T) <-chan T {
+	return ChainCap(ctx, in, p.chanCap, p.stages...)
+}
+
+// Stage - пайплайн как одна стадия (для вложения в другие пайплайны).
+func (p *Pipeline[T]) Stage() Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		for n := range p.Run(ctx, in) {
+			if !send(ctx, out, n) {
+				return
+			}
+		}
+	}
+}
+
+// Chain - последовательный запуск стадий. Возвращает выход последней стадии.
+func Chain[T any](ctx context.Context, in <-chan T, stages ...Stage[T]) <-chan T {
+	return ChainCap(ctx, in, 0, stages...)
+}
+
+// ChainCap - последовательный запуск стадий, соединенных каналами емкости capacity.
+func ChainCap[T any](ctx context.Context, in <-chan T, capacity int, stages ...Stage[T]) <-chan T {
+	for _, stage := range stages {
+		out := make(chan T, capacity)
+		go stage(ctx, in, out)
+		in = out
+	}
+	return in
+}
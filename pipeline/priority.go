@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Режимы отправки значений с высоким приоритетом стадией буферизации.
+const (
+	PriorityImmediate = "immediate" // Значения с приоритетом > 0 отправляются сразу, минуя буфер
+	PriorityFirst     = "first"     // Буфер отправляет значения по убыванию приоритета
+)
+
+// ValidatePriorityMode - проверка имени режима приоритетов.
+func ValidatePriorityMode(mode string) error {
+	switch mode {
+	case PriorityImmediate, PriorityFirst:
+		return nil
+	}
+	return fmt.Errorf("неизвестный режим приоритетов: %q (ожидается %s или %s)", mode, PriorityImmediate, PriorityFirst)
+}
+
+// prioritized - значение буфера с приоритетом.
+type prioritized[T any] struct {
+	v        T
+	priority int
+}
+
+// priorityBuffer - хранилище стадии буферизации с учетом приоритетов:
+// при отправке значения упорядочиваются по убыванию приоритета (с равным
+// приоритетом - в порядке поступления), при переполнении с политикой
+// overwrite вытесняется самое старое значение с наименьшим приоритетом.
+type priorityBuffer[T any] struct {
+	items    []prioritized[T] // В порядке поступления
+	size     int
+	policy   string
+	classify func(v T) int
+	onEvict  func(v T)
+}
+
+// newPriorityBuffer - хранилище емкости size с политикой policy
+// и функцией приоритета classify.
+func newPriorityBuffer[T any](size int, policy string, classify func(v T) int, onEvict func(v T)) *priorityBuffer[T] {
+	return &priorityBuffer[T]{items: make([]prioritized[T], 0, size), size: size, policy: policy, classify: classify, onEvict: onEvict}
+}
+
+// Push - добавление значения. Для политики block стадия отправляет
+// заполненный буфер заранее, поэтому переполнение как при drop-newest.
+func (b *priorityBuffer[T]) Push(v T) {
+	it := prioritized[T]{v: v, priority: b.classify(v)}
+	if len(b.items) < b.size {
+		b.items = append(b.items, it)
+		return
+	}
+	evicted := v
+	// Новое значение вытесняет старое, только если его приоритет не ниже
+	if i := b.lowest(); b.policy == OverflowOverwrite && b.items[i].priority <= it.priority {
+		evicted = b.items[i].v
+		b.items = append(slices.Delete(b.items, i, i+1), it)
+	}
+	if b.onEvict != nil {
+		b.onEvict(evicted)
+	}
+}
+
+// lowest - индекс самого старого значения с наименьшим приоритетом.
+func (b *priorityBuffer[T]) lowest() int {
+	low := 0
+	for i, it := range b.items {
+		if it.priority < b.items[low].priority {
+			low = i
+		}
+	}
+	return low
+}
+
+// Full - буфер заполнен.
+func (b *priorityBuffer[T]) Full() bool {
+	return len(b.items) == b.size
+}
+
+// Len - количество значений в буфере.
+func (b *priorityBuffer[T]) Len() int {
+	return len(b.items)
+}
+
+// Flush - извлечение всех значений по убыванию приоритета (nil - буфер пуст).
+func (b *priorityBuffer[T]) Flush() []T {
+	if len(b.items) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(b.items, func(x, y prioritized[T]) int { return cmp.Compare(y.priority, x.priority) })
+	data := make([]T, len(b.items))
+	for i, it := range b.items {
+		data[i] = it.v
+	}
+	b.items = b.items[:0]
+	return data
+}
+
+// Peek - копия не более n самых старых значений без удаления.
+func (b *priorityBuffer[T]) Peek(n int) []T {
+	data := make([]T, 0, min(max(n, 0), len(b.items)))
+	for _, it := range b.items[:cap(data)] {
+		data = append(data, it.v)
+	}
+	return data
+}
+
+// Resize - изменение емкости; не поместившиеся значения с наименьшим
+// приоритетом (самые старые из них) удаляются и возвращаются.
+func (b *priorityBuffer[T]) Resize(size int) []T {
+	var removed []T
+	for len(b.items) > size {
+		i := b.lowest()
+		removed = append(removed, b.items[i].v)
+		b.items = slices.Delete(b.items, i, i+1)
+	}
+	b.size = size
+	return removed
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Queue - наблюдаемая очередь ограниченной емкости на входе стадии (см. WithQueue).
+// Заполненная очередь означает, что стадия (или стадии после нее) не успевает
+// обрабатывать вход. При достижении High вызывается OnHigh, при последующем
+// снижении до Low - OnLow. Обработчики вызываются из горутин стадии.
+type Queue struct {
+	Cap    int             // Емкость очереди
+	High   int             // Верхняя отметка заполненности (0 - Cap)
+	Low    int             // Нижняя отметка заполненности (0 - половина Cap)
+	OnHigh func(depth int) // Достигнута верхняя отметка
+	OnLow  func(depth int) // Заполненность снизилась до нижней отметки
+
+	depth atomic.Int64
+	peak  atomic.Int64
+	high  atomic.Bool
+}
+
+// Depth - текущее количество значений в очереди.
+func (q *Queue) Depth() int {
+	return int(max(q.depth.Load(), 0))
+}
+
+// TakePeak - наибольшая заполненность с предыдущего вызова TakePeak.
+func (q *Queue) TakePeak() int {
+	return int(q.peak.Swap(int64(q.Depth())))
+}
+
+// Congested - достигнута верхняя отметка и заполненность еще не снизилась до нижней.
+func (q *Queue) Congested() bool {
+	return q.high.Load()
+}
+
+// observe - учет новой заполненности d и вызов обработчиков отметок.
+func (q *Queue) observe(d int64) {
+	for {
+		p := q.peak.Load()
+		if d <= p || q.peak.CompareAndSwap(p, d) {
+			break
+		}
+	}
+	high, low := q.High, q.Low
+	if high <= 0 {
+		high = q.Cap
+	}
+	if low <= 0 {
+		low = q.Cap / 2
+	}
+	switch {
+	case d >= int64(high) && q.high.CompareAndSwap(false, true):
+		if q.OnHigh != nil {
+			q.OnHigh(int(d))
+		}
+	case d <= int64(low) && q.high.CompareAndSwap(true, false):
+		if q.OnLow != nil {
+			q.OnLow(int(max(d, 0)))
+		}
+	}
+}
+
+// WithQueue - стадия stage с очередью q на входе. Значения принимаются, пока
+// в очереди есть место; заполненность доступна через q. При q.Cap <= 0
+// стадия возвращается без изменений.
+func WithQueue[T any](q *Queue, stage Stage[T]) Stage[T] {
+	if q.Cap <= 0 {
+		return stage
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		queue := make(chan T, q.Cap)
+		stageIn := make(chan T)
+		go stage(ctx, stageIn, out)
+		go func() {
+			defer close(stageIn)
+			for v := range queue {
+				q.observe(q.depth.Add(-1))
+				if !send(ctx, stageIn, v) {
+					return
+				}
+			}
+		}()
+		defer close(queue)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				if !send(ctx, queue, v) {
+					return
+				}
+				q.observe(q.depth.Add(1))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate - ограничение пропускной способности: N значений за период Per.
+type Rate struct {
+	N   int
+	Per time.Duration
+}
+
+// String - запись ограничения в виде N/период (например, 100/s).
+func (r Rate) String() string {
+	switch r.Per {
+	case time.Second:
+		return fmt.Sprintf("%d/s", r.N)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", r.N)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", r.N)
+	}
+	return fmt.Sprintf("%d/%s", r.N, r.Per)
+}
+
+// interval - интервал пополнения маркера.
+func (r Rate) interval() time.Duration {
+	return r.Per / time.Duration(r.N)
+}
+
+// ParseRate - разбор ограничения вида N/период: 100/s, 600/m, 10/h или 5/250ms.
+func ParseRate(s string) (Rate, error) {
+	n, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("ожидается ограничение вида N/период (например, 100/s), получено %q", s)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil || count <= 0 {
+		return Rate{}, fmt.Errorf("количество значений должно быть положительным целым: %q", n)
+	}
+	r := Rate{N: count}
+	switch per = strings.TrimSpace(per); per {
+	case "s":
+		r.Per = time.Second
+	case "m":
+		r.Per = time.Minute
+	case "h":
+		r.Per = time.Hour
+	default:
+		if r.Per, err = time.ParseDuration(per); err != nil || r.Per <= 0 {
+			return Rate{}, fmt.Errorf("некорректный период %q (ожидается s, m, h или длительность)", per)
+		}
+	}
+	if r.interval() <= 0 {
+		return Rate{}, fmt.Errorf("слишком высокое ограничение: %s", s)
+	}
+	return r, nil
+}
+
+// RateLimit - стадия, ограничивающая пропускную способность алгоритмом маркерной
+// корзины: маркеры пополняются со скоростью rate, одновременно накапливается не
+// более burst маркеров (burst <= 0 - один). Значение без маркера ожидает его
+// появления, задерживая вход; значения не отбрасываются.
+func RateLimit[T any](rate Rate, burst int, clock Clock) Stage[T] {
+	if burst <= 0 {
+		burst = 1
+	}
+	interval := rate.interval()
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		tokens := burst
+		last := clock.Now() // Время последнего начисления маркеров
+		timer := clock.NewTimer(interval)
+		timer.Stop()
+		defer timer.Stop()
+
+		// refill - начисление маркеров за время, прошедшее с last
+		refill := func() {
+			now := clock.Now()
+			if n := int(now.Sub(last) / interval); n > 0 {
+				tokens = min(burst, tokens+n)
+				last = last.Add(time.Duration(n) * interval)
+			}
+			if tokens == burst {
+				last = now // Полная корзина не копит маркеры впрок
+			}
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				refill()
+				for tokens == 0 {
+					timer.Reset(interval - clock.Now().Sub(last))
+					select {
+					case <-timer.C():
+						refill()
+					case <-ctx.Done():
+						return
+					}
+				}
+				tokens--
+				if !send(ctx, out, v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrChainStopped - цепочка стадий уже завершила работу.
+var ErrChainStopped = errors.New("цепочка стадий остановлена")
+
+// ReloadableChain - стадия-обертка над цепочкой стадий, которую можно
+// заменить во время работы без потери входных данных.
+type ReloadableChain[T any] struct {
+	stages   []Stage[T]
+	chanCap  int
+	reload   chan reloadRequest[T]
+	stopped  chan struct{}
+	received atomic.Uint64 // Значения, принятые цепочкой
+	emitted  atomic.Uint64 // Значения, выданные цепочкой
+}
+
+// ChainPosition - количество значений, принятых и выданных цепочкой.
+type ChainPosition struct {
+	Received uint64
+	Emitted  uint64
+}
+
+// reloadRequest - запрос на замену цепочки.
+type reloadRequest[T any] struct {
+	stages []Stage[T]
+	keep   bool           // Перезапуск с текущими стадиями (Checkpoint)
+	pos    *ChainPosition // Позиция после доработки старой цепочки
+	done   chan struct{}
+}
+
+// NewReloadableChain - создание заменяемой цепочки из стадий stages.
+func NewReloadableChain[T any](stages ...Stage[T]) *ReloadableChain[T] {
+	return &ReloadableChain[T]{
+		stages:  append([]Stage[T](nil), stages...),
+		reload:  make(chan reloadRequest[T]),
+		stopped: make(chan struct{}),
+	}
+}
+
+// WithChanCap - задание емкости каналов между стадиями (0 - небуферизованные).
+func (c *ReloadableChain[T]) WithChanCap(n int) *ReloadableChain[T] {
+	c.chanCap = n
+	return c
+}
+
+// Reload - замена активной цепочки на stages. Старая цепочка дорабатывает
+// уже принятые значения, после чего вход переключается на новую.
+func (c *ReloadableChain[T]) Reload(stages ...Stage[T]) error {
+	return c.request(reloadRequest[T]{stages: append([]Stage[T](nil), stages...)})
+}
+
+// Checkpoint - точка согласования: активная цепочка дорабатывает принятые
+// значения (буферы отправляют остаток) и запускается заново с теми же стадиями.
+// Возвращает позицию, в которой все принятые значения выданы или отброшены.
+func (c *ReloadableChain[T]) Checkpoint() (ChainPosition, error) {
+	var pos ChainPosition
+	err := c.request(reloadRequest[T]{keep: true, pos: &pos})
+	return pos, err
+}
+
+// Position - текущая позиция цепочки; после завершения Run - итоговая.
+func (c *ReloadableChain[T]) Position() ChainPosition {
+	return ChainPosition{Received: c.received.Load(), Emitted: c.emitted.Load()}
+}
+
+// request - передача запроса на замену цепочки и ожидание его выполнения.
+func (c *ReloadableChain[T]) request(req reloadRequest[T]) error {
+	req.done = make(chan struct{})
+	select {
+	case c.reload <- req:
+	case <-c.stopped:
+		return ErrChainStopped
+	}
+	select {
+	case <-req.done:
+		return nil
+	case <-c.stopped:
+		return ErrChainStopped
+	}
+}
+
+// Run - стадия пайплайна (совместима с Stage).
+func (c *ReloadableChain[T]) Run(ctx context.Context, in <-chan T, out chan<- T) {
+	var forwarded sync.WaitGroup
+	defer close(out)
+	defer close(c.stopped)
+	// Выход закрывается после передачи: при отмене ctx передача еще может
+	// отправлять значение в out
+	defer forwarded.Wait()
+
+	start := func(stages []Stage[T]) chan<- T {
+		chainIn := make(chan T, c.chanCap)
+		chainOut := ChainCap(ctx, chainIn, c.chanCap, stages...)
+		forwarded.Add(1)
+		go func() {
+			defer forwarded.Done()
+			for n := range chainOut {
+				if !send(ctx, out, n) {
+					return
+				}
+				c.emitted.Add(1)
+			}
+		}()
+		return chainIn
+	}
+	// drain - закрытие входа цепочки и ожидание выхода всех ее значений.
+	drain := func(chainIn chan<- T) {
+		close(chainIn)
+		forwarded.Wait()
+	}
+
+	stages := c.stages
+	chainIn := start(stages)
+	for {
+		select {
+		case n, ok := <-in:
+			if !ok {
+				drain(chainIn)
+				return
+			}
+			if !send(ctx, chainIn, n) {
+				return
+			}
+			c.received.Add(1)
+		case req := <-c.reload:
+			drain(chainIn)
+			if req.pos != nil {
+				*req.pos = c.Position()
+			}
+			if !req.keep {
+				stages = req.stages
+			}
+			chainIn = start(stages)
+			close(req.done)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
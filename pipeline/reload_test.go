@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// scaleStage - стадия умножения значений на k.
+func scaleStage(k int) Stage[int] {
+	return ItemStage(func(v int) (int, bool, error) { return v * k, true, nil })
+}
+
+func TestReloadableChainMidStream(t *testing.T) {
+	const total = 10000
+	ctx := testContext(t)
+	chain := NewReloadableChain(scaleStage(1)).WithChanCap(8)
+	in := make(chan int)
+	out := make(chan int)
+	go chain.Run(ctx, in, out)
+	go func() {
+		defer close(in)
+		for i := 1; i <= total; i++ {
+			in <- i
+		}
+	}()
+
+	var got []int
+	reloaded := make(chan struct{})
+	var switchAt uint64
+	for v := range out {
+		got = append(got, v)
+		if len(got) == total/2 {
+			go func() {
+				defer close(reloaded)
+				if err := chain.Reload(scaleStage(-1)); err != nil {
+					t.Errorf("Reload: %v", err)
+				}
+				// Не меньше принятых старой цепочкой
+				switchAt = chain.Position().Received
+			}()
+		}
+	}
+	<-reloaded
+
+	if len(got) != total {
+		t.Fatalf("на выходе %d значений, ожидалось %d: значения потеряны при замене", len(got), total)
+	}
+	// Старая цепочка дорабатывает принятое до переключения: порядок сохраняется,
+	// значения до точки переключения - положительные, после - отрицательные
+	switched := 0
+	for i, v := range got {
+		want := i + 1
+		if v < 0 {
+			switched++
+			want = -want
+		} else if switched > 0 {
+			t.Fatalf("значение %d старой цепочки после переключения", v)
+		}
+		if v != want {
+			t.Fatalf("значение %d: %d, ожидалось %d", i, v, want)
+		}
+	}
+	if switched == 0 || uint64(total-switched) > switchAt {
+		t.Fatalf("новая цепочка обработала %d значений, переключение после %d принятых", switched, switchAt)
+	}
+	pos := chain.Position()
+	if pos.Received != total || pos.Emitted != total {
+		t.Fatalf("позиция %+v, ожидалось %d принятых и выданных", pos, total)
+	}
+}
+
+func TestReloadableChainCheckpoint(t *testing.T) {
+	ctx := testContext(t)
+	chain := NewReloadableChain(scaleStage(2))
+	in := make(chan int)
+	out := make(chan int, 10)
+	go chain.Run(ctx, in, out)
+	in <- 1
+	in <- 2
+	pos, err := chain.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if pos.Received != 2 || pos.Emitted != 2 {
+		t.Fatalf("позиция %+v, ожидалось 2 принятых и выданных", pos)
+	}
+	close(in)
+	var got []int
+	for v := range out {
+		got = append(got, v)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 4 {
+		t.Fatalf("выход %v, ожидалось [2 4]", got)
+	}
+	if err := chain.Reload(scaleStage(1)); err != ErrChainStopped {
+		t.Fatalf("Reload остановленной цепочки: %v, ожидалось ErrChainStopped", err)
+	}
+}
+
+// TestReloadableChainCancelUnreadOutput - отмена при непрочитанном выходе:
+// выход закрывается только после завершения передачи значений цепочки.
+func TestReloadableChainCancelUnreadOutput(t *testing.T) {
+	for range 500 {
+		ctx, cancel := context.WithCancel(context.Background())
+		// Стадия сообщает, что передача приняла значение и ждет выхода
+		forwarding := make(chan struct{})
+		stage := func(ctx context.Context, in <-chan int, out chan<- int) {
+			defer close(out)
+			for v := range in {
+				if !send(ctx, out, v) {
+					return
+				}
+				close(forwarding)
+			}
+		}
+		chain := NewReloadableChain[int](stage)
+		in := make(chan int, 1)
+		out := make(chan int)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			chain.Run(ctx, in, out)
+		}()
+		in <- 1
+		<-forwarding
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(testTimeout):
+			t.Fatal("Run не завершился после отмены")
+		}
+		for range out {
+		}
+	}
+}
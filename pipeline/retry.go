@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Параметры повтора записи в приемник по умолчанию.
+const (
+	DefaultRetryAttempts   = 5
+	DefaultRetryMinBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 10 * time.Second
+)
+
+// retryPending - количество неподтвержденных Flush значений, после которого
+// RetrySink сбрасывает приемник сам, ограничивая повторяемую партию.
+const retryPending = 1024
+
+// RetryPolicy - политика повтора записи в приемник.
+type RetryPolicy struct {
+	MaxAttempts int           // Наибольшее количество попыток, включая первую (0 - DefaultRetryAttempts)
+	MinBackoff  time.Duration // Ожидание перед первым повтором (0 - DefaultRetryMinBackoff)
+	MaxBackoff  time.Duration // Наибольшее ожидание (0 - DefaultRetryMaxBackoff)
+	Jitter      float64       // Случайное отклонение ожидания: доля от 0 до 1 (0 - без отклонения)
+	Clock       Clock         // Источник времени (nil - RealClock)
+	// OnRetry вызывается перед каждым повтором с его номером (с 1), ошибкой
+	// и ожиданием. Может быть nil.
+	OnRetry func(retry int, err error, wait time.Duration)
+}
+
+// Validate - проверка параметров политики.
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.MaxAttempts < 0:
+		return fmt.Errorf("количество попыток не может быть отрицательным: %d", p.MaxAttempts)
+	case p.MinBackoff < 0 || p.MaxBackoff < 0:
+		return fmt.Errorf("ожидание не может быть отрицательным")
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("отклонение ожидания должно быть от 0 до 1: %g", p.Jitter)
+	}
+	return nil
+}
+
+// Resetter - приемник, накапливающий значения до Flush, который может
+// отбросить еще не отправленные значения (например, перед повторной записью
+// партии).
+type Resetter interface {
+	Reset()
+}
+
+// RetrySink - приемник, повторяющий запись в sink с экспоненциальным
+// ожиданием. Партия - значения, записанные после последнего успешного Flush;
+// при ошибке Write или Flush неотправленные значения sink отбрасываются
+// (если он реализует Resetter), и партия записывается заново с Flush,
+// поэтому значения партии, выведенные до ошибки, могут быть выведены повторно.
+// После MaxAttempts неудачных попыток партия передается onExhausted
+// (например, в файл недоставленных значений) и запись продолжается;
+// без onExhausted возвращается последняя ошибка. Ожидание прерывается
+// при отмене ctx.
+type RetrySink[T any] struct {
+	ctx         context.Context
+	sink        Sink[T]
+	policy      RetryPolicy
+	onExhausted func(batch []T, err error)
+	pending     []T // Партия с последнего успешного Flush
+}
+
+// NewRetrySink - приемник, повторяющий запись в sink согласно policy.
+// onExhausted получает партии, не записанные за все попытки (может быть nil).
+func NewRetrySink[T any](ctx context.Context, sink Sink[T], policy RetryPolicy, onExhausted func(batch []T, err error)) *RetrySink[T] {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultRetryAttempts
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = DefaultRetryMinBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if policy.Clock == nil {
+		policy.Clock = RealClock{}
+	}
+	return &RetrySink[T]{ctx: ctx, sink: sink, policy: policy, onExhausted: onExhausted}
+}
+
+// Write - запись значения v с повтором партии при ошибке.
+func (s *RetrySink[T]) Write(v T) error {
+	s.pending = append(s.pending, v)
+	if err := s.sink.Write(v); err != nil {
+		return s.retry(err)
+	}
+	if len(s.pending) >= retryPending {
+		return s.Flush()
+	}
+	return nil
+}
+
+// Flush - сброс приемника с повтором партии при ошибке.
+func (s *RetrySink[T]) Flush() error {
+	if err := s.sink.Flush(); err != nil {
+		return s.retry(err)
+	}
+	s.pending = s.pending[:0]
+	return nil
+}
+
+// Reset - отбрасывание партии, в том числе неотправленных значений sink.
+func (s *RetrySink[T]) Reset() {
+	s.pending = s.pending[:0]
+	if r, ok := s.sink.(Resetter); ok {
+		r.Reset()
+	}
+}
+
+// retry - повторная запись партии после ошибки err первой попытки.
+func (s *RetrySink[T]) retry(err error) error {
+	backoff := s.policy.MinBackoff
+	for attempt := 1; attempt < s.policy.MaxAttempts; attempt++ {
+		wait := backoff
+		if s.policy.Jitter > 0 {
+			wait += time.Duration((rand.Float64()*2 - 1) * s.policy.Jitter * float64(backoff))
+		}
+		if s.policy.OnRetry != nil {
+			s.policy.OnRetry(attempt, err, wait)
+		}
+		timer := s.policy.Clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-s.ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff = min(backoff*2, s.policy.MaxBackoff)
+
+		if err = s.rewrite(); err == nil {
+			s.pending = s.pending[:0]
+			return nil
+		}
+	}
+	if s.onExhausted == nil {
+		return err
+	}
+	batch := s.pending
+	s.pending = nil
+	if r, ok := s.sink.(Resetter); ok {
+		r.Reset()
+	}
+	s.onExhausted(batch, err)
+	return nil
+}
+
+// rewrite - запись партии заново и сброс приемника.
+func (s *RetrySink[T]) rewrite() error {
+	if r, ok := s.sink.(Resetter); ok {
+		r.Reset()
+	}
+	for _, v := range s.pending {
+		if err := s.sink.Write(v); err != nil {
+			return err
+		}
+	}
+	return s.sink.Flush()
+}
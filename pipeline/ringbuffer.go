@@ -0,0 +1,330 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"sync"
+)
+
+// Политики переполнения кольцевого буфера.
+const (
+	OverflowOverwrite  = "overwrite"   // Вытеснение самого старого элемента
+	OverflowDropNewest = "drop-newest" // Отбрасывание добавляемого элемента
+	OverflowBlock      = "block"       // Ожидание освобождения места
+)
+
+// ValidateOverflow - проверка имени политики переполнения.
+func ValidateOverflow(policy string) error {
+	switch policy {
+	case OverflowOverwrite, OverflowDropNewest, OverflowBlock:
+		return nil
+	}
+	return fmt.Errorf("неизвестная политика переполнения: %q (ожидается %s, %s или %s)",
+		policy, OverflowOverwrite, OverflowDropNewest, OverflowBlock)
+}
+
+// RingBuffer - структура для кольцевого буфера элементов типа T.
+type RingBuffer[T any] struct {
+	data    []T
+	head    int
+	tail    int
+	count   int
+	size    int
+	policy  string
+	dropped uint64
+	onEvict func(v T)
+	mu      sync.Mutex
+	space   *sync.Cond // Сигнал об освобождении места (политика block)
+}
+
+// NewRingBuffer - создание нового кольцевого буфера с вытеснением старых данных.
+func NewRingBuffer[T any](size int) *RingBuffer[T] {
+	return NewRingBufferPolicy[T](size, OverflowOverwrite)
+}
+
+// NewRingBufferPolicy - создание кольцевого буфера с политикой переполнения policy.
+// Неизвестная политика приводит к панике (см. ValidateOverflow).
+func NewRingBufferPolicy[T any](size int, policy string) *RingBuffer[T] {
+	if err := ValidateOverflow(policy); err != nil {
+		panic(err)
+	}
+	rb := &RingBuffer[T]{
+		data:   make([]T, size),
+		size:   size,
+		policy: policy,
+	}
+	rb.space = sync.NewCond(&rb.mu)
+	return rb
+}
+
+// OnEvict - задание обработчика значений, потерянных при переполнении.
+// Вызывается вне блокировки буфера.
+func (rb *RingBuffer[T]) OnEvict(fn func(v T)) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.onEvict = fn
+}
+
+// Push - добавление элемента в буфер. При переполнении поведение определяется
+// политикой: вытеснение старого элемента, отбрасывание нового или ожидание Flush.
+func (rb *RingBuffer[T]) Push(val T) {
+	rb.mu.Lock()
+	for rb.policy == OverflowBlock && rb.full() {
+		rb.space.Wait()
+	}
+	var (
+		evicted T
+		lost    = rb.full()
+	)
+	if lost && rb.policy == OverflowDropNewest {
+		evicted = val
+	} else {
+		if lost {
+			evicted = rb.data[rb.head]
+			rb.head = (rb.head + 1) % rb.size // Перезапись старых данных при переполнении
+			rb.count--
+		}
+		rb.data[rb.tail] = val
+		rb.tail = (rb.tail + 1) % rb.size
+		rb.count++
+	}
+	if lost {
+		rb.dropped++
+	}
+	onEvict := rb.onEvict
+	rb.mu.Unlock()
+
+	if lost && onEvict != nil {
+		onEvict(evicted)
+	}
+}
+
+// full - буфер заполнен (вызывается под блокировкой).
+func (rb *RingBuffer[T]) full() bool {
+	return rb.count == rb.size
+}
+
+// Full - буфер заполнен, следующий Push приведет к переполнению.
+func (rb *RingBuffer[T]) Full() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.full()
+}
+
+// Len - количество элементов в буфере.
+func (rb *RingBuffer[T]) Len() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.count
+}
+
+// Cap - емкость буфера.
+func (rb *RingBuffer[T]) Cap() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.size
+}
+
+// Dropped - количество значений, потерянных при переполнении.
+func (rb *RingBuffer[T]) Dropped() uint64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.dropped
+}
+
+// Flush - получение всех элементов из буфера с очисткой.
+func (rb *RingBuffer[T]) Flush() []T {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.count == 0 {
+		return nil // Буфер пуст
+	}
+
+	data := make([]T, 0, rb.count)
+	for ; rb.count > 0; rb.count-- {
+		data = append(data, rb.data[rb.head])
+		rb.head = (rb.head + 1) % rb.size
+	}
+	rb.space.Broadcast()
+	return data
+}
+
+// Peek - копия не более n самых старых элементов без удаления из буфера.
+func (rb *RingBuffer[T]) Peek(n int) []T {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.copyOut(min(max(n, 0), rb.count))
+}
+
+// Snapshot - копия всех элементов от старого к новому без удаления из буфера.
+func (rb *RingBuffer[T]) Snapshot() []T {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.copyOut(rb.count)
+}
+
+// All - перебор элементов от старого к новому без удаления из буфера.
+// Перебирается снимок на момент вызова, поэтому во время перебора
+// буфер можно изменять.
+func (rb *RingBuffer[T]) All() iter.Seq[T] {
+	data := rb.Snapshot()
+	return func(yield func(T) bool) {
+		for _, v := range data {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// copyOut - копия n самых старых элементов (вызывается под блокировкой).
+func (rb *RingBuffer[T]) copyOut(n int) []T {
+	if n == 0 {
+		return nil
+	}
+	data := make([]T, n)
+	for i := range data {
+		data[i] = rb.data[(rb.head+i)%rb.size]
+	}
+	return data
+}
+
+// Resize - изменение емкости буфера с сохранением элементов. Если элементы
+// не помещаются в новую емкость, самые старые удаляются из буфера
+// и возвращаются (без учета в Dropped и без вызова OnEvict).
+// Неположительная емкость приводит к панике.
+func (rb *RingBuffer[T]) Resize(size int) []T {
+	if size <= 0 {
+		panic(fmt.Sprintf("емкость кольцевого буфера должна быть положительной: %d", size))
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	removed := rb.copyOut(max(rb.count-size, 0))
+	rb.head = (rb.head + len(removed)) % rb.size
+	rb.count -= len(removed)
+
+	data := make([]T, size)
+	for i := 0; i < rb.count; i++ {
+		data[i] = rb.data[(rb.head+i)%rb.size]
+	}
+	rb.data, rb.size = data, size
+	rb.head, rb.tail = 0, rb.count%size
+	rb.space.Broadcast()
+	return removed
+}
+
+// CheckInvariants - проверка внутренней согласованности буфера: емкость
+// совпадает с хранилищем, количество элементов - от 0 до емкости, позиция
+// записи отстоит от позиции чтения на количество элементов, политика
+// переполнения известна. Предназначена для проверок под нагрузкой и на
+// случайных последовательностях операций (property- и fuzz-тесты) и может
+// вызываться одновременно с ними; ошибка описывает первое нарушение.
+func (rb *RingBuffer[T]) CheckInvariants() error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	switch {
+	case rb.size != len(rb.data):
+		return fmt.Errorf("кольцевой буфер: емкость %d, размер хранилища %d", rb.size, len(rb.data))
+	case rb.size == 0:
+		if rb.count != 0 || rb.head != 0 || rb.tail != 0 {
+			return fmt.Errorf("кольцевой буфер нулевой емкости содержит элементы: %d", rb.count)
+		}
+		return nil // Нулевое значение RingBuffer до UnmarshalBinary
+	case rb.count < 0 || rb.count > rb.size:
+		return fmt.Errorf("кольцевой буфер: элементов %d при емкости %d", rb.count, rb.size)
+	case rb.head < 0 || rb.head >= rb.size || rb.tail < 0 || rb.tail >= rb.size:
+		return fmt.Errorf("кольцевой буфер: позиции чтения %d и записи %d вне емкости %d", rb.head, rb.tail, rb.size)
+	case rb.tail != (rb.head+rb.count)%rb.size:
+		return fmt.Errorf("кольцевой буфер: позиция записи %d не соответствует позиции чтения %d и количеству элементов %d", rb.tail, rb.head, rb.count)
+	}
+	return ValidateOverflow(rb.policy)
+}
+
+// ringStateVersion - версия формата MarshalBinary (первый байт).
+const ringStateVersion = 1
+
+// ringState - сериализуемое состояние RingBuffer.
+type ringState[T any] struct {
+	Size    int    `json:"size"`
+	Policy  string `json:"policy"`
+	Dropped uint64 `json:"dropped"`
+	Values  []T    `json:"values"` // От старого к новому
+}
+
+// state - текущее состояние буфера.
+func (rb *RingBuffer[T]) state() ringState[T] {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return ringState[T]{Size: rb.size, Policy: rb.policy, Dropped: rb.dropped, Values: rb.copyOut(rb.count)}
+}
+
+// restore - замена состояния буфера на s после проверки. Обработчик
+// OnEvict сохраняется; ожидающие места Push продолжают работу.
+func (rb *RingBuffer[T]) restore(s ringState[T]) error {
+	switch {
+	case s.Size <= 0:
+		return fmt.Errorf("емкость кольцевого буфера должна быть положительной: %d", s.Size)
+	case len(s.Values) > s.Size:
+		return fmt.Errorf("элементов больше емкости кольцевого буфера: %d > %d", len(s.Values), s.Size)
+	}
+	if err := ValidateOverflow(s.Policy); err != nil {
+		return err
+	}
+	data := make([]T, s.Size)
+	copy(data, s.Values)
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.space == nil { // Нулевой RingBuffer
+		rb.space = sync.NewCond(&rb.mu)
+	}
+	rb.data, rb.size, rb.policy, rb.dropped = data, s.Size, s.Policy, s.Dropped
+	rb.head, rb.count, rb.tail = 0, len(s.Values), len(s.Values)%s.Size
+	rb.space.Broadcast()
+	return nil
+}
+
+// MarshalBinary - снимок буфера (емкость, политика переполнения, счетчик
+// потерь и элементы от старого к новому) в формате gob. Элементы должны
+// кодироваться gob: экспортируемые поля или encoding.BinaryMarshaler
+// (как у Num и time.Time).
+func (rb *RingBuffer[T]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(ringStateVersion)
+	if err := gob.NewEncoder(&buf).Encode(rb.state()); err != nil {
+		return nil, fmt.Errorf("кольцевой буфер: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary - восстановление буфера из снимка MarshalBinary, в том
+// числе в нулевой RingBuffer. При ошибке буфер не меняется.
+func (rb *RingBuffer[T]) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != ringStateVersion {
+		return fmt.Errorf("кольцевой буфер: неизвестный формат снимка")
+	}
+	var s ringState[T]
+	if err := gob.NewDecoder(bytes.NewReader(data[1:])).Decode(&s); err != nil {
+		return fmt.Errorf("кольцевой буфер: %w", err)
+	}
+	return rb.restore(s)
+}
+
+// MarshalJSON - снимок буфера в JSON: {"size", "policy", "dropped", "values"}.
+func (rb *RingBuffer[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rb.state())
+}
+
+// UnmarshalJSON - восстановление буфера из снимка MarshalJSON.
+func (rb *RingBuffer[T]) UnmarshalJSON(data []byte) error {
+	var s ringState[T]
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return rb.restore(s)
+}
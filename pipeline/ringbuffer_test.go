@@ -0,0 +1,290 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/quick"
+)
+
+// ringPolicies - политики переполнения в порядке выбора по байту входных данных.
+var ringPolicies = []string{OverflowOverwrite, OverflowDropNewest, OverflowBlock}
+
+// runRingModel - выполнение последовательности операций ops над RingBuffer
+// емкости size с политикой policy и над эталонной моделью на срезе.
+// Байт операции задает Push, Flush, Peek или Resize; для политики block
+// заполненный буфер сначала отправляется, как в стадии буферизации.
+// Возвращает первое расхождение с моделью или нарушение инвариантов.
+func runRingModel(ops []byte, size int, policy string) error {
+	rb := NewRingBufferPolicy[int](size, policy)
+	var evicted []int
+	rb.OnEvict(func(v int) { evicted = append(evicted, v) })
+	var (
+		model        []int
+		modelEvicted []int
+		next         int
+	)
+	for i, op := range ops {
+		switch op % 4 {
+		case 0, 1: // Push чаще остальных операций, чтобы буфер переполнялся
+			if policy == OverflowBlock && len(model) == size {
+				if got := rb.Flush(); !slices.Equal(got, model) {
+					return fmt.Errorf("операция %d: Flush перед Push = %v, ожидалось %v", i, got, model)
+				}
+				model = nil
+			}
+			rb.Push(next)
+			switch {
+			case len(model) < size:
+				model = append(model, next)
+			case policy == OverflowOverwrite:
+				modelEvicted = append(modelEvicted, model[0])
+				model = append(model[1:], next)
+			default:
+				modelEvicted = append(modelEvicted, next)
+			}
+			next++
+		case 2:
+			if got := rb.Flush(); !slices.Equal(got, model) {
+				return fmt.Errorf("операция %d: Flush = %v, ожидалось %v", i, got, model)
+			}
+			model = nil
+		case 3:
+			n := int(op>>2)%8 + 1
+			if op&0x80 != 0 {
+				if got, want := rb.Peek(n), model[:min(n, len(model))]; !slices.Equal(got, want) {
+					return fmt.Errorf("операция %d: Peek(%d) = %v, ожидалось %v", i, n, got, want)
+				}
+				break
+			}
+			removed := model[:max(len(model)-n, 0)]
+			if got := rb.Resize(n); !slices.Equal(got, removed) {
+				return fmt.Errorf("операция %d: Resize(%d) = %v, ожидалось %v", i, n, got, removed)
+			}
+			model, size = slices.Clone(model[len(removed):]), n
+		}
+		if err := rb.CheckInvariants(); err != nil {
+			return fmt.Errorf("операция %d: %w", i, err)
+		}
+		if rb.Len() != len(model) || rb.Cap() != size {
+			return fmt.Errorf("операция %d: Len %d, Cap %d, ожидалось %d и %d", i, rb.Len(), rb.Cap(), len(model), size)
+		}
+	}
+	if !slices.Equal(evicted, modelEvicted) || rb.Dropped() != uint64(len(modelEvicted)) {
+		return fmt.Errorf("потеряны %v (Dropped %d), ожидалось %v", evicted, rb.Dropped(), modelEvicted)
+	}
+	if got := rb.Snapshot(); !slices.Equal(got, model) {
+		return fmt.Errorf("Snapshot = %v, ожидалось %v", got, model)
+	}
+	return nil
+}
+
+func TestRingBufferQuick(t *testing.T) {
+	for _, policy := range ringPolicies {
+		t.Run(policy, func(t *testing.T) {
+			prop := func(ops []byte, size uint8) bool {
+				if err := runRingModel(ops, int(size)%16+1, policy); err != nil {
+					t.Log(err)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func FuzzRingBuffer(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 2, 1, 3}, uint8(2), uint8(0))
+	f.Add([]byte{0, 1, 0, 1, 0, 131, 2}, uint8(1), uint8(1))
+	f.Add([]byte{0, 0, 0, 0, 7, 0, 2}, uint8(3), uint8(2))
+	f.Fuzz(func(t *testing.T, ops []byte, size, policy uint8) {
+		if err := runRingModel(ops, int(size)%16+1, ringPolicies[int(policy)%len(ringPolicies)]); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// TestRingBufferConcurrentFIFO - одновременные Push и Flush: полученные
+// значения идут по возрастанию без повторов (FIFO, без порчи данных),
+// а полученные и потерянные вместе составляют все записанные. При политике
+// block значения не теряются.
+func TestRingBufferConcurrentFIFO(t *testing.T) {
+	const n = 5000
+	for _, policy := range ringPolicies {
+		for _, size := range []int{1, 3, 64} {
+			t.Run(fmt.Sprintf("%s/%d", policy, size), func(t *testing.T) {
+				rb := NewRingBufferPolicy[int](size, policy)
+				var lost []int
+				rb.OnEvict(func(v int) { lost = append(lost, v) }) // Вызывается из писателя
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					for i := range n {
+						rb.Push(i)
+					}
+				}()
+				var (
+					got      []int
+					checkErr error
+					wg       sync.WaitGroup
+				)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-done:
+							return
+						default:
+						}
+						if err := rb.CheckInvariants(); err != nil && checkErr == nil {
+							checkErr = err
+						}
+						runtime.Gosched()
+					}
+				}()
+			read:
+				for {
+					select {
+					case <-done:
+						break read
+					default:
+						batch := rb.Flush()
+						if len(batch) == 0 {
+							runtime.Gosched() // Очередь писателю, ожидающему места
+						}
+						got = append(got, batch...)
+					}
+				}
+				got = append(got, rb.Flush()...)
+				wg.Wait()
+				if checkErr != nil {
+					t.Fatal(checkErr)
+				}
+				for i := 1; i < len(got); i++ {
+					if got[i] <= got[i-1] {
+						t.Fatalf("нарушен порядок: %d после %d", got[i], got[i-1])
+					}
+				}
+				all := append(slices.Clone(got), lost...)
+				slices.Sort(all)
+				for i, v := range all {
+					if i >= n || v != i {
+						t.Fatalf("получено %d и потеряно %d значений из %d: повторы или порча данных", len(got), len(lost), n)
+					}
+				}
+				if len(all) != n {
+					t.Fatalf("получено %d и потеряно %d значений из %d", len(got), len(lost), n)
+				}
+				if policy == OverflowBlock && len(lost) != 0 {
+					t.Fatalf("при политике block потеряно %d значений", len(lost))
+				}
+				if rb.Dropped() != uint64(len(lost)) {
+					t.Fatalf("Dropped %d, потеряно %d", rb.Dropped(), len(lost))
+				}
+			})
+		}
+	}
+}
+
+func FuzzParseNum(f *testing.F) {
+	for _, s := range []string{"0", "-7", "+5", "1.5", "-2e10", "12345678901234567890", "1_000", "0x1p-2", "Inf", "NaN", ""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := ParseNum(s)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidNum) {
+				t.Fatalf("ParseNum(%q): ошибка %v без ErrInvalidNum", s, err)
+			}
+			return
+		}
+		if strings.Contains(s, "_") {
+			t.Fatalf("ParseNum(%q) = %s: разделители разрядов не допускаются", s, n)
+		}
+		// Выведенная запись разбирается в то же значение
+		back, err := ParseNum(n.String())
+		if err != nil || back.String() != n.String() || back.IsInt() != n.IsInt() {
+			t.Fatalf("ParseNum(%q) = %s, повторный разбор: %s, %v", s, n, back, err)
+		}
+	})
+}
+
+func FuzzParseNumLine(f *testing.F) {
+	for _, s := range []string{"42", " 42\n", "\t-1.5 ", "1 2", "", "\r\n"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		n, err := ParseNumLine(line)
+		want, wantErr := ParseNum(strings.TrimSpace(line))
+		if (err == nil) != (wantErr == nil) || err == nil && n.String() != want.String() {
+			t.Fatalf("ParseNumLine(%q) = %s, %v; ParseNum после обрезки = %s, %v", line, n, err, want, wantErr)
+		}
+	})
+}
+
+// TestSPSCRingConcurrentInvariants - писатель и читатель SPSCRing в разных
+// горутинах, третья горутина проверяет согласованность через CheckInvariants
+// и Len.
+func TestSPSCRingConcurrentInvariants(t *testing.T) {
+	const n = 20000
+	for _, size := range []int{1, 3, 64} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			r := NewSPSCRing[int](size)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < n; {
+					if r.Push(i) {
+						i++
+					} else {
+						runtime.Gosched()
+					}
+				}
+			}()
+			checkErr := make(chan error, 1)
+			go func() {
+				defer close(checkErr)
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					if err := r.CheckInvariants(); err != nil {
+						checkErr <- err
+						return
+					}
+					if l := r.Len(); l < 0 || l > size {
+						checkErr <- fmt.Errorf("Len %d при емкости %d", l, size)
+						return
+					}
+					runtime.Gosched()
+				}
+			}()
+			var got []int
+			for len(got) < n {
+				before := len(got)
+				got = r.PopAll(got)
+				if len(got) == before {
+					runtime.Gosched()
+				}
+			}
+			if err := <-checkErr; err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range got {
+				if v != i {
+					t.Fatalf("значение %d на позиции %d", v, i)
+				}
+			}
+		})
+	}
+}
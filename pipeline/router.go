@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Branch - ветвь маршрутизатора Route.
+type Branch[T any] struct {
+	Name   string       // Имя ветви (в ошибках и отклонениях)
+	When   Predicate[T] // Условие ветви (nil - ветвь по умолчанию)
+	Stages []Stage[T]   // Стадии ветви (пусто - значения передаются как есть)
+	Sink   Sink[T]      // Приемник ветви (nil - выход ветви объединяется с выходом Route)
+}
+
+// Route - стадия, направляющая каждое значение в первую ветвь, условие которой
+// истинно, а если таких нет - в ветвь по умолчанию. Значения без подходящей
+// ветви отклоняются (Reject). Выходы ветвей без приемника объединяются в выход
+// стадии без сохранения порядка между ветвями; ветви с приемником выводят
+// значения в него, ошибка приемника передается в ReportError, и дальнейшие
+// значения ветви отбрасываются. Стадия завершается после завершения всех
+// ветвей и сброса их приемников.
+//
+//	pipeline.Route(
+//		pipeline.Branch[int]{Name: "even", When: func(n int) bool { return n%2 == 0 }, Sink: evens},
+//		pipeline.Branch[int]{Name: "odd", Stages: []pipeline.Stage[int]{square}},
+//	)
+func Route[T any](branches ...Branch[T]) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		ins := make([]chan T, len(branches))
+		def := -1
+		var wg sync.WaitGroup
+		for i, b := range branches {
+			if b.When == nil && def < 0 {
+				def = i
+			}
+			ins[i] = make(chan T)
+			branchOut := Chain(ctx, ins[i], b.Stages...)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if b.Sink == nil {
+					for v := range branchOut {
+						if !send(ctx, out, v) {
+							return
+						}
+					}
+					return
+				}
+				if err := Drain(ctx, branchOut, b.Sink); err != nil && !errors.Is(err, ctx.Err()) {
+					ReportError(ctx, fmt.Errorf("ветвь %s: %w", b.Name, err))
+					discard(ctx, branchOut)
+				}
+			}()
+		}
+
+		route(ctx, in, branches, ins, def)
+		for _, c := range ins {
+			close(c)
+		}
+		wg.Wait()
+	}
+}
+
+// route - распределение значений in по входам ветвей ins до закрытия in
+// или отмены ctx.
+func route[T any](ctx context.Context, in <-chan T, branches []Branch[T], ins []chan T, def int) {
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return
+			}
+			target := def
+			for i, b := range branches {
+				if b.When != nil && b.When(v) {
+					target = i
+					break
+				}
+			}
+			if target < 0 {
+				Reject(ctx, NewRejection("route", v, "нет подходящей ветви"))
+				continue
+			}
+			if !send(ctx, ins[target], v) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// sampleReason - причина отбрасывания значения стадиями выборки.
+const sampleReason = "не попало в выборку"
+
+// SampleEvery - стадия, передающая каждое n-е значение (первое, n+1-е и т.д.;
+// n <= 1 - все значения). Отброшенные значения передаются в Reject.
+func SampleEvery[T any](n int) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		i := 0
+		sample(ctx, in, out, func(T) bool {
+			pass := n <= 1 || i%n == 0
+			i++
+			return pass
+		})
+	}
+}
+
+// SampleRandom - стадия, передающая каждое значение с вероятностью fraction
+// (от 0 до 1). Случайные числа берутся из rng (nil - общий генератор пакета
+// math/rand/v2). Отброшенные значения передаются в Reject.
+func SampleRandom[T any](fraction float64, rng *rand.Rand) Stage[T] {
+	random := rand.Float64
+	if rng != nil {
+		random = rng.Float64
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		sample(ctx, in, out, func(T) bool { return random() < fraction })
+	}
+}
+
+// sample - передача значений, для которых pass истинно; out закрывается
+// по завершении.
+func sample[T any](ctx context.Context, in <-chan T, out chan<- T, pass func(v T) bool) {
+	defer close(out)
+	audit := rejecting(ctx)
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				return
+			}
+			if !pass(v) {
+				if audit {
+					Reject(ctx, NewRejection("sample", v, sampleReason))
+				}
+				continue
+			}
+			if !send(ctx, out, v) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reservoirItem - значение выборки с номером поступления в окне.
+type reservoirItem[T any] struct {
+	i int
+	v T
+}
+
+// SampleReservoir - стадия, передающая равномерную случайную выборку
+// из k значений каждого окна длительностью interval (interval <= 0 - одно
+// окно до закрытия входа). Выборка отправляется в конце окна в порядке
+// поступления; в окне меньше k значений передаются все. Случайные числа
+// берутся из rng (nil - общий генератор пакета math/rand/v2).
+// Значения, не попавшие в выборку, передаются в Reject.
+func SampleReservoir[T any](k int, interval time.Duration, clock Clock, rng *rand.Rand) Stage[T] {
+	intN := rand.IntN
+	if rng != nil {
+		intN = rng.IntN
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		audit := rejecting(ctx)
+		drop := func(v T) {
+			if audit {
+				Reject(ctx, NewRejection("sample", v, sampleReason))
+			}
+		}
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := clock.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C()
+		}
+
+		reservoir := make([]reservoirItem[T], 0, k)
+		seen := 0 // Значений в текущем окне
+		flush := func() bool {
+			// Замены нарушают порядок поступления
+			slices.SortFunc(reservoir, func(a, b reservoirItem[T]) int { return cmp.Compare(a.i, b.i) })
+			for _, it := range reservoir {
+				if !send(ctx, out, it.v) {
+					return false
+				}
+			}
+			reservoir, seen = reservoir[:0], 0
+			return true
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				switch j := intN(seen + 1); {
+				case len(reservoir) < k:
+					reservoir = append(reservoir, reservoirItem[T]{i: seen, v: v})
+				case j < k:
+					drop(reservoir[j].v)
+					reservoir[j] = reservoirItem[T]{i: seen, v: v}
+				default:
+					drop(v)
+				}
+				seen++
+			case <-tick:
+				if !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Sink - приемник данных пайплайна.
+type Sink[T any] interface {
+	Write(v T) error
+	Flush() error
+}
+
+// Drain - запись значений из in в sink до закрытия in или отмены ctx.
+// По завершении вызывается sink.Flush. При отмене ctx возвращается ctx.Err(),
+// даже если in закрыт одновременно с отменой (стадия завершилась по ней).
+func Drain[T any](ctx context.Context, in <-chan T, sink Sink[T]) error {
+	for {
+		select {
+		case v, ok := <-in:
+			if !ok {
+				if err := sink.Flush(); err != nil {
+					return err
+				}
+				return ctx.Err()
+			}
+			if err := sink.Write(v); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			if err := sink.Flush(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// BatchSink - приемник, принимающий значения партиями.
+type BatchSink[T any] interface {
+	WriteBatch(batch []T) error
+	Flush() error
+}
+
+// DrainBatches - запись партий из in в sink до закрытия in или отмены ctx.
+// По завершении вызывается sink.Flush. При отмене ctx возвращается ctx.Err(),
+// даже если in закрыт одновременно с отменой (стадия завершилась по ней).
+func DrainBatches[T any](ctx context.Context, in <-chan []T, sink BatchSink[T]) error {
+	for {
+		select {
+		case batch, ok := <-in:
+			if !ok {
+				if err := sink.Flush(); err != nil {
+					return err
+				}
+				return ctx.Err()
+			}
+			if err := sink.WriteBatch(batch); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			if err := sink.Flush(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// SliceSink - приемник, сохраняющий значения в памяти (например, для тестов).
+type SliceSink[T any] struct {
+	mu     sync.Mutex
+	values []T
+}
+
+// Write - сохранение значения.
+func (s *SliceSink[T]) Write(v T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = append(s.values, v)
+	return nil
+}
+
+// Flush - ничего не делает: значения сохраняются сразу.
+func (s *SliceSink[T]) Flush() error { return nil }
+
+// Values - копия сохраненных значений.
+func (s *SliceSink[T]) Values() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.values)
+}
+
+// WriterSink - приемник, выводящий значения в io.Writer по формату.
+type WriterSink[T any] struct {
+	w      io.Writer
+	format string
+}
+
+// NewWriterSink - создание приемника, выводящего каждое значение
+// в w по формату format (например, "%d\n").
+func NewWriterSink[T any](w io.Writer, format string) *WriterSink[T] {
+	return &WriterSink[T]{w: w, format: format}
+}
+
+// Write - вывод значения.
+func (s *WriterSink[T]) Write(v T) error {
+	_, err := fmt.Fprintf(s.w, s.format, v)
+	return err
+}
+
+// Flush - сброс буфера w, если он поддерживает Flush.
+func (s *WriterSink[T]) Flush() error {
+	if f, ok := s.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
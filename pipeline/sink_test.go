@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// batchSliceSink - приемник партий в памяти.
+type batchSliceSink struct{ SliceSink[int] }
+
+func (s *batchSliceSink) WriteBatch(batch []int) error {
+	for _, v := range batch {
+		s.Write(v)
+	}
+	return nil
+}
+
+// TestDrainCancelWins - закрытый вход и отмена одновременно: Drain и
+// DrainBatches всегда сообщают об отмене.
+func TestDrainCancelWins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 100 {
+		in := make(chan int)
+		close(in)
+		if err := Drain(ctx, in, &SliceSink[int]{}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Drain = %v, ожидалась context.Canceled", err)
+		}
+		batches := make(chan []int)
+		close(batches)
+		if err := DrainBatches(ctx, batches, &batchSliceSink{}); !errors.Is(err, context.Canceled) {
+			t.Fatalf("DrainBatches = %v, ожидалась context.Canceled", err)
+		}
+	}
+}
+
+func TestDrainClosedInput(t *testing.T) {
+	in := make(chan int, 2)
+	in <- 1
+	in <- 2
+	close(in)
+	var sink SliceSink[int]
+	if err := Drain(context.Background(), in, &sink); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Values(); len(got) != 2 {
+		t.Fatalf("записано %v", got)
+	}
+}
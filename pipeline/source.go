@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Source - источник данных пайплайна. Next возвращает очередное значение
+// или io.EOF, когда данные исчерпаны.
+type Source[T any] interface {
+	Next() (T, error)
+}
+
+// Feed - передача значений из src в out до исчерпания источника или отмены ctx.
+// Возвращает nil при исчерпании источника. Канал out не закрывается.
+func Feed[T any](ctx context.Context, src Source[T], out chan<- T) error {
+	for {
+		v, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !send(ctx, out, v) {
+			return ctx.Err()
+		}
+	}
+}
+
+// SliceSource - источник значений из памяти (например, для тестов).
+type SliceSource[T any] struct {
+	values []T
+}
+
+// NewSliceSource - создание источника, возвращающего values по порядку.
+func NewSliceSource[T any](values ...T) *SliceSource[T] {
+	return &SliceSource[T]{values: values}
+}
+
+// Next - очередное значение источника.
+func (s *SliceSource[T]) Next() (T, error) {
+	var zero T
+	if len(s.values) == 0 {
+		return zero, io.EOF
+	}
+	v := s.values[0]
+	s.values = s.values[1:]
+	return v, nil
+}
+
+// LineSource - источник значений, записанных по одному в строке.
+type LineSource[T any] struct {
+	scanner   *bufio.Scanner
+	lineNo    int
+	parse     func(line string) (T, error)
+	onInvalid func(lineNo int, line string, err error)
+}
+
+// NewLineSource - создание источника значений из r, разбираемых функцией parse.
+// Строки с ошибкой разбора пропускаются и передаются в onInvalid (может быть nil).
+func NewLineSource[T any](r io.Reader, parse func(line string) (T, error), onInvalid func(lineNo int, line string, err error)) *LineSource[T] {
+	return &LineSource[T]{scanner: bufio.NewScanner(r), parse: parse, onInvalid: onInvalid}
+}
+
+// Next - очередное значение источника.
+func (s *LineSource[T]) Next() (T, error) {
+	for s.scanner.Scan() {
+		s.lineNo++
+		line := s.scanner.Text()
+		v, err := s.parse(line)
+		if err == nil {
+			return v, nil
+		}
+		if s.onInvalid != nil {
+			s.onInvalid(s.lineNo, strings.TrimSpace(line), err)
+		}
+	}
+	var zero T
+	if err := s.scanner.Err(); err != nil {
+		return zero, err
+	}
+	return zero, io.EOF
+}
+
+// ReaderSource - источник целых чисел, записанных по одному в строке.
+type ReaderSource = LineSource[int]
+
+// NewReaderSource - создание источника чисел из r. Некорректные строки
+// пропускаются и передаются в onInvalid (может быть nil).
+func NewReaderSource(r io.Reader, onInvalid func(lineNo int, line string)) *ReaderSource {
+	var report func(int, string, error)
+	if onInvalid != nil {
+		report = func(lineNo int, line string, _ error) { onInvalid(lineNo, line) }
+	}
+	return NewLineSource(r, ParseIntLine, report)
+}
+
+// ParseIntLine - разбор строки с одним целым числом.
+func ParseIntLine(line string) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(line))
+}
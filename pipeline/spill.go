@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Spill - очередь значений на диске: файл с одной JSON-записью на строку.
+// Значения дописываются в конец и читаются с начала; непрочитанные значения
+// сохраняются при Close и читаются снова после повторного открытия файла.
+// Используется несколькими горутинами только с внешней синхронизацией.
+type Spill[T any] struct {
+	path  string
+	w     *os.File
+	buf   *bufio.Writer
+	r     *os.File
+	rd    *bufio.Reader
+	front []T  // Значения, возвращенные в начало очереди (PushFront)
+	n     int  // Непрочитанные записи в файле
+	read  bool // Начало файла уже прочитано
+}
+
+// OpenSpill - открытие (или создание) файла очереди path.
+// Записи, оставшиеся от предыдущего запуска, доступны через Pop.
+func OpenSpill[T any](path string) (*Spill[T], error) {
+	w, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("файл сброса на диск: %w", err)
+	}
+	r, err := os.Open(path)
+	if err != nil {
+		w.Close()
+		return nil, fmt.Errorf("файл сброса на диск: %w", err)
+	}
+	s := &Spill[T]{path: path, w: w, buf: bufio.NewWriter(w), r: r, rd: bufio.NewReader(r)}
+
+	// Подсчет записей, оставшихся от предыдущего запуска
+	for {
+		line, err := s.rd.ReadBytes('\n')
+		if len(line) > 1 || (len(line) == 1 && line[0] != '\n') {
+			s.n++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("файл сброса на диск %s: %w", path, err)
+		}
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		s.close()
+		return nil, err
+	}
+	s.rd.Reset(r)
+	return s, nil
+}
+
+// Len - количество значений в очереди.
+func (s *Spill[T]) Len() int {
+	return len(s.front) + s.n
+}
+
+// Push - добавление значения в конец очереди.
+func (s *Spill[T]) Push(v T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("сброс на диск: %w", err)
+	}
+	s.buf.Write(data)
+	if err := s.buf.WriteByte('\n'); err != nil {
+		return fmt.Errorf("сброс на диск: %w", err)
+	}
+	s.n++
+	return nil
+}
+
+// PushFront - возврат значений vs в начало очереди (перед остальными).
+func (s *Spill[T]) PushFront(vs ...T) {
+	s.front = append(append([]T(nil), vs...), s.front...)
+}
+
+// Pop - извлечение первого значения очереди. ok равно false для пустой очереди.
+func (s *Spill[T]) Pop() (v T, ok bool, err error) {
+	if len(s.front) > 0 {
+		v, s.front = s.front[0], s.front[1:]
+		return v, true, nil
+	}
+	if s.n == 0 {
+		return v, false, nil
+	}
+	if err := s.buf.Flush(); err != nil {
+		return v, false, fmt.Errorf("сброс на диск: %w", err)
+	}
+	for {
+		line, err := s.rd.ReadBytes('\n')
+		if len(line) > 1 || (len(line) == 1 && line[0] != '\n') {
+			s.n--
+			s.read = true
+			if err := json.Unmarshal(line, &v); err != nil {
+				return v, false, fmt.Errorf("файл сброса на диск %s: %w", s.path, err)
+			}
+			if s.n == 0 {
+				return v, true, s.truncate()
+			}
+			return v, true, nil
+		}
+		if err != nil {
+			// Файл короче ожидаемого (например, изменен извне)
+			s.n = 0
+			if errors.Is(err, io.EOF) {
+				return v, false, s.truncate()
+			}
+			return v, false, err
+		}
+	}
+}
+
+// truncate - очистка прочитанного файла.
+func (s *Spill[T]) truncate() error {
+	if err := s.w.Truncate(0); err != nil {
+		return fmt.Errorf("сброс на диск: %w", err)
+	}
+	if _, err := s.r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.rd.Reset(s.r)
+	s.read = false
+	return nil
+}
+
+// Close - сохранение непрочитанных значений в файле и его закрытие.
+func (s *Spill[T]) Close() error {
+	if s.Len() == 0 {
+		return errors.Join(s.truncate(), s.close())
+	}
+	if len(s.front) == 0 && !s.read {
+		return errors.Join(s.buf.Flush(), s.close())
+	}
+
+	// Перезапись файла: значения PushFront, затем непрочитанный остаток
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Join(fmt.Errorf("сброс на диск: %w", err), s.close())
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, v := range s.front {
+		if err = enc.Encode(v); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		if err = s.buf.Flush(); err == nil {
+			_, err = io.Copy(w, s.rd)
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	err = errors.Join(err, f.Close(), s.close())
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("сброс на диск: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// close - закрытие файлов очереди.
+func (s *Spill[T]) close() error {
+	return errors.Join(s.w.Close(), s.r.Close())
+}
@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Реализации хранилища стадии буферизации.
+const (
+	RingMutex = "mutex" // RingBuffer с блокировкой
+	RingSPSC  = "spsc"  // SPSCRing без блокировок
+)
+
+// ValidateRing - проверка имени реализации хранилища буфера.
+func ValidateRing(ring string) error {
+	switch ring {
+	case RingMutex, RingSPSC:
+		return nil
+	}
+	return fmt.Errorf("неизвестная реализация буфера: %q (ожидается %s или %s)", ring, RingMutex, RingSPSC)
+}
+
+// cacheLine - размер строки кэша процессора для выравнивания индексов.
+const cacheLine = 64
+
+// SPSCRing - кольцевой буфер без блокировок для одного писателя и одного
+// читателя (single producer, single consumer). Push вызывается только из
+// одной горутины, Pop и PopAll - только из одной (возможно, другой);
+// Len и Cap - из любой. Индексы - атомарные счетчики, разнесенные по разным
+// строкам кэша, чтобы писатель и читатель не мешали друг другу.
+type SPSCRing[T any] struct {
+	data []T
+	size uint64
+	_    [cacheLine]byte
+	head atomic.Uint64 // Номер следующего читаемого элемента (читатель)
+	_    [cacheLine - 8]byte
+	tail atomic.Uint64 // Номер следующего записываемого элемента (писатель)
+	_    [cacheLine - 8]byte
+}
+
+// NewSPSCRing - создание буфера емкости size.
+// Неположительная емкость приводит к панике.
+func NewSPSCRing[T any](size int) *SPSCRing[T] {
+	if size <= 0 {
+		panic(fmt.Sprintf("емкость кольцевого буфера должна быть положительной: %d", size))
+	}
+	return &SPSCRing[T]{data: make([]T, size), size: uint64(size)}
+}
+
+// Push - добавление элемента; false - буфер заполнен, элемент не добавлен.
+func (r *SPSCRing[T]) Push(v T) bool {
+	tail := r.tail.Load()
+	if tail-r.head.Load() == r.size {
+		return false
+	}
+	r.data[tail%r.size] = v
+	r.tail.Store(tail + 1) // Публикация элемента читателю
+	return true
+}
+
+// Pop - извлечение самого старого элемента; false - буфер пуст.
+func (r *SPSCRing[T]) Pop() (T, bool) {
+	var zero T
+	head := r.head.Load()
+	if head == r.tail.Load() {
+		return zero, false
+	}
+	i := head % r.size
+	v := r.data[i]
+	r.data[i] = zero // Значение не удерживается от сборки мусора
+	r.head.Store(head + 1)
+	return v, true
+}
+
+// PopAll - извлечение всех элементов, добавленных к моменту вызова,
+// с дописыванием в dst.
+func (r *SPSCRing[T]) PopAll(dst []T) []T {
+	var zero T
+	head, tail := r.head.Load(), r.tail.Load()
+	for h := head; h != tail; h++ {
+		i := h % r.size
+		dst = append(dst, r.data[i])
+		r.data[i] = zero
+	}
+	r.head.Store(tail)
+	return dst
+}
+
+// Len - количество элементов в буфере.
+func (r *SPSCRing[T]) Len() int {
+	// Сначала читается head: иначе между чтениями он может обогнать tail
+	head := r.head.Load()
+	return int(r.tail.Load() - head)
+}
+
+// Cap - емкость буфера.
+func (r *SPSCRing[T]) Cap() int {
+	return int(r.size)
+}
+
+// CheckInvariants - проверка согласованности буфера: емкость совпадает
+// с хранилищем, элементов - от 0 до емкости. Вызывается из любой горутины,
+// как Len (см. RingBuffer.CheckInvariants).
+func (r *SPSCRing[T]) CheckInvariants() error {
+	if r.size == 0 || r.size != uint64(len(r.data)) {
+		return fmt.Errorf("кольцевой буфер: емкость %d, размер хранилища %d", r.size, len(r.data))
+	}
+	head := r.head.Load()
+	if n := r.tail.Load() - head; n > r.size {
+		return fmt.Errorf("кольцевой буфер: позиция записи отстает от позиции чтения или элементов %d при емкости %d", int64(n), r.size)
+	}
+	return nil
+}
+
+// spscBuffer - хранилище стадии буферизации на SPSCRing с политикой
+// переполнения. Стадия - единственный писатель и читатель, поэтому
+// вытеснение (чтение из горутины писателя) безопасно.
+type spscBuffer[T any] struct {
+	ring    *SPSCRing[T]
+	policy  string
+	onEvict func(v T)
+}
+
+// newSPSCBuffer - хранилище емкости size с политикой policy.
+func newSPSCBuffer[T any](size int, policy string, onEvict func(v T)) *spscBuffer[T] {
+	return &spscBuffer[T]{ring: NewSPSCRing[T](size), policy: policy, onEvict: onEvict}
+}
+
+// Push - добавление значения. Для политики block стадия отправляет
+// заполненный буфер заранее, поэтому переполнение как при drop-newest.
+func (b *spscBuffer[T]) Push(v T) {
+	if b.ring.Push(v) {
+		return
+	}
+	evicted := v
+	if b.policy == OverflowOverwrite {
+		evicted, _ = b.ring.Pop()
+		b.ring.Push(v)
+	}
+	if b.onEvict != nil {
+		b.onEvict(evicted)
+	}
+}
+
+// Full - буфер заполнен.
+func (b *spscBuffer[T]) Full() bool {
+	return b.ring.Len() == b.ring.Cap()
+}
+
+// Len - количество значений в буфере.
+func (b *spscBuffer[T]) Len() int {
+	return b.ring.Len()
+}
+
+// Flush - извлечение всех значений (nil - буфер пуст).
+func (b *spscBuffer[T]) Flush() []T {
+	if b.ring.Len() == 0 {
+		return nil
+	}
+	return b.ring.PopAll(make([]T, 0, b.ring.Len()))
+}
+
+// Peek - копия не более n самых старых значений без удаления (вызывается
+// стадией - единственным читателем).
+func (b *spscBuffer[T]) Peek(n int) []T {
+	r := b.ring
+	head := r.head.Load()
+	data := make([]T, min(max(n, 0), r.Len()))
+	for i := range data {
+		data[i] = r.data[(head+uint64(i))%r.size]
+	}
+	return data
+}
+
+// Resize - замена буфера буфером емкости size с сохранением значений;
+// не поместившиеся самые старые возвращаются.
+func (b *spscBuffer[T]) Resize(size int) []T {
+	data := b.Flush()
+	var removed []T
+	if n := len(data) - size; n > 0 {
+		removed, data = data[:n], data[n:]
+	}
+	b.ring = NewSPSCRing[T](size)
+	for _, v := range data {
+		b.ring.Push(v)
+	}
+	return removed
+}
@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSPSCRingBoundaries(t *testing.T) {
+	r := NewSPSCRing[int](3)
+	if _, ok := r.Pop(); ok {
+		t.Fatal("Pop из пустого буфера")
+	}
+	// Многократный переход через конец хранилища
+	next, want := 0, 0
+	for round := range 10 {
+		for r.Push(next) {
+			next++
+		}
+		if r.Len() != r.Cap() || r.Cap() != 3 {
+			t.Fatalf("круг %d: Len %d, Cap %d у заполненного буфера", round, r.Len(), r.Cap())
+		}
+		if r.Push(-1) {
+			t.Fatalf("круг %d: Push в заполненный буфер", round)
+		}
+		for range round%3 + 1 {
+			v, ok := r.Pop()
+			if !ok || v != want {
+				t.Fatalf("круг %d: Pop = %d, %v, ожидалось %d", round, v, ok, want)
+			}
+			want++
+		}
+		if err := r.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rest := r.PopAll(nil)
+	for _, v := range rest {
+		if v != want {
+			t.Fatalf("PopAll = %v, ожидалось начало с %d", rest, want)
+		}
+		want++
+	}
+	if want != next || r.Len() != 0 {
+		t.Fatalf("извлечено до %d из %d, Len %d", want, next, r.Len())
+	}
+	if _, ok := r.Pop(); ok {
+		t.Fatal("Pop из опустошенного буфера")
+	}
+}
+
+// TestSPSCRingProducerConsumer - один писатель и один читатель (Pop) в разных
+// горутинах: значения приходят по порядку, без потерь и повторов.
+func TestSPSCRingProducerConsumer(t *testing.T) {
+	const n = 50000
+	for _, size := range []int{1, 2, 7, 128} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			r := NewSPSCRing[int](size)
+			go func() {
+				for i := 0; i < n; {
+					if r.Push(i) {
+						i++
+					} else {
+						runtime.Gosched()
+					}
+				}
+			}()
+			for want := 0; want < n; {
+				v, ok := r.Pop()
+				if !ok {
+					runtime.Gosched()
+					continue
+				}
+				if v != want {
+					t.Fatalf("получено %d, ожидалось %d", v, want)
+				}
+				want++
+			}
+			if _, ok := r.Pop(); ok || r.Len() != 0 {
+				t.Fatal("лишние значения после всех записанных")
+			}
+		})
+	}
+}
+
+func TestSPSCBufferOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		policy       string
+		want, evicts []int
+	}{
+		{OverflowOverwrite, []int{4, 5}, []int{1, 2, 3}},
+		{OverflowDropNewest, []int{1, 2}, []int{3, 4, 5}},
+	} {
+		var evicted []int
+		b := newSPSCBuffer(2, tc.policy, func(v int) { evicted = append(evicted, v) })
+		for v := 1; v <= 5; v++ {
+			b.Push(v)
+		}
+		if !b.Full() || b.Len() != 2 {
+			t.Fatalf("%s: Full %v, Len %d", tc.policy, b.Full(), b.Len())
+		}
+		if got := b.Peek(5); !slices.Equal(got, tc.want) {
+			t.Fatalf("%s: Peek = %v, ожидалось %v", tc.policy, got, tc.want)
+		}
+		if got := b.Flush(); !slices.Equal(got, tc.want) || !slices.Equal(evicted, tc.evicts) {
+			t.Fatalf("%s: Flush = %v, потеряны %v; ожидалось %v и %v", tc.policy, got, evicted, tc.want, tc.evicts)
+		}
+		if b.Flush() != nil {
+			t.Fatalf("%s: Flush пустого буфера не nil", tc.policy)
+		}
+	}
+}
+
+func TestSPSCBufferResize(t *testing.T) {
+	b := newSPSCBuffer[int](4, OverflowOverwrite, nil)
+	for v := 1; v <= 4; v++ {
+		b.Push(v)
+	}
+	if removed := b.Resize(2); !slices.Equal(removed, []int{1, 2}) {
+		t.Fatalf("Resize(2) вернул %v, ожидалось [1 2]", removed)
+	}
+	if removed := b.Resize(3); removed != nil {
+		t.Fatalf("Resize(3) вернул %v", removed)
+	}
+	b.Push(5)
+	b.Push(6) // Вытесняет 3
+	if got := b.Flush(); !slices.Equal(got, []int{4, 5, 6}) {
+		t.Fatalf("после Resize: %v, ожидалось [4 5 6]", got)
+	}
+}
+
+// TestBufferStageSPSC - стадия буферизации с ring: spsc при каждой политике
+// переполнения и изменении размера во время работы.
+func TestBufferStageSPSC(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		want   []int
+	}{
+		{OverflowOverwrite, []int{4, 5}},
+		{OverflowDropNewest, []int{1, 2}},
+		{OverflowBlock, []int{1, 2, 3, 4, 5}}, // Заполненный буфер отправляется
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			h := NewHarness(testContext(t), NewBufferWith(BufferOptions[int]{
+				Size: 2, FlushInterval: time.Hour, Overflow: tc.policy, Ring: RingSPSC,
+			}))
+			h.Send(1, 2, 3, 4, 5)
+			if err := h.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := h.Output(); !slices.Equal(got, tc.want) {
+				t.Fatalf("выход %v, ожидалось %v", got, tc.want)
+			}
+		})
+	}
+	t.Run("resize", func(t *testing.T) {
+		control := NewBufferControl()
+		h := NewHarness(testContext(t), NewBufferWith(BufferOptions[int]{
+			Size: 4, FlushInterval: time.Hour, Ring: RingSPSC, Control: control,
+		}))
+		h.Send(1, 2, 3, 4)
+		if err := control.SetSize(2); err != nil {
+			t.Fatal(err)
+		}
+		// Значения, не помещающиеся в уменьшенный буфер, сначала отправляются
+		if got, ok := h.WaitOutput(4, testTimeout); !ok || !slices.Equal(got, []int{1, 2, 3, 4}) {
+			t.Fatalf("после уменьшения: %v, ожидалось [1 2 3 4]", got)
+		}
+		h.Send(5, 6, 7) // 7 вытесняет 5 из буфера нового размера
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := h.Output(); !slices.Equal(got, []int{1, 2, 3, 4, 6, 7}) {
+			t.Fatalf("выход %v, ожидалось [1 2 3 4 6 7]", got)
+		}
+	})
+}
+
+// benchRingBatch - размер партии, после которой буфер опустошается.
+const benchRingBatch = 1024
+
+// BenchmarkRingBuffer - RingBuffer с блокировкой: запись и отправка партий
+// из одной горутины, как в стадии буферизации, и запись одновременно с Flush.
+func BenchmarkRingBuffer(b *testing.B) {
+	b.Run("stage", func(b *testing.B) {
+		rb := NewRingBuffer[int](benchRingBatch)
+		b.ReportAllocs()
+		for i := range b.N {
+			rb.Push(i)
+			if rb.Full() {
+				rb.Flush()
+			}
+		}
+	})
+	b.Run("concurrent", func(b *testing.B) {
+		rb := NewRingBufferPolicy[int](benchRingBatch, OverflowDropNewest)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for read := 0; read < b.N; {
+				read += len(rb.Flush())
+				runtime.Gosched()
+			}
+		}()
+		for i := 0; i < b.N; {
+			if rb.Full() {
+				runtime.Gosched()
+				continue
+			}
+			rb.Push(i)
+			i++
+		}
+		<-done
+	})
+}
+
+// BenchmarkSPSCRing - SPSCRing без блокировок: запись и чтение из одной
+// горутины, как в стадии буферизации (ring: spsc), и писатель с читателем
+// в разных горутинах - режим, для которого буфер предназначен.
+func BenchmarkSPSCRing(b *testing.B) {
+	b.Run("stage", func(b *testing.B) {
+		r := NewSPSCRing[int](benchRingBatch)
+		dst := make([]int, 0, benchRingBatch)
+		b.ReportAllocs()
+		for i := range b.N {
+			if !r.Push(i) {
+				dst = r.PopAll(dst[:0])
+				r.Push(i)
+			}
+		}
+	})
+	b.Run("concurrent", func(b *testing.B) {
+		r := NewSPSCRing[int](benchRingBatch)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			dst := make([]int, 0, benchRingBatch)
+			for read := 0; read < b.N; {
+				dst = r.PopAll(dst[:0])
+				read += len(dst)
+				if len(dst) == 0 {
+					runtime.Gosched()
+				}
+			}
+		}()
+		for i := 0; i < b.N; {
+			if r.Push(i) {
+				i++
+			} else {
+				runtime.Gosched()
+			}
+		}
+		<-done
+	})
+}
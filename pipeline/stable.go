@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// NewStableGate - стадия, пропускающая значение только после того, как оно
+// не менялось в течение duration. Любое новое значение сбрасывает таймер,
+// подтвержденное значение отправляется один раз.
+func NewStableGate[T comparable](duration time.Duration, clock Clock) Stage[T] {
+	return NewStableGateBy(identity[T], duration, clock)
+}
+
+// NewStableGateBy - NewStableGate со сравнением значений по ключу key
+// (например, ItemValue). Отправляется первое значение с подтвержденным ключом.
+func NewStableGateBy[T any, K comparable](key func(T) K, duration time.Duration, clock Clock) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		timer := clock.NewTimer(duration)
+		timer.Stop()
+		defer timer.Stop()
+
+		var (
+			current T
+			pending bool // Значение ожидает подтверждения
+			seen    bool // Получено хотя бы одно значение
+		)
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					return
+				}
+				if !seen || key(n) != key(current) {
+					current, seen, pending = n, true, true
+					timer.Reset(duration)
+				}
+			case <-timer.C():
+				if pending {
+					pending = false
+					if !send(ctx, out, current) {
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// stableHarness - стенд стадии NewStableGate с периодом duration на FakeClock.
+func stableHarness(t *testing.T, duration time.Duration) (*Harness[int], *FakeClock) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := NewHarness(testContext(t), NewStableGate[int](duration, clock))
+	t.Cleanup(func() { h.Close() })
+	return h, clock
+}
+
+// sendSync - передача v стадии с ожиданием его обработки: повтор того же
+// значения таймер не сбрасывает и принимается, только когда стадия
+// обработала первое.
+func sendSync(h *Harness[int], v int) {
+	h.Send(v, v)
+}
+
+func TestStableGateEmitsAfterDuration(t *testing.T) {
+	h, clock := stableHarness(t, time.Second)
+	sendSync(h, 1)
+	clock.Advance(999 * time.Millisecond)
+	if out, ok := h.WaitOutput(1, 20*time.Millisecond); ok {
+		t.Fatalf("значение отправлено до истечения срока: %v", out)
+	}
+	clock.Advance(time.Millisecond)
+	if out, ok := h.WaitOutput(1, testTimeout); !ok || !slices.Equal(out, []int{1}) {
+		t.Fatalf("выход %v, ожидалось [1]", out)
+	}
+}
+
+func TestStableGateChangeResetsTimer(t *testing.T) {
+	h, clock := stableHarness(t, time.Second)
+	sendSync(h, 1)
+	clock.Advance(999 * time.Millisecond)
+	sendSync(h, 2) // 1 не подтверждено и не отправляется
+	clock.Advance(999 * time.Millisecond)
+	if out, ok := h.WaitOutput(1, 20*time.Millisecond); ok {
+		t.Fatalf("значение отправлено до истечения срока: %v", out)
+	}
+	clock.Advance(time.Millisecond)
+	if out, ok := h.WaitOutput(1, testTimeout); !ok || !slices.Equal(out, []int{2}) {
+		t.Fatalf("выход %v, ожидалось [2]", out)
+	}
+}
+
+func TestStableGateRepeatKeepsTimer(t *testing.T) {
+	h, clock := stableHarness(t, time.Second)
+	sendSync(h, 1)
+	clock.Advance(500 * time.Millisecond)
+	sendSync(h, 1) // Повтор не сбрасывает таймер
+	clock.Advance(500 * time.Millisecond)
+	if out, ok := h.WaitOutput(1, testTimeout); !ok || !slices.Equal(out, []int{1}) {
+		t.Fatalf("выход %v, ожидалось [1]", out)
+	}
+	// Подтвержденное значение отправляется один раз
+	sendSync(h, 1)
+	clock.Advance(2 * time.Second)
+	if out, ok := h.WaitOutput(2, 20*time.Millisecond); ok {
+		t.Fatalf("подтвержденное значение отправлено повторно: %v", out)
+	}
+}
+
+func TestStableGateStaleTick(t *testing.T) {
+	h, clock := stableHarness(t, time.Second)
+	sendSync(h, 1)
+	// Таймер сработал, но стадия еще не прочитала срабатывание, когда пришло 2
+	clock.Advance(time.Second)
+	sendSync(h, 2)
+	clock.Advance(999 * time.Millisecond)
+	out, _ := h.WaitOutput(2, 20*time.Millisecond)
+	if slices.Contains(out, 2) {
+		t.Fatalf("2 отправлено через 999ms после поступления: %v", out)
+	}
+	clock.Advance(time.Millisecond)
+	// 1 отправлено, если стадия прочитала срабатывание раньше, чем 2
+	deadline := time.Now().Add(testTimeout)
+	for out = h.Output(); !slices.Contains(out, 2) && time.Now().Before(deadline); out = h.Output() {
+		time.Sleep(time.Millisecond)
+	}
+	if !slices.Equal(out, []int{2}) && !slices.Equal(out, []int{1, 2}) {
+		t.Fatalf("выход %v, ожидалось [2] или [1 2]", out)
+	}
+}
+
+func TestStableGatePendingAtClose(t *testing.T) {
+	h, clock := stableHarness(t, time.Second)
+	sendSync(h, 1)
+	clock.Advance(500 * time.Millisecond)
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if out := h.Output(); len(out) != 0 {
+		t.Fatalf("неподтвержденное значение отправлено при закрытии входа: %v", out)
+	}
+}
@@ -0,0 +1,377 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Настройки буферизации по умолчанию.
+const (
+	DefaultBufferSize    = 5               // Размер буфера
+	DefaultFlushInterval = 5 * time.Second // Интервал очистки буфера
+)
+
+// Stage - стадия пайплайна: читает значения из in и пишет результат в out.
+// Завершается при закрытии in или отмене ctx, закрывая out.
+type Stage[T any] func(ctx context.Context, in <-chan T, out chan<- T)
+
+// send - отправка v в out с учетом отмены ctx. Возвращает false при отмене.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// FilterNegative - стадия пайплайна: фильтр отрицательных чисел
+// (условие KeepNonNegative). Отброшенные значения передаются в Reject.
+func FilterNegative[T Signed | Float](ctx context.Context, in <-chan T, out chan<- T) {
+	runFilter(ctx, in, out, KeepNonNegative[T], func(n T) *Rejection {
+		return NewRejection("filter_negative", n, "отрицательное число")
+	})
+}
+
+// FilterNotDivisibleBy3 - стадия пайплайна: фильтр чисел, не кратных 3 (исключая 0),
+// то есть пропуск кратных 3 (условие KeepDivisibleBy3). Отброшенные значения
+// передаются в Reject.
+func FilterNotDivisibleBy3[T Integer](ctx context.Context, in <-chan T, out chan<- T) {
+	runFilter(ctx, in, out, KeepDivisibleBy3[T], func(n T) *Rejection {
+		return NewRejection("filter_div3", n, "не кратно 3")
+	})
+}
+
+// BufferOptions - параметры стадии буферизации.
+type BufferOptions[T any] struct {
+	Size          int                       // Размер кольцевого буфера
+	FlushInterval time.Duration             // Интервал отправки накопленных данных
+	Overflow      string                    // Политика переполнения (пусто - overwrite)
+	OnEvict       func(v T)                 // Обработчик значений, потерянных при переполнении
+	OnFlush       func(batch uint64, n int) // Вызывается перед отправкой каждой непустой партии
+	Metrics       *BufferMetrics            // Метрики буфера (nil - не собираются)
+	SpillPath     string                    // Файл сброса на диск при заполнении буфера (пусто - без сброса)
+	BatchSize     int                       // Отправка при накоплении BatchSize значений (0 - по интервалу, для NewBatchBuffer - при заполнении)
+	Control       *BufferControl            // Управление во время работы (nil - без управления)
+	Ring          string                    // Реализация хранилища: RingMutex или RingSPSC (пусто - RingMutex)
+	Clock         Clock                     // Источник времени для FlushInterval (nil - RealClock)
+	Priority      func(v T) int             // Приоритет значения (nil - без приоритетов)
+	PriorityMode  string                    // Режим приоритетов: PriorityImmediate или PriorityFirst (пусто - PriorityImmediate)
+	Sort          func(a, b T) int          // Упорядочивание значений каждой партии (nil - порядок буфера)
+	Limit         int                       // Отправка только первых Limit значений партии (0 - всех)
+	Acks          *Acks[T]                  // Подтверждение доставки партий (nil - партии не ожидают подтверждения)
+}
+
+// NewBuffer - стадия пайплайна: буферизация в кольцевом буфере размера
+// bufferSize и периодическая отправка данных каждые flushInterval.
+func NewBuffer[T any](bufferSize int, flushInterval time.Duration) Stage[T] {
+	return NewBufferWith(BufferOptions[T]{Size: bufferSize, FlushInterval: flushInterval})
+}
+
+// NewBufferWith - стадия буферизации с параметрами opts.
+// При политике block заполненный буфер отправляется сразу, задерживая вход.
+// С BatchSize > 0 буфер отправляется, как только накоплено BatchSize значений,
+// и отсчет FlushInterval начинается заново. Отправкой и интервалом можно
+// управлять во время работы через opts.Control.
+//
+// С SpillPath значения, не поместившиеся в буфер, дописываются в файл
+// (см. Spill) вместо применения политики переполнения и отправляются
+// партиями по Size после содержимого буфера. Значения, оставшиеся в буфере
+// и файле при отмене ctx, сохраняются и отправляются первыми при следующем запуске.
+//
+// С opts.Priority значения с приоритетом > 0 отправляются сразу, минуя
+// буфер (PriorityImmediate), или буфер отправляет значения по убыванию
+// приоритета и при переполнении вытесняет значения с наименьшим
+// приоритетом (PriorityFirst; opts.Ring при этом не учитывается).
+//
+// Каждая партия перед отправкой упорядочивается функцией opts.Sort, после
+// чего от нее остаются первые opts.Limit значений (например, наибольшие
+// при сортировке по убыванию); остальные передаются в Reject.
+//
+// С opts.Acks отправленные партии остаются в полете до подтверждения
+// приемником (см. Acks): партии с ошибкой доставки отправляются повторно
+// с прежними номерами перед содержимым буфера, а после закрытия входа стадия
+// ожидает подтверждения всех партий (или отмены ctx). При отмене ctx
+// неподтвержденные партии сохраняются в файле сброса SpillPath.
+func NewBufferWith[T any](opts BufferOptions[T]) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		bufferAndSend(ctx, in, opts, func(data []T) int {
+			for i, v := range data {
+				if !send(ctx, out, v) {
+					return i
+				}
+			}
+			return len(data)
+		})
+	}
+}
+
+// BatchStage - стадия, отправляющая значения партиями. Закрывает out по завершении.
+type BatchStage[T any] func(ctx context.Context, in <-chan T, out chan<- []T)
+
+// NewBatchBuffer - буферизация с отправкой партий целиком: партия отправляется
+// при заполнении буфера, накоплении opts.BatchSize значений или по истечении
+// opts.FlushInterval - в зависимости от того, что наступит раньше. Остальные
+// параметры - как у NewBufferWith.
+func NewBatchBuffer[T any](opts BufferOptions[T]) BatchStage[T] {
+	if opts.BatchSize <= 0 || opts.BatchSize > opts.Size {
+		opts.BatchSize = opts.Size
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- []T) {
+		defer close(out)
+		bufferAndSend(ctx, in, opts, func(data []T) int {
+			if len(data) == 0 || send(ctx, out, data) {
+				return len(data)
+			}
+			return 0
+		})
+	}
+}
+
+// bufferRing - хранилище значений стадии буферизации (RingBuffer или spscBuffer).
+type bufferRing[T any] interface {
+	Push(v T)
+	Full() bool
+	Len() int
+	Flush() []T
+	Resize(size int) []T
+	Peek(n int) []T
+}
+
+// Стадия пайплайна: буферизация и отправка данных функцией deliver,
+// возвращающей количество отправленных значений партии.
+// При закрытии входа остаток буфера отправляется, при отмене ctx - отбрасывается
+// (или сохраняется в файле сброса).
+func bufferAndSend[T any](ctx context.Context, in <-chan T, opts BufferOptions[T], deliver func(data []T) int) {
+	if opts.Overflow == "" {
+		opts.Overflow = OverflowOverwrite
+	}
+	cur := opts.Control.start(BufferSettings{Size: opts.Size, FlushInterval: opts.FlushInterval})
+	size, interval := cur.Size, cur.FlushInterval
+	if opts.Clock == nil {
+		opts.Clock = RealClock{}
+	}
+	ticker := opts.Clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	m := opts.Metrics
+	var onEvict func(v T)
+	if m != nil || opts.OnEvict != nil {
+		onEvict = func(v T) {
+			if m != nil {
+				m.Dropped.Add(1)
+			}
+			if opts.OnEvict != nil {
+				opts.OnEvict(v)
+			}
+		}
+	}
+	newBuffer := func(size int) bufferRing[T] {
+		if opts.Priority != nil && opts.PriorityMode == PriorityFirst {
+			return newPriorityBuffer(size, opts.Overflow, opts.Priority, onEvict)
+		}
+		// Стадия - единственный писатель и читатель хранилища: RingSPSC
+		// убирает только блокировку, отправки значений в каналы не меняются
+		if opts.Ring == RingSPSC {
+			return newSPSCBuffer(size, opts.Overflow, onEvict)
+		}
+		rb := NewRingBufferPolicy[T](size, opts.Overflow)
+		if onEvict != nil {
+			rb.OnEvict(onEvict)
+		}
+		return rb
+	}
+	buffer := newBuffer(size)
+	var spill *Spill[T]
+	if opts.SpillPath != "" {
+		s, err := OpenSpill[T](opts.SpillPath)
+		if err != nil {
+			ReportError(ctx, NewStageError("buffer", nil, err))
+		} else {
+			spill = s
+			defer func() {
+				if err := spill.Close(); err != nil {
+					ReportError(ctx, NewStageError("buffer", nil, err))
+				}
+			}()
+		}
+	}
+	spilled := func() {
+		if m != nil && spill != nil {
+			m.Spilled.Store(int64(spill.Len()))
+		}
+	}
+
+	// Неподтвержденные партии сохраняются раньше содержимого буфера (см. ctx.Done)
+	if spill != nil && opts.Acks != nil {
+		defer func() {
+			spill.PushFront(opts.Acks.take()...)
+		}()
+	}
+
+	batch := opts.Acks.start() // Номер последней отправленной партии
+	// transmit - отправка партии id (при повторной отправке - с прежним номером)
+	transmit := func(id uint64, data []T) bool {
+		if m != nil {
+			m.Flushes.Add(1)
+		}
+		if opts.OnFlush != nil {
+			opts.OnFlush(id, len(data))
+		}
+		opts.Acks.sent(id, data)
+		if sent := deliver(data); sent < len(data) {
+			// Неподтвержденная партия сохраняется целиком при завершении
+			if spill != nil && opts.Acks == nil {
+				spill.PushFront(data[sent:]...) // Сохранение неотправленного остатка
+				spilled()
+			}
+			return false
+		}
+		return true
+	}
+	audit := rejecting(ctx)
+	emit := func(data []T) bool {
+		if opts.Sort != nil {
+			slices.SortStableFunc(data, opts.Sort)
+		}
+		if opts.Limit > 0 && len(data) > opts.Limit {
+			if audit {
+				for _, v := range data[opts.Limit:] {
+					Reject(ctx, NewRejection("buffer", v, "вне первых значений партии"))
+				}
+			}
+			data = data[:opts.Limit]
+		}
+		if len(data) == 0 {
+			return true
+		}
+		batch++
+		return transmit(batch, data)
+	}
+	flush := func() bool {
+		// Повторная отправка партий с ошибкой доставки
+		for _, id := range opts.Acks.retries() {
+			if data, ok := opts.Acks.resend(id); ok && !transmit(id, data) {
+				return false
+			}
+		}
+		data := buffer.Flush()
+		if m != nil {
+			m.Occupancy.Store(0)
+		}
+		if !emit(data) {
+			return false
+		}
+		// Отправка сброшенных на диск значений партиями по размеру буфера
+		for spill != nil && spill.Len() > 0 {
+			chunk := make([]T, 0, min(size, spill.Len()))
+			for len(chunk) < size {
+				v, ok, err := spill.Pop()
+				if err != nil {
+					ReportError(ctx, NewStageError("buffer", nil, err))
+				}
+				if !ok {
+					break
+				}
+				chunk = append(chunk, v)
+			}
+			spilled()
+			if len(chunk) == 0 || !emit(chunk) {
+				return len(chunk) == 0
+			}
+		}
+		return true
+	}
+
+	// Значения, сохраненные предыдущим запуском, отправляются до нового входа
+	spilled()
+	if spill != nil && spill.Len() > 0 && !flush() {
+		return
+	}
+
+	for {
+		select {
+		case n, ok := <-in:
+			if !ok {
+				// Вход закрыт: отправка остатка буфера и ожидание подтверждений
+				if !flush() {
+					return
+				}
+				// Партии с ошибкой отправляются повторно с интервалом отправки
+				for opts.Acks.InFlight() > 0 {
+					select {
+					case <-opts.Acks.changes():
+					case <-ticker.C():
+						if !flush() {
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+				return
+			}
+			if opts.Priority != nil && opts.PriorityMode != PriorityFirst && opts.Priority(n) > 0 {
+				// Значение с высоким приоритетом отправляется отдельной партией
+				if !emit([]T{n}) {
+					return
+				}
+				continue
+			}
+			// Порядок сохраняется: пока файл не пуст, новые значения идут в него
+			if spill != nil && (buffer.Full() || spill.Len() > 0) {
+				err := spill.Push(n)
+				spilled()
+				if err == nil {
+					continue
+				}
+				ReportError(ctx, NewStageError("buffer", n, err))
+			}
+			if opts.Overflow == OverflowBlock && buffer.Full() && !flush() {
+				return
+			}
+			buffer.Push(n)
+			if m != nil {
+				m.Occupancy.Store(int64(buffer.Len()))
+			}
+			if opts.BatchSize > 0 && buffer.Len() >= min(opts.BatchSize, size) {
+				if !flush() {
+					return
+				}
+				ticker.Reset(interval)
+			}
+		case <-ticker.C():
+			if !flush() {
+				return
+			}
+		case <-opts.Control.flushRequests():
+			if !flush() {
+				return
+			}
+			ticker.Reset(interval)
+		case q := <-opts.Control.snapshotQueries():
+			q.reply <- buffer.Peek(q.n)
+		case <-opts.Control.changes():
+			cur := opts.Control.Settings()
+			if cur.Size != size {
+				// Значения, не помещающиеся в новый буфер, отправляются
+				if buffer.Len() > cur.Size && !flush() {
+					return
+				}
+				size = cur.Size
+				buffer.Resize(size)
+			}
+			if cur.FlushInterval != interval {
+				interval = cur.FlushInterval
+				ticker.Reset(interval)
+			}
+		case <-ctx.Done():
+			if spill != nil {
+				spill.PushFront(buffer.Flush()...)
+			}
+			return
+		}
+	}
+}
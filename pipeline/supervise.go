@@ -0,0 +1,212 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Политики перезапуска стадии.
+const (
+	RestartNever     = "never"      // Не перезапускать
+	RestartOnFailure = "on-failure" // Перезапускать после паники
+	RestartAlways    = "always"     // Перезапускать после паники и завершения до закрытия входа
+)
+
+// Интервалы ожидания перед перезапуском стадии по умолчанию.
+const (
+	DefaultRestartMinBackoff = 100 * time.Millisecond
+	DefaultRestartMaxBackoff = 30 * time.Second
+)
+
+// ValidateRestart - проверка политики перезапуска.
+func ValidateRestart(policy string) error {
+	switch policy {
+	case RestartNever, RestartOnFailure, RestartAlways:
+		return nil
+	}
+	return fmt.Errorf("неизвестная политика перезапуска: %q (ожидается %s, %s или %s)",
+		policy, RestartNever, RestartOnFailure, RestartAlways)
+}
+
+// PanicError - паника в стадии: значение recover и стек вызовов.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// Error - описание паники.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("паника: %v", e.Value)
+}
+
+// asPanicError - значение recover r как *PanicError со стеком паники
+// (вызывается в отложенной функции). Паника вложенной обертки передается как есть.
+func asPanicError(r any) *PanicError {
+	if pe, ok := r.(*PanicError); ok {
+		return pe
+	}
+	return &PanicError{Value: r, Stack: debug.Stack()}
+}
+
+// SuperviseOptions - параметры надзора за стадией.
+type SuperviseOptions struct {
+	Policy      string        // Политика перезапуска (пусто - on-failure)
+	MinBackoff  time.Duration // Ожидание перед первым перезапуском (0 - DefaultRestartMinBackoff)
+	MaxBackoff  time.Duration // Наибольшее ожидание (0 - DefaultRestartMaxBackoff)
+	MaxRestarts int           // Наибольшее количество перезапусков (0 - без ограничения)
+	Clock       Clock         // Источник времени (nil - RealClock)
+	// OnRestart вызывается перед каждым перезапуском с его номером (с 1)
+	// и причиной: *PanicError или ошибкой досрочного завершения. Может быть nil.
+	OnRestart func(restart int, cause error)
+}
+
+// errStageExited - стадия завершилась до закрытия входа.
+var errStageExited = fmt.Errorf("стадия завершилась до закрытия входа")
+
+// Supervise - стадия stage под надзором: паника перехватывается и передается
+// в ReportError как StageError с последним переданным стадии значением и
+// *PanicError со стеком, после чего стадия перезапускается согласно
+// opts.Policy с удваивающимся ожиданием. Значение, при обработке которого
+// произошла паника, теряется; состояние стадии при перезапуске сбрасывается.
+// Если стадия не перезапускается, оставшиеся значения входа отбрасываются
+// до его закрытия, чтобы не задерживать предыдущие стадии.
+func Supervise[T any](name string, stage Stage[T], opts SuperviseOptions) Stage[T] {
+	if opts.Policy == "" {
+		opts.Policy = RestartOnFailure
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultRestartMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultRestartMaxBackoff
+	}
+	if opts.Clock == nil {
+		opts.Clock = RealClock{}
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+
+		// Вход общий для всех запусков: значение, не принятое упавшей
+		// стадией, получит следующий запуск
+		sv := &supervisedInput[T]{in: in}
+		backoff := opts.MinBackoff
+		for restarts := 0; ; {
+			started := opts.Clock.Now()
+			cause := sv.run(ctx, stage, out)
+			if ctx.Err() != nil {
+				return
+			}
+			if cause == nil && sv.closed && !sv.pending {
+				return // Вход закрыт, стадия завершилась штатно
+			}
+			if pe, ok := cause.(*PanicError); ok {
+				ReportError(ctx, NewStageError(name, sv.last, pe))
+			} else if cause == nil {
+				cause = errStageExited
+			}
+			restart := opts.Policy == RestartAlways ||
+				opts.Policy == RestartOnFailure && cause != errStageExited
+			if !restart || opts.MaxRestarts > 0 && restarts >= opts.MaxRestarts {
+				if !sv.closed {
+					discard(ctx, in)
+				}
+				return
+			}
+			// Долго проработавшая стадия перезапускается без накопленной задержки
+			if opts.Clock.Now().Sub(started) > opts.MaxBackoff {
+				backoff = opts.MinBackoff
+			}
+			timer := opts.Clock.NewTimer(backoff)
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			backoff = min(backoff*2, opts.MaxBackoff)
+			restarts++
+			if opts.OnRestart != nil {
+				opts.OnRestart(restarts, cause)
+			}
+		}
+	}
+}
+
+// supervisedInput - вход стадии под надзором, общий для ее запусков.
+type supervisedInput[T any] struct {
+	in      <-chan T
+	value   T    // Прочитанное, но не принятое стадией значение
+	pending bool // value еще не передано стадии
+	closed  bool // Вход закрыт
+	last    any  // Последнее принятое стадией значение
+}
+
+// run - один запуск стадии с передачей ей значений входа и результатов в out.
+// Возвращает *PanicError при панике стадии.
+func (sv *supervisedInput[T]) run(ctx context.Context, stage Stage[T], out chan<- T) error {
+	stageIn := make(chan T)
+	stageOut := make(chan T)
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- asPanicError(r)
+				return
+			}
+			result <- nil
+		}()
+		stage(ctx, stageIn, stageOut)
+	}()
+	inClosed := false
+	for {
+		var recv <-chan T
+		var forward chan<- T
+		switch {
+		case sv.pending:
+			forward = stageIn
+		case !sv.closed:
+			recv = sv.in
+		case !inClosed:
+			close(stageIn)
+			inClosed = true
+		}
+		select {
+		case v, ok := <-recv:
+			if !ok {
+				sv.closed = true
+				continue
+			}
+			sv.value, sv.pending = v, true
+		case forward <- sv.value:
+			// Значение принято стадией: при панике оно будет указано в ошибке
+			sv.last, sv.pending = sv.value, false
+		case v, ok := <-stageOut:
+			if !ok {
+				// Выход закрыт: ожидание результата запуска
+				return <-result
+			}
+			if !send(ctx, out, v) {
+				return <-result
+			}
+		case err := <-result:
+			// Стадия завершилась, не закрыв выход (паника до defer close)
+			return err
+		}
+	}
+}
+
+// discard - чтение и отбрасывание значений in до закрытия или отмены ctx.
+func discard[T any](ctx context.Context, in <-chan T) {
+	for {
+		select {
+		case _, ok := <-in:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
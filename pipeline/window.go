@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"time"
+)
+
+// NewWindowMode - стадия, отправляющая на каждом тике интервала interval
+// моду (наиболее частое значение) окна. При равенстве частот выбирается
+// наименьшее значение. Пустые окна пропускаются, последнее окно
+// отправляется при закрытии входа.
+func NewWindowMode[T cmp.Ordered](interval time.Duration, clock Clock) Stage[T] {
+	return NewWindowModeBy(identity[T], interval, clock)
+}
+
+// modeCount - количество значений с ключом в окне и последнее из них.
+type modeCount[T any] struct {
+	n    int
+	last T
+}
+
+// NewWindowModeBy - NewWindowMode с подсчетом значений по ключу key
+// (например, ItemValue). Отправляется последнее значение окна с ключом-модой.
+func NewWindowModeBy[T any, K cmp.Ordered](key func(T) K, interval time.Duration, clock Clock) Stage[T] {
+	return NewWindowModeFunc(key, cmp.Compare[K], interval, clock)
+}
+
+// NewWindowModeFunc - NewWindowModeBy для ключей без естественного порядка
+// (например, Num): при равенстве частот выбирается ключ, наименьший по compare.
+func NewWindowModeFunc[T any, K comparable](key func(T) K, compare func(a, b K) int, interval time.Duration, clock Clock) Stage[T] {
+	return func(ctx context.Context, in <-chan T, out chan<- T) {
+		defer close(out)
+		ticker := clock.NewTicker(interval)
+		defer ticker.Stop()
+
+		counts := make(map[K]modeCount[T])
+		emit := func() bool {
+			if len(counts) == 0 {
+				return true // Пустое окно
+			}
+			var mode K
+			best := modeCount[T]{}
+			for k, c := range counts {
+				if c.n > best.n || (c.n == best.n && compare(k, mode) < 0) {
+					mode, best = k, c
+				}
+			}
+			clear(counts)
+			return send(ctx, out, best.last)
+		}
+
+		for {
+			select {
+			case n, ok := <-in:
+				if !ok {
+					emit()
+					return
+				}
+				k := key(n)
+				c := counts[k]
+				c.n++
+				c.last = n
+				counts[k] = c
+			case <-ticker.C():
+				if !emit() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWindowMode(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := NewHarness(testContext(t), NewWindowMode[int](time.Second, clock))
+
+	// Частоты 3 и 1 равны: выбирается наименьшее значение
+	h.Send(3, 1, 3, 1, 2)
+	clock.Advance(time.Second)
+	if out, ok := h.WaitOutput(1, testTimeout); !ok || !slices.Equal(out, []int{1}) {
+		t.Fatalf("выход первого окна %v, ожидалось [1]", out)
+	}
+
+	// Пустое окно пропускается
+	clock.Advance(time.Second)
+	if out, ok := h.WaitOutput(2, 20*time.Millisecond); ok {
+		t.Fatalf("отправлено пустое окно: %v", out)
+	}
+
+	// Последнее окно отправляется при закрытии входа
+	h.Send(7, 5, 5)
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if out := h.Output(); !slices.Equal(out, []int{1, 5}) {
+		t.Fatalf("выход %v, ожидалось [1 5]", out)
+	}
+}
+
+func TestWindowModeByKeepsLastValue(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := NewHarness(testContext(t), NewWindowModeBy(ItemValue[int], time.Second, clock))
+	h.Send(Item[int]{Value: 4, Seq: 1}, Item[int]{Value: 8, Seq: 2}, Item[int]{Value: 4, Seq: 3})
+	clock.Advance(time.Second)
+	out, ok := h.WaitOutput(1, testTimeout)
+	if !ok || out[0].Value != 4 || out[0].Seq != 3 {
+		t.Fatalf("выход %v, ожидалось последнее значение 4 (Seq 3)", out)
+	}
+	h.Close()
+}
@@ -0,0 +1,128 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pipeline.proto
+
+// Потоковый интерфейс пайплайна: клиенты передают значения и получают
+// результат обработки (подкоманда grpc).
+
+package pipelinepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Pipeline_Process_FullMethodName = "/pipeline.v1.Pipeline/Process"
+)
+
+// PipelineClient is the client API for Pipeline service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Pipeline - обработка потока значений стадиями пайплайна.
+type PipelineClient interface {
+	// Process - значения клиента обрабатываются пайплайном, результат
+	// возвращается в том же вызове. Закрытие передачи клиентом завершает
+	// вызов после дообработки его значений.
+	Process(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Value, Value], error)
+}
+
+type pipelineClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPipelineClient(cc grpc.ClientConnInterface) PipelineClient {
+	return &pipelineClient{cc}
+}
+
+func (c *pipelineClient) Process(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Value, Value], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pipeline_ServiceDesc.Streams[0], Pipeline_Process_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Value, Value]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pipeline_ProcessClient = grpc.BidiStreamingClient[Value, Value]
+
+// PipelineServer is the server API for Pipeline service.
+// All implementations must embed UnimplementedPipelineServer
+// for forward compatibility.
+//
+// Pipeline - обработка потока значений стадиями пайплайна.
+type PipelineServer interface {
+	// Process - значения клиента обрабатываются пайплайном, результат
+	// возвращается в том же вызове. Закрытие передачи клиентом завершает
+	// вызов после дообработки его значений.
+	Process(grpc.BidiStreamingServer[Value, Value]) error
+	mustEmbedUnimplementedPipelineServer()
+}
+
+// UnimplementedPipelineServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPipelineServer struct{}
+
+func (UnimplementedPipelineServer) Process(grpc.BidiStreamingServer[Value, Value]) error {
+	return status.Errorf(codes.Unimplemented, "method Process not implemented")
+}
+func (UnimplementedPipelineServer) mustEmbedUnimplementedPipelineServer() {}
+func (UnimplementedPipelineServer) testEmbeddedByValue()                  {}
+
+// UnsafePipelineServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PipelineServer will
+// result in compilation errors.
+type UnsafePipelineServer interface {
+	mustEmbedUnimplementedPipelineServer()
+}
+
+func RegisterPipelineServer(s grpc.ServiceRegistrar, srv PipelineServer) {
+	// If the following call pancis, it indicates UnimplementedPipelineServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Pipeline_ServiceDesc, srv)
+}
+
+func _Pipeline_Process_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PipelineServer).Process(&grpc.GenericServerStream[Value, Value]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pipeline_ProcessServer = grpc.BidiStreamingServer[Value, Value]
+
+// Pipeline_ServiceDesc is the grpc.ServiceDesc for Pipeline service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pipeline_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pipeline.v1.Pipeline",
+	HandlerType: (*PipelineServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Process",
+			Handler:       _Pipeline_Process_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pipeline.proto",
+}